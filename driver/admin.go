@@ -0,0 +1,183 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+	"time"
+)
+
+// operationRecord describes an in-flight CSI operation.
+type operationRecord struct {
+	Operation string    `json:"operation"`
+	Started   time.Time `json:"started"`
+	Volume    string    `json:"volume"`
+}
+
+var (
+	// inflightOperations tracks the CSI operations which are currently in progress, keyed by a
+	// monotonically increasing sequence number.
+	inflightOperations      = map[uint64]operationRecord{}
+	inflightOperationsMutex = sync.Mutex{}
+	operationSequence       uint64
+)
+
+// beginOperation records an in-flight CSI operation for the admin endpoint and returns its
+// sequence number.
+func beginOperation(operation string, volume string) uint64 {
+	inflightOperationsMutex.Lock()
+	defer inflightOperationsMutex.Unlock()
+
+	operationSequence++
+	inflightOperations[operationSequence] = operationRecord{
+		Operation: operation,
+		Started:   time.Now(),
+		Volume:    volume,
+	}
+
+	return operationSequence
+}
+
+// endOperation removes a completed CSI operation from the in-flight tracking.
+func endOperation(sequence uint64) {
+	inflightOperationsMutex.Lock()
+	defer inflightOperationsMutex.Unlock()
+
+	delete(inflightOperations, sequence)
+}
+
+// adminCacheEntry describes a cached server in the admin state dump.
+type adminCacheEntry struct {
+	Expires  time.Time `json:"expires"`
+	Hostname string    `json:"hostname"`
+	ID       string    `json:"id"`
+}
+
+// adminLockState describes a volume lock in the admin state dump.
+type adminLockState struct {
+	Held bool   `json:"held"`
+	Name string `json:"name"`
+}
+
+// adminState is the JSON document served by the admin state endpoint.
+type adminState struct {
+	Operations  []operationRecord `json:"operations"`
+	ServerCache []adminCacheEntry `json:"server_cache"`
+	VolumeLocks []adminLockState  `json:"volume_locks"`
+}
+
+// adminStateHandler serves a JSON dump of the in-flight operations, the server cache and the
+// volume lock state for diagnosing hung operations.
+func adminStateHandler(d *Driver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := adminState{
+			Operations:  []operationRecord{},
+			ServerCache: []adminCacheEntry{},
+			VolumeLocks: []adminLockState{},
+		}
+
+		inflightOperationsMutex.Lock()
+
+		for _, record := range inflightOperations {
+			state.Operations = append(state.Operations, record)
+		}
+
+		inflightOperationsMutex.Unlock()
+
+		d.serverCache.mutex.Lock()
+
+		for id, entry := range d.serverCache.entries {
+			state.ServerCache = append(state.ServerCache, adminCacheEntry{
+				Expires:  entry.expires,
+				Hostname: entry.server.Hostname,
+				ID:       id,
+			})
+		}
+
+		d.serverCache.mutex.Unlock()
+
+		volumeLocksMutex.Lock()
+
+		for name, mutex := range volumeLocks {
+			held := !mutex.TryLock()
+
+			if !held {
+				mutex.Unlock()
+			}
+
+			state.VolumeLocks = append(state.VolumeLocks, adminLockState{
+				Held: held,
+				Name: name,
+			})
+		}
+
+		volumeLocksMutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(state)
+
+		if err != nil {
+			log.Printf("Failed to encode the admin state - Reason: %s", err.Error())
+		}
+	}
+}
+
+// adminDNSRecordsHandler emits zone file records for the managed storage servers, allowing an
+// external process to keep the configured DNS zone in sync with the fleet.
+func adminDNSRecordsHandler(d *Driver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if d.Configuration.DNSZone == "" {
+			http.Error(w, "No DNS zone is configured", http.StatusNotFound)
+
+			return
+		}
+
+		servers, err := d.listManagedServers()
+
+		if err != nil {
+			http.Error(w, "Failed to retrieve the list of servers", http.StatusInternalServerError)
+
+			return
+		}
+
+		zone := strings.TrimSuffix(d.Configuration.DNSZone, ".")
+
+		w.Header().Set("Content-Type", "text/plain")
+
+		for _, server := range servers {
+			if len(server.NetworkInterfaces) == 0 || len(server.NetworkInterfaces[0].IPAddresses) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(w, "%s.%s. 300 IN A %s\n", server.Hostname, zone, server.NetworkInterfaces[0].IPAddresses[0].Address)
+		}
+	}
+}
+
+// serveAdmin exposes the admin endpoint on the loopback interface. The endpoint is restricted to
+// localhost as the profiling and state dumps are not meant to leave the pod.
+func serveAdmin(d *Driver) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/dns-records", adminDNSRecordsHandler(d))
+	mux.HandleFunc("/state", adminStateHandler(d))
+
+	err := http.ListenAndServe(fmt.Sprintf("127.0.0.1:%d", d.Configuration.AdminPort), mux)
+
+	if err != nil {
+		log.Printf("Failed to serve the admin endpoint - Reason: %s", err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// configureAPITransport applies the custom CA bundle, TLS verification and proxy settings for the
+// Cloud.dk API traffic. The API client library issues its requests through the default transport,
+// so the settings are applied there.
+func configureAPITransport(c *Configuration) error {
+	if c.APICACertFile == "" && !c.APIInsecureSkipVerify && c.APIProxyURL == "" {
+		return nil
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+
+	if !ok {
+		return fmt.Errorf("The default HTTP transport does not support custom TLS settings")
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.APIInsecureSkipVerify,
+	}
+
+	if c.APICACertFile != "" {
+		caCert, err := ioutil.ReadFile(c.APICACertFile)
+
+		if err != nil {
+			return fmt.Errorf("Failed to read the CA bundle for the API client - Reason: %s", err.Error())
+		}
+
+		caCertPool := x509.NewCertPool()
+
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return fmt.Errorf("Failed to parse the CA bundle for the API client")
+		}
+
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	if c.APIProxyURL != "" {
+		proxyURL, err := url.Parse(c.APIProxyURL)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse the proxy URL for the API client - Reason: %s", err.Error())
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	// publishedNodes tracks the node ids each volume is published to through this controller
+	// along with the address each node was granted access for.
+	publishedNodes      = map[string]map[string]string{}
+	publishedNodesMutex = sync.Mutex{}
+)
+
+// getAttachmentIP returns the address a node was granted access for, if any.
+func getAttachmentIP(id string, nodeID string) string {
+	publishedNodesMutex.Lock()
+	defer publishedNodesMutex.Unlock()
+
+	return publishedNodes[id][nodeID]
+}
+
+// getPublishedNodeIDs returns the ids of the nodes a volume is published to in a stable order.
+func getPublishedNodeIDs(id string) []string {
+	publishedNodesMutex.Lock()
+	defer publishedNodesMutex.Unlock()
+
+	nodeIDs := []string{}
+
+	for nodeID := range publishedNodes[id] {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+
+	sort.Strings(nodeIDs)
+
+	return nodeIDs
+}
+
+// recordAttachment registers a node as having access to a volume through the given address.
+func recordAttachment(id string, nodeID string, accessIP string) {
+	publishedNodesMutex.Lock()
+	defer publishedNodesMutex.Unlock()
+
+	if publishedNodes[id] == nil {
+		publishedNodes[id] = map[string]string{}
+	}
+
+	publishedNodes[id][nodeID] = accessIP
+}
+
+// removeAttachmentRecord removes a node from the attachment records for a volume.
+func removeAttachmentRecord(id string, nodeID string) {
+	publishedNodesMutex.Lock()
+	defer publishedNodesMutex.Unlock()
+
+	delete(publishedNodes[id], nodeID)
+
+	if len(publishedNodes[id]) == 0 {
+		delete(publishedNodes, id)
+	}
+}
@@ -0,0 +1,78 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditRecord is a single entry in the append-only audit log.
+type auditRecord struct {
+	Action      string    `json:"action"`
+	Correlation string    `json:"correlation,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+	Node        string    `json:"node,omitempty"`
+	Server      string    `json:"server,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+var (
+	// auditWriter receives the audit records as JSON lines. Auditing is disabled while the writer
+	// is nil.
+	auditWriter      io.Writer
+	auditWriterMutex = sync.Mutex{}
+)
+
+// initAuditLog directs the audit records to the given file. The value '-' selects the standard
+// output stream.
+func initAuditLog(path string) error {
+	if path == "-" {
+		auditWriter = os.Stdout
+
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return fmt.Errorf("Failed to open the audit log '%s' - Reason: %s", path, err.Error())
+	}
+
+	auditWriter = file
+
+	return nil
+}
+
+// auditEvent appends a record for a mutating operation to the audit log. The records are written
+// on a best effort basis and never fail the operation being audited.
+func auditEvent(action string, server string, node string, format string, v ...interface{}) {
+	if auditWriter == nil {
+		return
+	}
+
+	record := auditRecord{
+		Action:      action,
+		Correlation: getCorrelationID(),
+		Detail:      fmt.Sprintf(format, v...),
+		Node:        node,
+		Server:      server,
+		Time:        time.Now().UTC(),
+	}
+
+	auditWriterMutex.Lock()
+	defer auditWriterMutex.Unlock()
+
+	err := json.NewEncoder(auditWriter).Encode(record)
+
+	if err != nil {
+		log.Printf("Failed to write an audit record - Reason: %s", err.Error())
+	}
+}
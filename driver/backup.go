@@ -0,0 +1,202 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// resticEnvironmentVariables lists the environment variables forwarded to restic on the storage
+// server in order to authenticate against the backup repository.
+var resticEnvironmentVariables = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_DEFAULT_REGION",
+	"AWS_SECRET_ACCESS_KEY",
+	"RESTIC_PASSWORD",
+}
+
+// BackupVolume streams the data of the given volume off its storage server. Repository targets
+// (e.g. s3:...) are handled by restic running on the storage server while plain paths receive a
+// gzip compressed tar archive. The backup is taken online, so applications should be quiesced or
+// the volume frozen beforehand for full consistency.
+func (d *Driver) BackupVolume(volumeID string, target string) error {
+	ns, err := d.loadNetworkStorageByVolumeID(volumeID)
+
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(target, "'") {
+		return fmt.Errorf("The target '%s' contains unsupported characters", target)
+	}
+
+	if strings.HasPrefix(target, "s3:") {
+		return ns.backupWithRestic(target)
+	}
+
+	return ns.backupToFile(target)
+}
+
+// RestoreVolume streams previously backed up data onto the storage server of the given volume.
+// The volume must not be published while the restore is in progress.
+func (d *Driver) RestoreVolume(volumeID string, target string) error {
+	ns, err := d.loadNetworkStorageByVolumeID(volumeID)
+
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(target, "'") {
+		return fmt.Errorf("The target '%s' contains unsupported characters", target)
+	}
+
+	if strings.HasPrefix(target, "s3:") {
+		return ns.restoreWithRestic(target)
+	}
+
+	return ns.restoreFromFile(target)
+}
+
+// resticEnvironment builds the shell exports forwarded to restic on the storage server.
+func resticEnvironment() (string, error) {
+	exports := []string{"export DEBIAN_FRONTEND=noninteractive"}
+
+	for _, name := range resticEnvironmentVariables {
+		value := os.Getenv(name)
+
+		if value == "" {
+			continue
+		}
+
+		if strings.Contains(value, "'") {
+			return "", fmt.Errorf("The environment variable '%s' contains unsupported characters", name)
+		}
+
+		exports = append(exports, fmt.Sprintf("export %s='%s'", name, value))
+	}
+
+	return strings.Join(exports, "&& "), nil
+}
+
+// backupWithRestic backs the data disk up to a restic repository from the storage server.
+func (ns *NetworkStorage) backupWithRestic(target string) error {
+	debugCloudAction(rtNetworkStorage, "Backing up server with restic (id: %s)", ns.ID)
+
+	environment, err := resticEnvironment()
+
+	if err != nil {
+		return err
+	}
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"%s&& (which restic > /dev/null || (apt-get -qq update&& apt-get -qq -y install restic))&& restic -r '%s' backup /mnt/data",
+		environment,
+		target,
+	))
+
+	if err != nil {
+		return fmt.Errorf("Failed to back up the volume (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+	}
+
+	fmt.Printf("Backed up volume to '%s' (id: %s)\n", target, ns.ID)
+
+	return nil
+}
+
+// backupToFile streams a gzip compressed tar archive of the data disk to a local file.
+func (ns *NetworkStorage) backupToFile(target string) error {
+	debugCloudAction(rtNetworkStorage, "Backing up server to a file (id: %s)", ns.ID)
+
+	sshSession, err := ns.CreateSSHSession(nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	file, err := os.Create(target)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	stderr := new(bytes.Buffer)
+	sshSession.Stdout = file
+	sshSession.Stderr = stderr
+
+	err = sshSession.Run("tar -czf - -C /mnt/data .")
+
+	if err != nil {
+		return fmt.Errorf("Failed to back up the volume (id: %s) - Output: %s - Error: %s", ns.ID, stderr.String(), err.Error())
+	}
+
+	fmt.Printf("Backed up volume to '%s' (id: %s)\n", target, ns.ID)
+
+	return nil
+}
+
+// restoreWithRestic restores the latest snapshot from a restic repository onto the data disk.
+func (ns *NetworkStorage) restoreWithRestic(target string) error {
+	debugCloudAction(rtNetworkStorage, "Restoring server with restic (id: %s)", ns.ID)
+
+	environment, err := resticEnvironment()
+
+	if err != nil {
+		return err
+	}
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"%s&& (which restic > /dev/null || (apt-get -qq update&& apt-get -qq -y install restic))&& restic -r '%s' restore latest --target /",
+		environment,
+		target,
+	))
+
+	if err != nil {
+		return fmt.Errorf("Failed to restore the volume (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+	}
+
+	fmt.Printf("Restored volume from '%s' (id: %s)\n", target, ns.ID)
+
+	return nil
+}
+
+// restoreFromFile streams a gzip compressed tar archive from a local file onto the data disk.
+func (ns *NetworkStorage) restoreFromFile(target string) error {
+	debugCloudAction(rtNetworkStorage, "Restoring server from a file (id: %s)", ns.ID)
+
+	sshSession, err := ns.CreateSSHSession(nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	file, err := os.Open(target)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	sshSession.Stdin = file
+
+	output, err := sshSession.CombinedOutput("tar -xzf - -C /mnt/data")
+
+	if err != nil {
+		return fmt.Errorf("Failed to restore the volume (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+	}
+
+	fmt.Printf("Restored volume from '%s' (id: %s)\n", target, ns.ID)
+
+	return nil
+}
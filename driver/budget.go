@@ -0,0 +1,65 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkBudget determines if provisioning the given number of storage servers with the given
+// total capacity in GB would exceed the configured caps, and returns a descriptive error when it
+// would. The current usage is derived from the storage servers on the account.
+func checkBudget(d *Driver, servers int, capacity int) (err error) {
+	if d.Configuration.MaxStorageServers <= 0 && d.Configuration.MaxTotalCapacity <= 0 {
+		return nil
+	}
+
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to determine the current usage due to API errors")
+
+		return err
+	}
+
+	hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+	currentServers := 0
+	currentCapacity := 0
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, hostnamePrefix) {
+			continue
+		}
+
+		currentServers++
+
+		for _, disk := range v.Disks {
+			if disk.Label == nsDiskLabel || disk.Label == nsDiskLabelMirror {
+				currentCapacity += int(disk.Size)
+			}
+		}
+	}
+
+	if d.Configuration.MaxStorageServers > 0 && currentServers+servers > d.Configuration.MaxStorageServers {
+		return fmt.Errorf(
+			"The cap of %d storage servers would be exceeded (current: %d - requested: %d)",
+			d.Configuration.MaxStorageServers,
+			currentServers,
+			servers,
+		)
+	}
+
+	if d.Configuration.MaxTotalCapacity > 0 && currentCapacity+capacity > d.Configuration.MaxTotalCapacity {
+		return fmt.Errorf(
+			"The cap of %d GB of provisioned capacity would be exceeded (current: %d GB - requested: %d GB)",
+			d.Configuration.MaxTotalCapacity,
+			currentCapacity,
+			capacity,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+const (
+	// serverCacheTTLSeconds defines the number of seconds a cached server lookup remains valid.
+	serverCacheTTLSeconds = 15
+)
+
+// serverCache caches server lookups for a short period of time in order to reduce the number
+// of Cloud.dk API calls on busy clusters.
+type serverCache struct {
+	entries map[string]*serverCacheEntry
+	mutex   sync.Mutex
+}
+
+// serverCacheEntry contains a cached server and its expiration time.
+type serverCacheEntry struct {
+	expires time.Time
+	server  clouddk.ServerBody
+}
+
+// newServerCache creates a new, empty server cache.
+func newServerCache() *serverCache {
+	return &serverCache{
+		entries: map[string]*serverCacheEntry{},
+	}
+}
+
+// get returns a copy of the server stored under the given key, if any.
+func (c *serverCache) get(key string) *clouddk.ServerBody {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+
+	if !ok {
+		return nil
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+
+		return nil
+	}
+
+	server := entry.server
+
+	return &server
+}
+
+// getByHostname returns a copy of the cached server with the given hostname, if any.
+func (c *serverCache) getByHostname(hostname string) *clouddk.ServerBody {
+	return c.get("hostname/" + hostname)
+}
+
+// getByID returns a copy of the cached server with the given id, if any.
+func (c *serverCache) getByID(id string) *clouddk.ServerBody {
+	return c.get("id/" + id)
+}
+
+// invalidate removes the entries for the given server id and hostname.
+func (c *serverCache) invalidate(id string, hostname string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, "id/"+id)
+	delete(c.entries, "hostname/"+hostname)
+}
+
+// set stores the given server under both its id and its hostname.
+func (c *serverCache) set(server *clouddk.ServerBody) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &serverCacheEntry{
+		expires: time.Now().Add(serverCacheTTLSeconds * time.Second),
+		server:  *server,
+	}
+
+	c.entries["id/"+server.Identifier] = entry
+	c.entries["hostname/"+server.Hostname] = entry
+}
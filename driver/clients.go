@@ -0,0 +1,63 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+// CloudClient performs requests against the Cloud.dk API.
+type CloudClient interface {
+	DoRequest(method string, path string, body *bytes.Buffer, successCodes []int, retryLimit int, retryDelay int) (*http.Response, error)
+}
+
+// FileUploader uploads files to a storage server.
+type FileUploader interface {
+	Upload(ns *NetworkStorage, filePath string, fileContents *bytes.Buffer) error
+}
+
+// RemoteExecutor executes shell commands on a storage server.
+type RemoteExecutor interface {
+	Execute(ns *NetworkStorage, command string) ([]byte, error)
+}
+
+// cloudAPIClient implements the CloudClient interface against the Cloud.dk API.
+type cloudAPIClient struct {
+	settings *clouddk.ClientSettings
+}
+
+// DoRequest performs a request against the Cloud.dk API.
+func (c *cloudAPIClient) DoRequest(method string, path string, body *bytes.Buffer, successCodes []int, retryLimit int, retryDelay int) (*http.Response, error) {
+	return clouddk.DoClientRequest(c.settings, method, path, body, successCodes, retryLimit, retryDelay)
+}
+
+// sftpFileUploader implements the FileUploader interface over SFTP.
+type sftpFileUploader struct{}
+
+// Upload writes a file to the storage server.
+func (u *sftpFileUploader) Upload(ns *NetworkStorage, filePath string, fileContents *bytes.Buffer) error {
+	return ns.CreateFile(nil, filePath, fileContents)
+}
+
+// sshRemoteExecutor implements the RemoteExecutor interface over SSH.
+type sshRemoteExecutor struct{}
+
+// Execute runs a shell command on the storage server and returns its combined output.
+func (e *sshRemoteExecutor) Execute(ns *NetworkStorage, command string) ([]byte, error) {
+	sshSession, err := ns.CreateSSHSession(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer sshSession.Close()
+
+	auditEvent("ssh-command", ns.ID, "", "%s", command)
+
+	return sshSession.CombinedOutput(command)
+}
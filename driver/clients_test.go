@@ -0,0 +1,229 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+// mockCloudClient implements the CloudClient interface using canned responses.
+type mockCloudClient struct {
+	handler func(method string, path string) (int, string)
+}
+
+// DoRequest returns the canned response for the given request.
+func (c *mockCloudClient) DoRequest(method string, path string, body *bytes.Buffer, successCodes []int, retryLimit int, retryDelay int) (*http.Response, error) {
+	code, responseBody := c.handler(method, path)
+
+	res := &http.Response{
+		StatusCode: code,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(responseBody)),
+	}
+
+	for _, v := range successCodes {
+		if code == v {
+			return res, nil
+		}
+	}
+
+	return res, fmt.Errorf("Unexpected status code %d for path '%s'", code, path)
+}
+
+// mockFileUploader implements the FileUploader interface by recording the uploaded files.
+type mockFileUploader struct {
+	files map[string]string
+}
+
+// Upload records an uploaded file.
+func (u *mockFileUploader) Upload(ns *NetworkStorage, filePath string, fileContents *bytes.Buffer) error {
+	if u.files == nil {
+		u.files = map[string]string{}
+	}
+
+	u.files[filePath] = fileContents.String()
+
+	return nil
+}
+
+// mockRemoteExecutor implements the RemoteExecutor interface by recording the executed commands and returning canned output.
+type mockRemoteExecutor struct {
+	commands []string
+	output   string
+	err      error
+}
+
+// Execute records an executed command and returns the canned output.
+func (e *mockRemoteExecutor) Execute(ns *NetworkStorage, command string) ([]byte, error) {
+	e.commands = append(e.commands, command)
+
+	return []byte(e.output), e.err
+}
+
+// newMockedDriver creates a driver which is backed by the given mock implementations.
+func newMockedDriver(t *testing.T, client CloudClient, executor RemoteExecutor, uploader FileUploader) *Driver {
+	d := newSanityDriver(t, "http://127.0.0.1:1")
+
+	d.CloudClient = client
+	d.FileUploader = uploader
+	d.RemoteExecutor = executor
+
+	return d
+}
+
+// newMockedServerBody creates a server object with both a public and a private network interface.
+func newMockedServerBody(id string, hostname string, privateIP string) clouddk.ServerBody {
+	return clouddk.ServerBody{
+		Identifier: id,
+		Hostname:   hostname,
+		Disks: clouddk.DiskListBody{
+			{
+				Identifier: "disk01",
+				Label:      nsDiskLabel,
+				Size:       clouddk.CustomInt(16),
+			},
+		},
+		NetworkInterfaces: clouddk.NetworkInterfaceListBody{
+			{
+				Identifier: "nic01",
+				IPAddresses: clouddk.IPAddressListBody{
+					{
+						Address: "185.10.10.10",
+					},
+				},
+			},
+			{
+				Identifier: "nic02",
+				IPAddresses: clouddk.IPAddressListBody{
+					{
+						Address: privateIP,
+					},
+				},
+			},
+		},
+	}
+}
+
+// TestLoadNetworkStorage verifies that a volume is initialized from the API response and prefers the private network.
+func TestLoadNetworkStorage(t *testing.T) {
+	server := newMockedServerBody("fake01", "k8s-network-storage-mock", "10.0.0.10")
+	serverJSON, _ := json.Marshal(server)
+
+	d := newMockedDriver(
+		t,
+		&mockCloudClient{handler: func(method string, path string) (int, string) {
+			if method == "GET" && path == "cloudservers/fake01" {
+				return 200, string(serverJSON)
+			}
+
+			return 404, `{"message":"Not Found","status":404}`
+		}},
+		&mockRemoteExecutor{},
+		&mockFileUploader{},
+	)
+
+	ns, _, err := loadNetworkStorage(d, "fake01")
+
+	if err != nil {
+		t.Fatalf("Failed to load the network storage - Error: %s", err.Error())
+	}
+
+	if ns.IP != "10.0.0.10" {
+		t.Errorf("The private network address is expected to be preferred (actual: %s)", ns.IP)
+	}
+
+	if ns.Size != 16 || ns.Hostname != "k8s-network-storage-mock" {
+		t.Errorf("Unexpected volume properties (size: %d - hostname: %s)", ns.Size, ns.Hostname)
+	}
+}
+
+// TestGetExportState verifies that the export state output of a storage server is parsed correctly.
+func TestGetExportState(t *testing.T) {
+	executor := &mockRemoteExecutor{
+		output: "/mnt/data\t10.0.0.2(rw,sync,no_subtree_check)\n---\n10.0.0.2\n",
+	}
+
+	d := newMockedDriver(t, &mockCloudClient{}, executor, &mockFileUploader{})
+	ns := &NetworkStorage{driver: d, ID: "fake01", IP: "10.0.0.10"}
+
+	exports, ipsetEntries, err := ns.GetExportState()
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve the export state - Error: %s", err.Error())
+	}
+
+	if len(exports) != 1 || exports[0] != "/mnt/data\t10.0.0.2(rw,sync,no_subtree_check)" {
+		t.Errorf("Unexpected exports (actual: %v)", exports)
+	}
+
+	if len(ipsetEntries) != 1 || ipsetEntries[0] != "10.0.0.2" {
+		t.Errorf("Unexpected ipset entries (actual: %v)", ipsetEntries)
+	}
+}
+
+// TestPublish verifies that publishing a volume uploads the node script and reloads the exports.
+func TestPublish(t *testing.T) {
+	node := newMockedServerBody("node01", "k8s-node-1", "10.0.0.9")
+	nodeListJSON, _ := json.Marshal(clouddk.ServerListBody{node})
+
+	executor := &mockRemoteExecutor{}
+	uploader := &mockFileUploader{}
+
+	d := newMockedDriver(
+		t,
+		&mockCloudClient{handler: func(method string, path string) (int, string) {
+			if method == "GET" && strings.HasPrefix(path, "cloudservers?hostname=") {
+				return 200, string(nodeListJSON)
+			}
+
+			return 404, `{"message":"Not Found","status":404}`
+		}},
+		executor,
+		uploader,
+	)
+
+	ns := &NetworkStorage{driver: d, ID: "fake01", IP: "10.0.0.10"}
+
+	publishContext, err := ns.Publish("k8s-node-1")
+
+	if err != nil {
+		t.Fatalf("Failed to publish the volume - Error: %s", err.Error())
+	}
+
+	if publishContext[pcDriverVersion] != DriverVersion {
+		t.Errorf("The publish context is expected to contain the driver version (actual: %s)", publishContext[pcDriverVersion])
+	}
+
+	script := uploader.files[fmt.Sprintf(nsFormatNodeNetworkScriptPath, "k8s-node-1")]
+
+	if !strings.Contains(script, "ipset add nodes 10.0.0.9") {
+		t.Errorf("The node script is expected to authorize the private network address (actual: %s)", script)
+	}
+
+	export := uploader.files[fmt.Sprintf(nsFormatNodeExportPath, "k8s-node-1")]
+
+	if export != "/mnt/data\t10.0.0.9(rw,sync,no_subtree_check)\n" {
+		t.Errorf("The export fragment is expected to contain the export entry for the node (actual: %s)", export)
+	}
+
+	if len(executor.commands) != 1 || !strings.Contains(executor.commands[0], "exportfs -o rw,sync,no_subtree_check 10.0.0.9:/mnt/data") {
+		t.Errorf("The export is expected to be applied without a full re-export (actual: %v)", executor.commands)
+	}
+
+	if nodeIDs := getPublishedNodeIDs(ns.ID); len(nodeIDs) != 1 || nodeIDs[0] != "k8s-node-1" {
+		t.Errorf("The node is expected to be recorded as published (actual: %v)", nodeIDs)
+	}
+
+	// Remove the global records to avoid leaking state into other tests.
+	removeExportRecord(ns.ID, "10.0.0.9")
+	removeAttachmentRecord(ns.ID, "k8s-node-1")
+}
@@ -7,7 +7,12 @@ package driver
 import (
 	"context"
 	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
@@ -15,7 +20,45 @@ import (
 )
 
 const (
+	parameterDiskScheduler    = "diskScheduler"
+	parameterExtraCIDRs       = "extraAllowedCidrs"
+	parameterFilesystem       = "filesystem"
+	parameterHA               = "ha"
+	parameterImportDataDisk   = "importDataDisk"
+	parameterNconnect         = "nconnect"
+	parameterNFSThreads       = "nfsThreads"
+	parameterNFSVersion       = "nfsVersion"
+	parameterPackageID        = "packageId"
+	parameterPath             = "path"
+	parameterProfile          = "profile"
+	parameterProtocol         = "protocol"
+	parameterReclaimDataDisk  = "reclaimDataDisk"
+	parameterRedundancy       = "redundancy"
+	parameterRetainSwap       = "retainSwap"
+	parameterServer           = "server"
+	parameterSnapshotInterval = "snapshotInterval"
+	parameterStaticIP         = "staticIP"
+	parameterSnapshotRetain   = "snapshotRetention"
+	parameterTCPSlotTable     = "tcpSlotTableEntries"
+	parameterTransport        = "transport"
+	parameterTuningProfile    = "tuningProfile"
+
+	reclaimDataDiskDelete = "delete"
+	reclaimDataDiskRetain = "retain"
+
+	secretPassword = "password"
+	secretUsername = "username"
+
+	vcExportPath    = "exportPath"
+	vcNFSLockdPort  = "nfsLockdPort"
+	vcNFSMountdPort = "nfsMountdPort"
+	vcNFSStatdPort  = "nfsStatdPort"
+	vcServerIP      = "serverIP"
+	vcServerName    = "serverName"
+	vcStatic        = "static"
+
 	volumePrefixBlockStorage   = "bs"
+	volumePrefixExternal       = "ext"
 	volumePrefixNetworkStorage = "ns"
 )
 
@@ -38,9 +81,219 @@ func (cs *ControllerServer) ControllerGetCapabilities(ctx context.Context, req *
 	}, nil
 }
 
-// ControllerExpandVolume expands the given volume.
+// ControllerExpandVolume expands the given volume. The function is idempotent.
 func (cs *ControllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "Volume expansion is not supported")
+	if req.VolumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "The volume ID must be provided")
+	} else if req.CapacityRange == nil {
+		return nil, status.Error(codes.InvalidArgument, "The capacity range must be provided")
+	}
+
+	defer endOperation(beginOperation("ControllerExpandVolume", req.VolumeId))
+
+	// Use the credentials from the storage class secrets, if any.
+	cs = cs.withSecrets(req.GetSecrets())
+
+	// Separate the concatenated volume type and ID.
+	volumeInfo := strings.Split(req.VolumeId, "-")
+
+	if len(volumeInfo) != 2 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid volume ID")
+	}
+
+	switch volumeInfo[0] {
+	case volumePrefixBlockStorage:
+		return nil, status.Error(codes.Unimplemented, "Block storage is not supported")
+	case volumePrefixExternal:
+		return nil, status.Error(codes.Unimplemented, "External volumes cannot be expanded")
+	case volumePrefixNetworkStorage:
+		size, err := parseCapacity(req.CapacityRange)
+
+		if err != nil {
+			return nil, status.Error(codes.OutOfRange, err.Error())
+		}
+
+		ns, notFound, err := loadNetworkStorage(cs.driver, volumeInfo[1])
+
+		if err != nil {
+			if notFound {
+				return nil, status.Error(codes.NotFound, "The volume does not exist")
+			}
+
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		// Serialize operations targeting the same volume.
+		lockVolume(ns.Hostname)
+		defer unlockVolume(ns.Hostname)
+
+		if size > ns.Size {
+			started := time.Now()
+			err = ns.ExpandDisk(size)
+
+			if err != nil {
+				notifyWebhook(cs.driver, "Failed to expand volume (server: %s - size: %d GB) - Reason: %s", ns.ID, size, err.Error())
+
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			ns.Size = size
+
+			notifyWebhook(cs.driver, "Expanded volume (server: %s - size: %d GB - duration: %s)", ns.ID, ns.Size, time.Since(started).Round(time.Second))
+
+			// Move the server to a package suited for the new capacity, if enabled. A failed
+			// upgrade is only logged since the additional capacity is already available.
+			if cs.driver.Configuration.ExpandRebootWindow > 0 {
+				cs.rightSizeServerPackage(ns)
+			}
+		}
+
+		return &csi.ControllerExpandVolumeResponse{
+			CapacityBytes:         int64(ns.Size * 1073741824),
+			NodeExpansionRequired: false,
+		}, nil
+	default:
+		return nil, status.Error(codes.InvalidArgument, "Invalid volume type")
+	}
+}
+
+// rightSizeServerPackage upgrades the package of a storage server in case the volume has grown
+// beyond what the current package can serve with reasonable performance. The upgrade reboots the
+// server and a warning is logged when the reboot exceeds the configured window.
+func (cs *ControllerServer) rightSizeServerPackage(ns *NetworkStorage) {
+	server, _, err := getServerByID(cs.driver, ns.ID)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Skipping the package upgrade due to API errors (id: %s)", ns.ID)
+
+		return
+	}
+
+	packageID, err := getPackageID(expansionMemoryRequirement(ns.Size), cs.driver.Configuration.ServerProcessors)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Skipping the package upgrade (id: %s) - Error: %s", ns.ID, err.Error())
+
+		return
+	}
+
+	// Determine the position of both packages in the ascending package list so the server is
+	// never downgraded.
+	currentIndex := -1
+	desiredIndex := -1
+
+	for i, id := range serverPackageIDs {
+		if id == server.Package.Identifier {
+			currentIndex = i
+		}
+
+		if id == *packageID {
+			desiredIndex = i
+		}
+	}
+
+	if desiredIndex <= currentIndex {
+		return
+	}
+
+	started := time.Now()
+	err = ns.UpgradePackage(*packageID)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to upgrade the server package (id: %s) - Error: %s", ns.ID, err.Error())
+
+		return
+	}
+
+	if elapsed := time.Since(started); elapsed > time.Duration(cs.driver.Configuration.ExpandRebootWindow)*time.Second {
+		log.Printf("WARNING: The package upgrade for volume '%s' exceeded the configured reboot window (elapsed: %s)", ns.ID, elapsed)
+	}
+}
+
+// ControllerGetVolume returns the current information about the given volume.
+func (cs *ControllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
+	if req.VolumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "The volume ID must be provided")
+	}
+
+	// Separate the concatenated volume type and ID.
+	volumeInfo := strings.Split(req.VolumeId, "-")
+
+	if len(volumeInfo) != 2 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid volume ID")
+	}
+
+	switch volumeInfo[0] {
+	case volumePrefixBlockStorage:
+		return nil, status.Error(codes.Unimplemented, "Block storage is not supported")
+	case volumePrefixExternal:
+		// External volumes are managed by the administrator and are assumed to be operational.
+		return &csi.ControllerGetVolumeResponse{
+			Volume: &csi.Volume{
+				VolumeId: req.VolumeId,
+			},
+			Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+				PublishedNodeIds: getPublishedNodeIDs(volumeInfo[1]),
+				VolumeCondition: &csi.VolumeCondition{
+					Abnormal: false,
+					Message:  "The volume is external",
+				},
+			},
+		}, nil
+	case volumePrefixNetworkStorage:
+		ns, notFound, err := loadNetworkStorage(cs.driver, volumeInfo[1])
+
+		if err != nil {
+			if notFound {
+				return nil, status.Error(codes.NotFound, "The specified volume does not exist")
+			}
+
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		condition := &csi.VolumeCondition{
+			Abnormal: false,
+			Message:  "The volume is operational",
+		}
+
+		// Surface the result of the most recent health probe, if available.
+		if health, ok := getServerHealth(ns.ID); ok && !health.Healthy {
+			condition = &csi.VolumeCondition{
+				Abnormal: true,
+				Message:  health.Message,
+			}
+		}
+
+		// Surface the health of the RAID1 array for mirrored volumes.
+		if ns.Redundancy == nsRedundancyMirror {
+			degraded, err := ns.CheckMirrorDegraded()
+
+			if err == nil && degraded {
+				condition = &csi.VolumeCondition{
+					Abnormal: true,
+					Message:  "The mirrored array is degraded",
+				}
+			}
+		}
+
+		return &csi.ControllerGetVolumeResponse{
+			Volume: &csi.Volume{
+				CapacityBytes: int64(ns.Size * 1073741824),
+				VolumeId:      req.VolumeId,
+			},
+			Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+				PublishedNodeIds: getPublishedNodeIDs(ns.ID),
+				VolumeCondition:  condition,
+			},
+		}, nil
+	default:
+		return nil, status.Error(codes.InvalidArgument, "Invalid volume type")
+	}
+}
+
+// ControllerModifyVolume changes the mutable parameters of the given volume.
+func (cs *ControllerServer) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "Volume modification is not supported")
 }
 
 // ControllerPublishVolume attaches the given volume to the node.
@@ -49,10 +302,13 @@ func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 		return nil, status.Error(codes.InvalidArgument, "The node ID must be provided")
 	} else if req.VolumeId == "" {
 		return nil, status.Error(codes.InvalidArgument, "The volume ID must be provided")
-	} else if req.Readonly {
-		return nil, status.Error(codes.InvalidArgument, "Publishing volumes as read-only is not supported")
 	}
 
+	defer endOperation(beginOperation("ControllerPublishVolume", req.VolumeId))
+
+	// Use the credentials from the storage class secrets, if any.
+	cs = cs.withSecrets(req.GetSecrets())
+
 	// Separate the concatenated volume type and ID and attempt to grant the node access to the volume.
 	volumeInfo := strings.Split(req.VolumeId, "-")
 
@@ -63,6 +319,15 @@ func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 	switch volumeInfo[0] {
 	case volumePrefixBlockStorage:
 		return nil, status.Error(codes.Unimplemented, "Block storage is not supported")
+	case volumePrefixExternal:
+		// External volumes reference an endpoint managed by the administrator, so there is no
+		// per-node access control to apply.
+		return &csi.ControllerPublishVolumeResponse{
+			PublishContext: map[string]string{
+				pcDriverVersion: DriverVersion,
+				pcReadonly:      strconv.FormatBool(req.Readonly),
+			},
+		}, nil
 	case volumePrefixNetworkStorage:
 		ns, notFound, err := loadNetworkStorage(cs.driver, volumeInfo[1])
 
@@ -74,14 +339,47 @@ func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 
-		err = ns.Publish(req.NodeId)
+		// Static volumes reference pre-provisioned servers whose exports are managed by the
+		// administrator, so skip the per-node access control steps.
+		if req.VolumeContext[vcStatic] == "true" {
+			return &csi.ControllerPublishVolumeResponse{
+				PublishContext: map[string]string{
+					pcDriverVersion: DriverVersion,
+					pcReadonly:      strconv.FormatBool(req.Readonly),
+				},
+			}, nil
+		}
+
+		// Restore the server to a suitable package in case it was downsized while idle.
+		if server, _, err := getServerByID(cs.driver, ns.ID); err == nil && strings.HasPrefix(server.Label, nsLabelScaledDown) {
+			err = ns.RestorePackage()
+
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		// Refuse to publish the volume while the firewall baseline is missing, since granting
+		// access would otherwise expose the NFS ports to the internet.
+		err = ns.VerifyFirewall()
+
+		if err != nil {
+			notifyWebhook(cs.driver, "Refused to publish volume (server: %s) - Reason: %s", ns.ID, err.Error())
+
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+
+		publishContext, err := ns.Publish(req.NodeId)
 
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 
+		// Instruct the node plugin to mount the volume as read-only, if requested.
+		publishContext[pcReadonly] = strconv.FormatBool(req.Readonly)
+
 		return &csi.ControllerPublishVolumeResponse{
-			PublishContext: map[string]string{},
+			PublishContext: publishContext,
 		}, nil
 	default:
 		return nil, status.Error(codes.InvalidArgument, "Invalid volume type")
@@ -96,6 +394,11 @@ func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *
 		return nil, status.Error(codes.InvalidArgument, "The volume ID must be provided")
 	}
 
+	defer endOperation(beginOperation("ControllerUnpublishVolume", req.VolumeId))
+
+	// Use the credentials from the storage class secrets, if any.
+	cs = cs.withSecrets(req.GetSecrets())
+
 	// Separate the concatenated volume type and ID and attempt to revoke the node's access to the volume.
 	volumeInfo := strings.Split(req.VolumeId, "-")
 
@@ -106,6 +409,10 @@ func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *
 	switch volumeInfo[0] {
 	case volumePrefixBlockStorage:
 		return nil, status.Error(codes.Unimplemented, "Block storage is not supported")
+	case volumePrefixExternal:
+		// External volumes reference an endpoint managed by the administrator, so there is no
+		// per-node access to revoke.
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	case volumePrefixNetworkStorage:
 		ns, notFound, err := loadNetworkStorage(cs.driver, volumeInfo[1])
 
@@ -144,6 +451,11 @@ func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		return nil, status.Error(codes.InvalidArgument, "Volume sources are not supported")
 	}
 
+	defer endOperation(beginOperation("CreateVolume", req.Name))
+
+	// Use the credentials from the storage class secrets, if any.
+	cs = cs.withSecrets(req.GetSecrets())
+
 	createNetworkStorage := false
 
 	for _, cap := range req.VolumeCapabilities {
@@ -169,12 +481,43 @@ func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		}
 	}
 
+	// Volumes using the iSCSI transport are served by a storage server as well, but the LUN is
+	// backed by a single filesystem which cannot be safely written from multiple nodes.
+	if req.Parameters[parameterTransport] == nsTransportISCSI {
+		for _, cap := range req.VolumeCapabilities {
+			switch cap.AccessMode.Mode {
+			case csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER:
+				return nil, status.Error(codes.InvalidArgument, "The iSCSI transport does not support multiple node writers")
+			}
+		}
+
+		createNetworkStorage = true
+	}
+
 	size, err := parseCapacity(req.CapacityRange)
 
 	if err != nil {
 		return nil, status.Error(codes.OutOfRange, err.Error())
 	}
 
+	// Pass through an external NFS endpoint instead of provisioning storage, if requested.
+	if req.Parameters[parameterServer] != "" {
+		return cs.CreateVolumeExternal(ctx, req, size)
+	}
+
+	// Reject the request for a later retry in case the provisioning concurrency limit has been reached.
+	if cs.driver.provisionSemaphore != nil {
+		select {
+		case cs.driver.provisionSemaphore <- struct{}{}:
+			defer func() {
+				<-cs.driver.provisionSemaphore
+			}()
+		default:
+			return nil, status.Error(codes.Aborted, "The maximum number of concurrent provisioning operations has been reached")
+		}
+	}
+
 	// Create a new volume of the specified type.
 	if createNetworkStorage {
 		return cs.CreateVolumeNetworkStorage(ctx, req, size)
@@ -183,6 +526,39 @@ func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 	return cs.CreateVolumeBlockStorage(ctx, req, size)
 }
 
+// CreateVolumeExternal creates a volume backed by an external NFS endpoint instead of
+// provisioning a storage server. The function is idempotent.
+func (cs *ControllerServer) CreateVolumeExternal(ctx context.Context, req *csi.CreateVolumeRequest, size int) (*csi.CreateVolumeResponse, error) {
+	path := req.Parameters[parameterPath]
+
+	if !strings.HasPrefix(path, "/") {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid export path '%s'", path))
+	}
+
+	nfsVersion := req.Parameters[parameterNFSVersion]
+
+	switch nfsVersion {
+	case "":
+		nfsVersion = nsNFSVersion41
+	case nsNFSVersion3, nsNFSVersion41, nsNFSVersion42:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported NFS version '%s'", nfsVersion))
+	}
+
+	// Encode the volume name as hexadecimal since volume ids cannot contain dashes.
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			CapacityBytes: int64(size * 1073741824),
+			VolumeId:      fmt.Sprintf("%s-%x", volumePrefixExternal, req.Name),
+			VolumeContext: map[string]string{
+				parameterNFSVersion: nfsVersion,
+				parameterPath:       path,
+				parameterServer:     req.Parameters[parameterServer],
+			},
+		},
+	}, nil
+}
+
 // CreateVolumeBlockStorage creates new block storage from the given request. The function is idempotent.
 func (cs *ControllerServer) CreateVolumeBlockStorage(ctx context.Context, req *csi.CreateVolumeRequest, size int) (*csi.CreateVolumeResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "Block storage is not supported")
@@ -190,20 +566,382 @@ func (cs *ControllerServer) CreateVolumeBlockStorage(ctx context.Context, req *c
 
 // CreateVolumeNetworkStorage creates new network storage from the given request. The function is idempotent.
 func (cs *ControllerServer) CreateVolumeNetworkStorage(ctx context.Context, req *csi.CreateVolumeRequest, size int) (*csi.CreateVolumeResponse, error) {
-	ns, exists, err := createNetworkStorage(cs.driver, req.Name, size)
+	filesystem := req.Parameters[parameterFilesystem]
+
+	switch filesystem {
+	case "":
+		filesystem = nsFilesystemEXT4
+	case nsFilesystemEXT4, nsFilesystemZFS:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported filesystem '%s'", filesystem))
+	}
+
+	nfsVersion := req.Parameters[parameterNFSVersion]
+
+	switch nfsVersion {
+	case "":
+		nfsVersion = nsNFSVersion41
+	case nsNFSVersion3, nsNFSVersion41, nsNFSVersion42:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported NFS version '%s'", nfsVersion))
+	}
+
+	diskScheduler := req.Parameters[parameterDiskScheduler]
+
+	switch diskScheduler {
+	case "", "bfq", "cfq", "deadline", "kyber", "mq-deadline", "none", "noop":
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported disk scheduler '%s'", diskScheduler))
+	}
+
+	nfsThreads := 0
+
+	if req.Parameters[parameterNFSThreads] != "" {
+		i, err := strconv.Atoi(req.Parameters[parameterNFSThreads])
+
+		if err != nil || i < 1 || i > 256 {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported NFS thread count '%s'", req.Parameters[parameterNFSThreads]))
+		}
+
+		nfsThreads = i
+	}
+
+	tcpSlotTableEntries := 0
+
+	if req.Parameters[parameterTCPSlotTable] != "" {
+		i, err := strconv.Atoi(req.Parameters[parameterTCPSlotTable])
+
+		if err != nil || i < 1 || i > 65536 {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported TCP slot table size '%s'", req.Parameters[parameterTCPSlotTable]))
+		}
+
+		tcpSlotTableEntries = i
+	}
+
+	// Extra networks such as a VPN range or a second cluster can be granted access to the volume
+	// in a controlled manner on top of the per-node grants and the cluster networks.
+	extraCIDRs := []string{}
+
+	if req.Parameters[parameterExtraCIDRs] != "" {
+		for _, cidr := range strings.Split(req.Parameters[parameterExtraCIDRs], ",") {
+			cidr = strings.TrimSpace(cidr)
+
+			_, _, err := net.ParseCIDR(cidr)
+
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported network '%s'", cidr))
+			}
+
+			extraCIDRs = append(extraCIDRs, cidr)
+		}
+	}
+
+	// A pre-reserved static address keeps the NFS endpoint stable across server rebuilds and
+	// migrations. The address must be reserved with Cloud.dk and routable to the server.
+	staticIP := req.Parameters[parameterStaticIP]
+
+	if staticIP != "" && net.ParseIP(staticIP) == nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported static address '%s'", staticIP))
+	}
+
+	// Small packages can run out of memory under heavy NFS load without swap, so both the swap
+	// handling and the sysctl profile are selectable per storage class.
+	retainSwap := false
+
+	switch req.Parameters[parameterRetainSwap] {
+	case "", "false":
+	case "true":
+		retainSwap = true
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported swap retention setting '%s'", req.Parameters[parameterRetainSwap]))
+	}
+
+	tuningProfile := req.Parameters[parameterTuningProfile]
+
+	switch tuningProfile {
+	case "", nsTuningProfileConservative, nsTuningProfileDefault, nsTuningProfilePerformance:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported tuning profile '%s'", tuningProfile))
+	}
+
+	nconnect := req.Parameters[parameterNconnect]
+
+	if nconnect != "" {
+		connections, err := strconv.Atoi(nconnect)
+
+		if err != nil || connections < 1 || connections > 16 {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported nconnect value '%s'", nconnect))
+		}
+	}
+
+	// Take automatic snapshots at a fixed interval in hours, if requested. The retention defines
+	// how many of the scheduled snapshots are kept before the oldest ones are pruned.
+	snapshotInterval := 0
+	snapshotRetention := 7
+
+	if req.Parameters[parameterSnapshotInterval] != "" {
+		i, err := strconv.Atoi(req.Parameters[parameterSnapshotInterval])
+
+		if err != nil || i < 1 || i > 8760 {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported snapshot interval '%s'", req.Parameters[parameterSnapshotInterval]))
+		}
+
+		snapshotInterval = i
+	}
+
+	if req.Parameters[parameterSnapshotRetain] != "" {
+		i, err := strconv.Atoi(req.Parameters[parameterSnapshotRetain])
+
+		if err != nil || i < 1 || i > 365 {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported snapshot retention '%s'", req.Parameters[parameterSnapshotRetain]))
+		}
+
+		snapshotRetention = i
+	}
+
+	profile := req.Parameters[parameterProfile]
+
+	switch profile {
+	case "", mountProfileLatency, mountProfileResilient, mountProfileThroughput:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported mount profile '%s'", profile))
+	}
+
+	protocol := req.Parameters[parameterProtocol]
+
+	switch protocol {
+	case "":
+		protocol = nsProtocolNFS
+	case nsProtocolNFS, nsProtocolSMB:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported protocol '%s'", protocol))
+	}
+
+	// The SMB service is configured with the credentials which the nodes later present when mounting.
+	if protocol == nsProtocolSMB && (req.Secrets[secretUsername] == "" || req.Secrets[secretPassword] == "") {
+		return nil, status.Error(codes.InvalidArgument, "The SMB protocol requires the username and password secrets")
+	}
+
+	transport := req.Parameters[parameterTransport]
+
+	switch transport {
+	case "":
+		transport = nsTransportPlain
+	case nsTransportISCSI, nsTransportPlain, nsTransportTLS:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported transport '%s'", transport))
+	}
+
+	// The TLS channel and the iSCSI target both front the NFS service.
+	if protocol == nsProtocolSMB && transport != nsTransportPlain {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("The SMB protocol cannot be combined with the '%s' transport", transport))
+	}
+
+	ha := false
+
+	switch req.Parameters[parameterHA] {
+	case "", "false":
+	case "true":
+		ha = true
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported high availability setting '%s'", req.Parameters[parameterHA]))
+	}
+
+	// The failover machinery replicates the exported directory which no longer exists once the
+	// data disk backs an iSCSI LUN.
+	if ha && transport == nsTransportISCSI {
+		return nil, status.Error(codes.InvalidArgument, "The iSCSI transport cannot be combined with high availability")
+	}
+
+	reclaimDataDisk := req.Parameters[parameterReclaimDataDisk]
+
+	switch reclaimDataDisk {
+	case "", reclaimDataDiskDelete, reclaimDataDiskRetain:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported data disk reclaim policy '%s'", reclaimDataDisk))
+	}
+
+	redundancy := req.Parameters[parameterRedundancy]
+
+	switch redundancy {
+	case "":
+		redundancy = nsRedundancyNone
+	case nsRedundancyMirror, nsRedundancyNone:
+	default:
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Unsupported redundancy '%s'", redundancy))
+	}
+
+	// The mirrored array assembles the data disks into a new device while the iSCSI target
+	// exports the first data disk directly.
+	if redundancy == nsRedundancyMirror && transport == nsTransportISCSI {
+		return nil, status.Error(codes.InvalidArgument, "The iSCSI transport cannot be combined with mirrored redundancy")
+	}
+
+	// Render the configured hostname template so operators can identify the volumes in the
+	// Cloud.dk panel by their cluster, namespace or claim instead of the generated volume name.
+	name, err := renderVolumeHostname(cs.driver, req.Name, req.Parameters[parameterPVCNamespace], req.Parameters[parameterPVCName])
 
 	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	config := &NetworkStorageConfig{
+		DiskScheduler:       diskScheduler,
+		ExtraAllowedCIDRs:   extraCIDRs,
+		Filesystem:          filesystem,
+		HA:                  ha,
+		Name:                name,
+		NFSThreads:          nfsThreads,
+		NFSVersion:          nfsVersion,
+		PackageID:           req.Parameters[parameterPackageID],
+		Protocol:            protocol,
+		PVCName:             req.Parameters[parameterPVCName],
+		PVCNamespace:        req.Parameters[parameterPVCNamespace],
+		PVName:              req.Name,
+		Redundancy:          redundancy,
+		RetainDataDisk:      (reclaimDataDisk == reclaimDataDiskRetain),
+		RetainSwap:          retainSwap,
+		Size:                size,
+		SMBPassword:         req.Secrets[secretPassword],
+		SMBUsername:         req.Secrets[secretUsername],
+		StaticIP:            staticIP,
+		TCPSlotTableEntries: tcpSlotTableEntries,
+		Transport:           transport,
+		TuningProfile:       tuningProfile,
+	}
+
+	// Attach the provisioning events to the PVC the request originates from, if known.
+	registerEventTarget(name, req.Parameters[parameterPVCNamespace], req.Parameters[parameterPVCName])
+	defer unregisterEventTarget(name)
+
+	started := time.Now()
+
+	var ns *NetworkStorage
+	var volumeExists bool
+
+	// Import a retained data disk instead of provisioning a new server, if requested.
+	if tag := req.Parameters[parameterImportDataDisk]; tag != "" {
+		var notFound bool
+
+		ns, notFound, err = importNetworkStorage(cs.driver, config, tag)
+
+		if err != nil {
+			if notFound {
+				return nil, status.Error(codes.NotFound, fmt.Sprintf("No retained data disk exists for tag '%s'", tag))
+			}
+
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	} else {
+		// Refuse to provision past the configured budget caps. Highly available volumes consume
+		// two servers and mirrored volumes consume twice the capacity.
+		serversNeeded := 1
+		capacityNeeded := size
+
+		if ha {
+			serversNeeded = 2
+			capacityNeeded *= 2
+		}
+
+		if redundancy == nsRedundancyMirror {
+			capacityNeeded *= 2
+		}
+
+		err = checkBudget(cs.driver, serversNeeded, capacityNeeded)
+
+		if err != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		// Refuse to provision past the configured namespace quota, if any.
+		err = checkNamespaceQuota(cs.driver, req.Parameters[parameterPVCNamespace], capacityNeeded)
+
+		if err != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		var exists bool
+
+		ns, exists, err = createNetworkStorage(cs.driver, config)
+
+		if err != nil {
+			emitEvent(cs.driver, req.Name, eventTypeWarning, "ProvisioningFailed", "Failed to provision the volume - Reason: %s", err.Error())
+			notifyWebhook(cs.driver, "Failed to create volume '%s' (size: %d GB) - Reason: %s", req.Name, size, err.Error())
+
+			if exists {
+				return nil, status.Error(codes.AlreadyExists, "The volume already exists")
+			}
+
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		// Bind the existing volume with its actual metadata in case the server already exists,
+		// which allows interrupted or restarted provisioning to recover. A smaller existing volume
+		// is incompatible with the request and must be reported as a conflict.
 		if exists {
-			return nil, status.Error(codes.AlreadyExists, "The volume already exists")
+			if ns.Size < size {
+				return nil, status.Error(codes.AlreadyExists, fmt.Sprintf("The volume already exists with a smaller capacity of %d GB", ns.Size))
+			}
+
+			// The protocol settings are not recoverable from the server metadata, but the request
+			// parameters are identical for repeated calls with the same volume name.
+			ns.NFSVersion = nfsVersion
+			ns.Transport = transport
+
+			volumeExists = true
 		}
+	}
 
-		return nil, status.Error(codes.Internal, err.Error())
+	if !volumeExists {
+		notifyWebhook(cs.driver, "Created volume '%s' (server: %s - size: %d GB - duration: %s)", req.Name, ns.ID, ns.Size, time.Since(started).Round(time.Second))
+	}
+
+	recordNamespaceUsage(ns.ID, req.Parameters[parameterPVCNamespace], ns.Size)
+
+	// Register the periodic snapshot schedule, if requested.
+	if snapshotInterval > 0 {
+		scheduleSnapshots(
+			cs.driver,
+			ns.ID,
+			fmt.Sprintf("%s-%s", volumePrefixNetworkStorage, ns.ID),
+			time.Duration(snapshotInterval)*time.Hour,
+			snapshotRetention,
+		)
+	}
+
+	// Persist the address and export path of the storage server so the node plugin can stage the
+	// volume without a cloud API lookup.
+	exportPath := ns.ExportPath
+
+	if exportPath == "" {
+		exportPath = "/mnt/data"
+	}
+
+	// Persist the DNS name of the server in case a zone is configured, so the nodes mount by
+	// name and survive address changes during rebuilds and migrations.
+	serverName := ""
+
+	if cs.driver.Configuration.DNSZone != "" {
+		serverName = fmt.Sprintf("%s.%s", ns.Hostname, strings.TrimSuffix(cs.driver.Configuration.DNSZone, "."))
 	}
 
 	return &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			CapacityBytes: int64(ns.Size * 1073741824),
 			VolumeId:      fmt.Sprintf("%s-%s", volumePrefixNetworkStorage, ns.ID),
+			VolumeContext: map[string]string{
+				parameterNconnect:   nconnect,
+				parameterNFSVersion: ns.NFSVersion,
+				parameterProfile:    profile,
+				parameterProtocol:   protocol,
+				parameterTransport:  ns.Transport,
+				vcExportPath:        exportPath,
+				vcNFSLockdPort:      strconv.Itoa(cs.driver.Configuration.NFSLockdPort),
+				vcNFSMountdPort:     strconv.Itoa(cs.driver.Configuration.NFSMountdPort),
+				vcNFSStatdPort:      strconv.Itoa(cs.driver.Configuration.NFSStatdPort),
+				vcServerIP:          ns.IP,
+				vcServerName:        serverName,
+			},
 		},
 	}, nil
 }
@@ -219,6 +957,11 @@ func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 		return nil, status.Error(codes.InvalidArgument, "The volume ID must be provided")
 	}
 
+	defer endOperation(beginOperation("DeleteVolume", req.VolumeId))
+
+	// Use the credentials from the storage class secrets, if any.
+	cs = cs.withSecrets(req.GetSecrets())
+
 	// Separate the concatenated volume type and ID and attempt to delete the volume.
 	volumeInfo := strings.Split(req.VolumeId, "-")
 
@@ -229,6 +972,9 @@ func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 	switch volumeInfo[0] {
 	case volumePrefixBlockStorage:
 		return cs.DeleteVolumeBlockStorage(ctx, req, volumeInfo[1])
+	case volumePrefixExternal:
+		// External volumes do not own any provisioned resources.
+		return &csi.DeleteVolumeResponse{}, nil
 	case volumePrefixNetworkStorage:
 		return cs.DeleteVolumeNetworkStorage(ctx, req, volumeInfo[1])
 	default:
@@ -253,12 +999,80 @@ func (cs *ControllerServer) DeleteVolumeNetworkStorage(ctx context.Context, req
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	// The volume is already scheduled for deletion in case the server carries the pending
+	// deletion hostname prefix, so simply let the reaper remove it once the grace period expires.
+	if strings.HasPrefix(ns.Hostname, fmt.Sprintf(nsFormatPendingHostname, "")) {
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+
+	// The volume has already been retained in case the server carries the retained hostname prefix.
+	if strings.HasPrefix(ns.Hostname, fmt.Sprintf(nsFormatRetainedHostname, "")) {
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+
+	// Refuse to delete servers which were not provisioned by this driver. A malformed volume
+	// handle could otherwise resolve to an unrelated server.
+	if !strings.HasPrefix(ns.Hostname, fmt.Sprintf(nsFormatHostname, "")) {
+		return nil, status.Errorf(codes.FailedPrecondition, "The server '%s' is not a storage server (hostname: %s)", ns.ID, ns.Hostname)
+	}
+
+	// Serialize operations targeting the same volume name and verify the server identity before
+	// deleting it in order to avoid removing a server which was recreated under the same hostname.
+	lockVolume(ns.Hostname)
+	defer unlockVolume(ns.Hostname)
+
+	err = ns.VerifyIdentity()
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Retain the server holding the data disk instead of deleting it in case the volume was
+	// created with the retain-data-disk reclaim policy.
+	server, _, err := getServerByID(cs.driver, ns.ID)
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if strings.HasPrefix(server.Label, nsLabelRetainDataDisk) {
+		err = ns.Retain()
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+
+	// Schedule the server for deletion instead of deleting it immediately in case a grace
+	// period is configured, which allows accidental deletions to be recovered.
+	if cs.driver.Configuration.DeletionGracePeriod > 0 {
+		err = ns.MarkPendingDeletion(cs.driver.Configuration.DeletionGracePeriod)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		notifyWebhook(cs.driver, "Scheduled volume for deletion (server: %s - size: %d GB)", ns.ID, ns.Size)
+
+		releaseNamespaceUsage(ns.ID)
+		unscheduleSnapshots(ns.ID)
+
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+
 	err = ns.Delete()
 
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	notifyWebhook(cs.driver, "Deleted volume (server: %s - size: %d GB)", ns.ID, ns.Size)
+
+	releaseNamespaceUsage(ns.ID)
+	unscheduleSnapshots(ns.ID)
+
 	return &csi.DeleteVolumeResponse{}, nil
 }
 
@@ -275,7 +1089,100 @@ func (cs *ControllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnap
 
 // ListVolumes returns a list of all requested volumes.
 func (cs *ControllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "Volume listings are not supported")
+	serverList, err := listServers(cs.driver, "")
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Collect the storage servers in a stable order to support consistent pagination.
+	hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+	entries := []*csi.ListVolumesResponse_Entry{}
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, hostnamePrefix) {
+			continue
+		}
+
+		// Replica servers belong to a highly available volume rather than being volumes themselves.
+		if strings.HasSuffix(v.Hostname, nsReplicaSuffix) {
+			continue
+		}
+
+		size := 0
+
+		for _, disk := range v.Disks {
+			if disk.Label == nsDiskLabel {
+				size += int(disk.Size)
+			}
+		}
+
+		// Surface the result of the most recent health probe, if available.
+		condition := &csi.VolumeCondition{
+			Abnormal: false,
+			Message:  "The volume is operational",
+		}
+
+		if health, ok := getServerHealth(v.Identifier); ok && !health.Healthy {
+			condition = &csi.VolumeCondition{
+				Abnormal: true,
+				Message:  health.Message,
+			}
+		}
+
+		// Surface the owning workload recorded in the server label, if available.
+		var volumeContext map[string]string
+
+		if pvName, pvcNamespace, pvcName, ok := parseOwnerLabel(v.Label); ok {
+			volumeContext = map[string]string{
+				"pvcName":      pvcName,
+				"pvcNamespace": pvcNamespace,
+				"pvName":       pvName,
+			}
+		}
+
+		entries = append(entries, &csi.ListVolumesResponse_Entry{
+			Volume: &csi.Volume{
+				CapacityBytes: int64(size * 1073741824),
+				VolumeId:      fmt.Sprintf("%s-%s", volumePrefixNetworkStorage, v.Identifier),
+				VolumeContext: volumeContext,
+			},
+			Status: &csi.ListVolumesResponse_VolumeStatus{
+				PublishedNodeIds: getPublishedNodeIDs(v.Identifier),
+				VolumeCondition:  condition,
+			},
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Volume.VolumeId < entries[j].Volume.VolumeId
+	})
+
+	// Apply the requested pagination using the entry index as the token.
+	startingIndex := 0
+
+	if req.StartingToken != "" {
+		i, err := strconv.Atoi(req.StartingToken)
+
+		if err != nil || i < 0 || i > len(entries) {
+			return nil, status.Error(codes.Aborted, "Invalid starting token")
+		}
+
+		startingIndex = i
+	}
+
+	entries = entries[startingIndex:]
+	nextToken := ""
+
+	if req.MaxEntries > 0 && int(req.MaxEntries) < len(entries) {
+		entries = entries[:req.MaxEntries]
+		nextToken = strconv.Itoa(startingIndex + int(req.MaxEntries))
+	}
+
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: nextToken,
+	}, nil
 }
 
 // ValidateVolumeCapabilities checks whether the volume capabilities requested are supported.
@@ -305,6 +1212,14 @@ func (cs *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 				},
 			},
 		}
+	case volumePrefixExternal:
+		supportedCaps = []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+		}
 	case volumePrefixNetworkStorage:
 		_, notFound, err := loadNetworkStorage(cs.driver, volumeInfo[1])
 
@@ -0,0 +1,105 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
+	"google.golang.org/grpc"
+)
+
+var (
+	// correlationIDs maps goroutine ids to the correlation id of the CSI call they serve. The
+	// driver internals do not thread a context through, so the id is looked up by goroutine
+	// instead. Goroutines spawned inside a call do not inherit the id.
+	correlationIDs      = map[uint64]string{}
+	correlationIDsMutex = sync.Mutex{}
+)
+
+// newCorrelationID generates a short random identifier for correlating the log entries of a
+// single CSI call.
+func newCorrelationID() string {
+	b := make([]byte, 4)
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "00000000"
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// goroutineID parses the id of the calling goroutine from its stack header.
+func goroutineID() uint64 {
+	buffer := make([]byte, 64)
+	buffer = buffer[:runtime.Stack(buffer, false)]
+
+	// The header has the form "goroutine 123 [running]:".
+	fields := bytes.Fields(buffer)
+
+	if len(fields) < 2 {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(string(fields[1]), 10, 64)
+
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// setCorrelationID associates the calling goroutine with the given correlation id.
+func setCorrelationID(id string) {
+	correlationIDsMutex.Lock()
+	defer correlationIDsMutex.Unlock()
+
+	correlationIDs[goroutineID()] = id
+}
+
+// clearCorrelationID removes the correlation id of the calling goroutine.
+func clearCorrelationID() {
+	correlationIDsMutex.Lock()
+	defer correlationIDsMutex.Unlock()
+
+	delete(correlationIDs, goroutineID())
+}
+
+// getCorrelationID returns the correlation id of the calling goroutine, if any.
+func getCorrelationID() string {
+	correlationIDsMutex.Lock()
+	defer correlationIDsMutex.Unlock()
+
+	return correlationIDs[goroutineID()]
+}
+
+// correlateGRPC assigns a correlation id to each gRPC call and logs the call under that id. The
+// id is included in all debug messages written while the call is being handled, which allows the
+// interleaved output of concurrent operations to be followed.
+func correlateGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := newCorrelationID()
+
+	setCorrelationID(id)
+	defer clearCorrelationID()
+
+	log.Printf("(%s) GRPC call: %s - Request: %s", id, info.FullMethod, protosanitizer.StripSecrets(req))
+
+	res, err := handler(ctx, req)
+
+	if err != nil {
+		log.Printf("(%s) GRPC error: %s - Error: %s", id, info.FullMethod, err.Error())
+	}
+
+	return res, err
+}
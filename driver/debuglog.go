@@ -0,0 +1,72 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// outputTailLines defines the number of output lines to attach to the errors for failed
+	// provisioning operations.
+	outputTailLines = 20
+)
+
+// outputTail returns the last lines of a command output with control characters stripped, making
+// the output safe to attach to an error which is surfaced in events and API responses.
+func outputTail(output []byte, maxLines int) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+
+		if r < 32 || r == 127 {
+			return -1
+		}
+
+		return r
+	}, string(output))
+
+	lines := strings.Split(strings.TrimSpace(sanitized), "\n")
+
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// storeDebugLog writes the full output of a failed operation to the configured debug log
+// directory. The logs are only stored in case a directory is configured and the function never
+// fails as the logs are strictly diagnostic.
+func storeDebugLog(d *Driver, name string, output []byte) {
+	if d.Configuration.DebugLogDirectory == "" {
+		return
+	}
+
+	err := os.MkdirAll(d.Configuration.DebugLogDirectory, 0700)
+
+	if err != nil {
+		debugCloudAction(rtCommon, "Failed to create the debug log directory - Error: %s", err.Error())
+
+		return
+	}
+
+	filePath := filepath.Join(
+		d.Configuration.DebugLogDirectory,
+		fmt.Sprintf("%s-%s.log", name, time.Now().UTC().Format("20060102-150405")),
+	)
+
+	err = ioutil.WriteFile(filePath, output, 0600)
+
+	if err != nil {
+		debugCloudAction(rtCommon, "Failed to write the debug log file '%s' - Error: %s", filePath, err.Error())
+	}
+}
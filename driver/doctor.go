@@ -0,0 +1,143 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// RunDoctor validates the configuration and the state of every storage server and prints a per
+// volume report. The checks mirror the conditions behind the most common support issues: broken
+// credentials, unreachable SSH, missing exports, a missing firewall baseline and unmounted data
+// disks.
+func (d *Driver) RunDoctor() error {
+	// Verify the API credentials before anything else as all other checks depend on them.
+	_, err := d.CloudClient.DoRequest(
+		"GET",
+		"cloudservers",
+		new(bytes.Buffer),
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		fmt.Println("[FAIL] API credentials")
+
+		return err
+	}
+
+	fmt.Println("[ OK ] API credentials")
+
+	servers, err := d.listManagedServers()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checking %d storage servers\n", len(servers))
+
+	unhealthy := 0
+
+	for _, server := range servers {
+		fmt.Printf("\n%s (id: %s - state: %s)\n", server.Hostname, server.Identifier, volumeServerState(&server))
+
+		if !bool(server.Booted) {
+			fmt.Println("[FAIL] The server is not booted")
+
+			unhealthy++
+
+			continue
+		}
+
+		ip := getServerPrivateIP(&server)
+
+		if ip == "" && len(server.NetworkInterfaces) > 0 && len(server.NetworkInterfaces[0].IPAddresses) > 0 {
+			ip = server.NetworkInterfaces[0].IPAddresses[0].Address
+		}
+
+		if ip == "" {
+			fmt.Println("[FAIL] The server has no network address")
+
+			unhealthy++
+
+			continue
+		}
+
+		ns := &NetworkStorage{
+			driver:   d,
+			Hostname: server.Hostname,
+			ID:       server.Identifier,
+			IP:       ip,
+		}
+
+		if !d.doctorCheckServer(ns) {
+			unhealthy++
+		}
+	}
+
+	if unhealthy > 0 {
+		return fmt.Errorf("%d of %d storage servers reported problems", unhealthy, len(servers))
+	}
+
+	fmt.Printf("\nAll %d storage servers are healthy\n", len(servers))
+
+	return nil
+}
+
+// doctorCheckServer runs the connectivity and integrity checks against a single storage server and
+// prints the results.
+func (d *Driver) doctorCheckServer(ns *NetworkStorage) (healthy bool) {
+	healthy = true
+
+	// SSH connectivity.
+	_, err := d.RemoteExecutor.Execute(ns, "true")
+
+	if err != nil {
+		fmt.Printf("[FAIL] SSH connectivity - Error: %s\n", err.Error())
+
+		return false
+	}
+
+	fmt.Println("[ OK ] SSH connectivity")
+
+	// Data disk mount state. iSCSI volumes expose the disk as a block backstore instead of a
+	// mounted filesystem.
+	output, err := d.RemoteExecutor.Execute(ns, "mountpoint -q /mnt/data || targetcli ls /backstores/block/data > /dev/null 2>&1")
+
+	if err != nil {
+		fmt.Printf("[FAIL] Data disk mount - Output: %s\n", strings.TrimSpace(string(output)))
+
+		healthy = false
+	} else {
+		fmt.Println("[ OK ] Data disk mount")
+	}
+
+	// NFS export integrity. Servers exposing an iSCSI target do not publish NFS exports.
+	output, err = d.RemoteExecutor.Execute(ns, "(pidof nfsd > /dev/null && [ -n \"$(exportfs)\" ]) || targetcli ls /backstores/block/data > /dev/null 2>&1")
+
+	if err != nil {
+		fmt.Printf("[FAIL] NFS exports - Output: %s\n", strings.TrimSpace(string(output)))
+
+		healthy = false
+	} else {
+		fmt.Println("[ OK ] NFS exports")
+	}
+
+	// Firewall baseline.
+	err = ns.VerifyFirewall()
+
+	if err != nil {
+		fmt.Printf("[FAIL] Firewall baseline - Error: %s\n", err.Error())
+
+		healthy = false
+	} else {
+		fmt.Println("[ OK ] Firewall baseline")
+	}
+
+	return healthy
+}
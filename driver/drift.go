@@ -0,0 +1,344 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// exportRecords tracks the desired export state for each volume published through this controller.
+	exportRecords      = map[string]map[string]bool{}
+	exportRecordsMutex = sync.Mutex{}
+
+	// driftDetectedTotal counts the number of drift detections since the driver started.
+	driftDetectedTotal uint64
+
+	// driftRepairedTotal counts the number of addresses corrected by drift repair since the driver started.
+	driftRepairedTotal uint64
+)
+
+// recordExport registers an address as part of the desired export state for a volume.
+func recordExport(id string, accessIP string) {
+	exportRecordsMutex.Lock()
+	defer exportRecordsMutex.Unlock()
+
+	if exportRecords[id] == nil {
+		exportRecords[id] = map[string]bool{}
+	}
+
+	exportRecords[id][accessIP] = true
+}
+
+// hasExportRecords reports whether any addresses are part of the desired export state for a
+// volume.
+func hasExportRecords(id string) bool {
+	exportRecordsMutex.Lock()
+	defer exportRecordsMutex.Unlock()
+
+	return len(exportRecords[id]) > 0
+}
+
+// removeExportRecord removes an address from the desired export state for a volume.
+func removeExportRecord(id string, accessIP string) {
+	exportRecordsMutex.Lock()
+	defer exportRecordsMutex.Unlock()
+
+	delete(exportRecords[id], accessIP)
+
+	if len(exportRecords[id]) == 0 {
+		delete(exportRecords, id)
+	}
+}
+
+// GetExportState retrieves the contents of /etc/exports and the ipset entries from the storage server.
+func (ns *NetworkStorage) GetExportState() (exports []string, ipsetEntries []string, err error) {
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"cat /etc/exports"+
+			"&& (cat /etc/exports.d/*.exports 2> /dev/null || true)"+
+			"&& echo '---'"+
+			"&& ipset list nodes -output save | grep '^add' | cut -d ' ' -f 3",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to retrieve the export state (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return nil, nil, err
+	}
+
+	section := 0
+
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "---" {
+			section = 1
+
+			continue
+		}
+
+		if line == "" {
+			continue
+		}
+
+		if section == 0 {
+			exports = append(exports, line)
+		} else {
+			ipsetEntries = append(ipsetEntries, line)
+		}
+	}
+
+	return exports, ipsetEntries, nil
+}
+
+// RepairExports reconciles the export state of the storage server with the given addresses.
+func (ns *NetworkStorage) RepairExports(missing []string, unexpected []string) (err error) {
+	commands := []string{}
+
+	for _, ip := range missing {
+		commands = append(
+			commands,
+			"ipset add nodes "+ip+" -exist",
+			"echo '/mnt/data\t"+ip+"(rw,sync,no_subtree_check)' >> /etc/exports",
+		)
+	}
+
+	for _, ip := range unexpected {
+		commands = append(
+			commands,
+			"ipset del nodes "+ip+" -exist",
+			"sed -i '\\#^/mnt/data\\t"+ip+"(#d' /etc/exports",
+			"(grep -l '"+ip+"(' /etc/exports.d/*.exports 2> /dev/null | xargs -r rm -f)",
+		)
+	}
+
+	commands = append(commands, "exportfs -ra")
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, strings.Join(commands, " && "))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to repair the export state (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// RepairFirewall re-applies the firewall rules, ipsets and node access scripts on the storage server.
+// This restores the baseline access control after a reboot or a manual modification.
+func (ns *NetworkStorage) RepairFirewall() (err error) {
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"export IFACE=eth0"+
+			"&& for s in /etc/network/if-up.d/*-nfs-*; do \"$s\" || exit 1; done"+
+			"&& exportfs -ra",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to repair the firewall baseline (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// detectExportDrift diffs the desired export state against the actual state of each storage server.
+func detectExportDrift(d *Driver) {
+	exportRecordsMutex.Lock()
+
+	desired := map[string]map[string]bool{}
+
+	for id, ips := range exportRecords {
+		desired[id] = map[string]bool{}
+
+		for ip := range ips {
+			desired[id][ip] = true
+		}
+	}
+
+	exportRecordsMutex.Unlock()
+
+	for id, ips := range desired {
+		ns, _, err := loadNetworkStorage(d, id)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Skipping drift detection due to load errors (id: %s)", id)
+
+			continue
+		}
+
+		exports, ipsetEntries, err := ns.GetExportState()
+
+		if err != nil {
+			// A failing export state query usually means that the firewall baseline is gone
+			// after a reboot or a manual modification, so attempt to restore it.
+			if d.Configuration.DriftRepair {
+				debugCloudAction(rtNetworkStorage, "Repairing the firewall baseline (id: %s)", ns.ID)
+
+				err = ns.RepairFirewall()
+
+				if err == nil {
+					atomic.AddUint64(&driftRepairedTotal, 1)
+				}
+			}
+
+			continue
+		}
+
+		// Determine which addresses are missing from the actual state.
+		missing := []string{}
+
+		for ip := range ips {
+			found := false
+
+			for _, entry := range ipsetEntries {
+				if entry == ip {
+					found = true
+
+					break
+				}
+			}
+
+			if !found {
+				missing = append(missing, ip)
+			}
+		}
+
+		// Determine which exported addresses are not part of the desired state.
+		unexpected := []string{}
+
+		for _, line := range exports {
+			fields := strings.Split(line, "\t")
+
+			if len(fields) != 2 || fields[0] != "/mnt/data" {
+				continue
+			}
+
+			ip := strings.Split(fields[1], "(")[0]
+
+			if ip == "127.0.0.1" {
+				continue
+			}
+
+			if !ips[ip] {
+				unexpected = append(unexpected, ip)
+			}
+		}
+
+		if len(missing) == 0 && len(unexpected) == 0 {
+			continue
+		}
+
+		atomic.AddUint64(&driftDetectedTotal, 1)
+
+		debugCloudAction(
+			rtNetworkStorage,
+			"Detected export drift (id: %s - missing: %s - unexpected: %s)",
+			ns.ID,
+			strings.Join(missing, ","),
+			strings.Join(unexpected, ","),
+		)
+
+		if d.Configuration.DriftRepair {
+			debugCloudAction(rtNetworkStorage, "Repairing export drift (id: %s)", ns.ID)
+
+			err = ns.RepairExports(missing, unexpected)
+
+			if err == nil {
+				atomic.AddUint64(&driftRepairedTotal, uint64(len(missing)+len(unexpected)))
+			}
+		}
+	}
+}
+
+// detectNodeIPChanges determines if any published nodes were recreated with new addresses and
+// republishes the affected volumes so that the exports point at the current addresses.
+func detectNodeIPChanges(d *Driver) {
+	publishedNodesMutex.Lock()
+
+	recorded := map[string]map[string]string{}
+
+	for id, nodes := range publishedNodes {
+		recorded[id] = map[string]string{}
+
+		for nodeID, accessIP := range nodes {
+			recorded[id][nodeID] = accessIP
+		}
+	}
+
+	publishedNodesMutex.Unlock()
+
+	for id, nodes := range recorded {
+		for nodeID, previousIP := range nodes {
+			server, _, err := getServerByNodeID(d, nodeID)
+
+			if err != nil {
+				continue
+			}
+
+			currentIP, err := getNodeAccessIP(d, server)
+
+			if err != nil || currentIP == previousIP {
+				continue
+			}
+
+			atomic.AddUint64(&driftDetectedTotal, 1)
+
+			debugCloudAction(
+				rtNetworkStorage,
+				"Detected an address change for node '%s' (id: %s - previous: %s - current: %s)",
+				nodeID,
+				id,
+				previousIP,
+				currentIP,
+			)
+
+			if !d.Configuration.DriftRepair {
+				continue
+			}
+
+			ns, _, err := loadNetworkStorage(d, id)
+
+			if err != nil {
+				debugCloudAction(rtNetworkStorage, "Skipping address correction due to load errors (id: %s)", id)
+
+				continue
+			}
+
+			// Publishing the volume again revokes the access which was granted for the previous
+			// address before granting access for the current one.
+			_, err = ns.Publish(nodeID)
+
+			if err == nil {
+				atomic.AddUint64(&driftRepairedTotal, 1)
+			}
+		}
+	}
+}
+
+// driftDetectionLoop periodically checks the storage servers for export drift.
+func driftDetectionLoop(d *Driver) {
+	interval := time.Duration(d.Configuration.DriftDetectionInterval) * time.Second
+
+	for {
+		time.Sleep(interval)
+
+		detectNodeIPChanges(d)
+		detectExportDrift(d)
+
+		debugCloudAction(
+			rtCommon,
+			"Reconciliation pass completed (drift detected: %d - corrections applied: %d)",
+			atomic.LoadUint64(&driftDetectedTotal),
+			atomic.LoadUint64(&driftRepairedTotal),
+		)
+	}
+}
@@ -5,11 +5,14 @@
 package driver
 
 import (
+	"crypto/tls"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/danitso/terraform-provider-clouddk/clouddk"
-	csicommon "github.com/kubernetes-csi/drivers/pkg/csi-common"
 )
 
 const (
@@ -18,46 +21,177 @@ const (
 
 	// DriverVersion defines the driver's version number.
 	DriverVersion = "0.1.0"
+
+	// pcDriverVersion specifies the publish context key containing the version of the controller plugin.
+	pcDriverVersion = "driverVersion"
+
+	// pcReadonly specifies the publish context key containing whether the volume is published as read-only.
+	pcReadonly = "readonly"
+
+	// defaultMaxVolumesPerNode defines the default maximum number of volumes per node.
+	defaultMaxVolumesPerNode = 128
+
+	// defaultNFSLockdPort defines the default port for the NFS lock daemon on a storage server.
+	defaultNFSLockdPort = 2051
+
+	// defaultNFSMountdPort defines the default port for the NFS mount daemon on a storage server.
+	defaultNFSMountdPort = 2052
+
+	// defaultNFSStatdPort defines the default port for the NFS status daemon on a storage server.
+	defaultNFSStatdPort = 2050
+)
+
+var (
+	// BuildCommit contains the git commit the driver was built from. It is injected at build time.
+	BuildCommit = "unknown"
+
+	// BuildDate contains the date the driver was built. It is injected at build time.
+	BuildDate = "unknown"
 )
 
 // Configuration stores the driver configuration.
 type Configuration struct {
-	ClientSettings   *clouddk.ClientSettings
-	Endpoint         string
-	NodeID           string
-	PrivateKey       string
-	PublicKey        string
-	ServerMemory     int
-	ServerProcessors int
+	AdminPort                int
+	APICACertFile            string
+	APIInsecureSkipVerify    bool
+	APIProxyURL              string
+	AuditLogPath             string
+	AutoHeal                 bool
+	ClientSettings           *clouddk.ClientSettings
+	ClusterCIDRs             []string
+	ClusterID                string
+	DataDevice               string
+	DebugLogDirectory        string
+	DeletionGracePeriod      int
+	DNSZone                  string
+	DriftDetectionInterval   int
+	DriftRepair              bool
+	DryRun                   bool
+	Endpoint                 string
+	ExpandRebootWindow       int
+	GRPCKeepaliveInterval    int
+	GRPCKeepaliveTimeout     int
+	GRPCMaxMessageSize       int
+	GRPCReflection           bool
+	GRPCTimeout              int
+	HealthProbeInterval      int
+	HostnameTemplate         string
+	IdleScaleDownDays        int
+	JumpHostAddress          string
+	JumpHostPrivateKey       string
+	JumpHostUser             string
+	KubernetesEvents         bool
+	MaintenanceWindow        string
+	MaxConcurrentProvisions  int
+	MaxStorageServers        int
+	MaxTotalCapacity         int
+	MaxVolumesPerNode        int
+	MetricsPort              int
+	MountCheckInterval       int
+	NamespaceQuota           int
+	NetworkEncryption        bool
+	NFSLockdPort             int
+	NFSMountdPort            int
+	NFSStatdPort             int
+	NodeExporter             bool
+	NodeID                   string
+	OfflineBundlePath        string
+	StrictVersionSkew        bool
+	PrivateKey               string
+	ProfileRolloutPercentage int
+	PublicKey                string
+	ServerMemory             int
+	ServerPackageID          string
+	ServerProcessors         int
+	SkipStaging              bool
+	SocketGID                int
+	SocketMode               os.FileMode
+	SocketUID                int
+	TLSCACertFile            string
+	TLSCertFile              string
+	TLSKeyFile               string
+	WebhookURL               string
 }
 
 // Driver exposes the CSI driver for Cloud.dk.
 type Driver struct {
-	Configuration *Configuration
-	Driver        *csicommon.CSIDriver
-	PackageID     *string
-
-	ControllerServer *ControllerServer
-	IdentityServer   *IdentityServer
-	NodeServer       *NodeServer
-
-	ControllerCapabilities []*csi.ControllerServiceCapability
-	NodeCapabilities       []*csi.NodeServiceCapability
-	PluginCapabilities     []*csi.PluginCapability
-	VolumeCapabilities     []*csi.VolumeCapability
+	CloudClient    CloudClient
+	Configuration  *Configuration
+	FileUploader   FileUploader
+	PackageID      *string
+	RemoteExecutor RemoteExecutor
+	Server         *GRPCServer
+
+	ControllerServer      *ControllerServer
+	GroupControllerServer *GroupControllerServer
+	IdentityServer        *IdentityServer
+	NodeServer            *NodeServer
+
+	ControllerCapabilities      []*csi.ControllerServiceCapability
+	GroupControllerCapabilities []*csi.GroupControllerServiceCapability
+	NodeCapabilities            []*csi.NodeServiceCapability
+	PluginCapabilities          []*csi.PluginCapability
+	VolumeCapabilities          []*csi.VolumeCapability
+
+	provisionSemaphore chan struct{}
+	serverCache        *serverCache
 }
 
 // NewDriver returns a CSI plugin that manages Cloud.dk block storage
 func NewDriver(c *Configuration) (*Driver, error) {
-	packageID, err := getPackageID(c.ServerMemory, c.ServerProcessors)
+	// Apply the custom TLS and proxy settings for the API traffic, if any.
+	err := configureAPITransport(c)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &Driver{
-		Configuration: c,
-		PackageID:     packageID,
+	// Direct the audit records for mutating operations to the configured destination, if any.
+	if c.AuditLogPath != "" {
+		err = initAuditLog(c.AuditLogPath)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Determine the server package based on the hardware requirements unless an exact package is provided.
+	packageID := &c.ServerPackageID
+
+	if c.ServerPackageID == "" {
+		id, err := getPackageID(c.ServerMemory, c.ServerProcessors)
+
+		if err != nil {
+			return nil, err
+		}
+
+		packageID = id
+	}
+
+	// Fall back to the default volume limit when none is provided.
+	if c.MaxVolumesPerNode == 0 {
+		c.MaxVolumesPerNode = defaultMaxVolumesPerNode
+	}
+
+	// Fall back to the default NFS port assignments when none are provided.
+	if c.NFSLockdPort == 0 {
+		c.NFSLockdPort = defaultNFSLockdPort
+	}
+
+	if c.NFSMountdPort == 0 {
+		c.NFSMountdPort = defaultNFSMountdPort
+	}
+
+	if c.NFSStatdPort == 0 {
+		c.NFSStatdPort = defaultNFSStatdPort
+	}
+
+	d := &Driver{
+		CloudClient:    &cloudAPIClient{settings: c.ClientSettings},
+		Configuration:  c,
+		FileUploader:   &sftpFileUploader{},
+		PackageID:      packageID,
+		RemoteExecutor: &sshRemoteExecutor{},
 		ControllerCapabilities: []*csi.ControllerServiceCapability{
 			&csi.ControllerServiceCapability{
 				Type: &csi.ControllerServiceCapability_Rpc{
@@ -73,6 +207,43 @@ func NewDriver(c *Configuration) (*Driver, error) {
 					},
 				},
 			},
+			&csi.ControllerServiceCapability{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+					},
+				},
+			},
+			&csi.ControllerServiceCapability{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+					},
+				},
+			},
+			&csi.ControllerServiceCapability{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_GET_VOLUME,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
+					},
+				},
+			},
+		},
+		GroupControllerCapabilities: []*csi.GroupControllerServiceCapability{
+			{
+				Type: &csi.GroupControllerServiceCapability_Rpc{
+					Rpc: &csi.GroupControllerServiceCapability_RPC{
+						Type: csi.GroupControllerServiceCapability_RPC_CREATE_DELETE_GET_VOLUME_GROUP_SNAPSHOT,
+					},
+				},
+			},
 		},
 		NodeCapabilities: []*csi.NodeServiceCapability{
 			{
@@ -82,6 +253,20 @@ func NewDriver(c *Configuration) (*Driver, error) {
 					},
 				},
 			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+					},
+				},
+			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+					},
+				},
+			},
 		},
 		PluginCapabilities: []*csi.PluginCapability{
 			{
@@ -91,6 +276,13 @@ func NewDriver(c *Configuration) (*Driver, error) {
 					},
 				},
 			},
+			{
+				Type: &csi.PluginCapability_Service_{
+					Service: &csi.PluginCapability_Service{
+						Type: csi.PluginCapability_Service_GROUP_CONTROLLER_SERVICE,
+					},
+				},
+			},
 		},
 		VolumeCapabilities: []*csi.VolumeCapability{
 			{
@@ -98,40 +290,146 @@ func NewDriver(c *Configuration) (*Driver, error) {
 					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
 				},
 			},
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+				},
+			},
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
 		},
-	}, nil
+	}
+
+	// Drop the staging capability so the volumes are mounted directly at the target path in case
+	// the staging phase is disabled.
+	if c.SkipStaging {
+		nodeCapabilities := []*csi.NodeServiceCapability{}
+
+		for _, capability := range d.NodeCapabilities {
+			if capability.GetRpc().GetType() != csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME {
+				nodeCapabilities = append(nodeCapabilities, capability)
+			}
+		}
+
+		d.NodeCapabilities = nodeCapabilities
+	}
+
+	// Cache server lookups for a short period of time in order to reduce the API traffic.
+	d.serverCache = newServerCache()
+
+	// Limit the number of concurrent provisioning operations, if enabled.
+	if c.MaxConcurrentProvisions > 0 {
+		d.provisionSemaphore = make(chan struct{}, c.MaxConcurrentProvisions)
+	}
+
+	// Log mutating operations instead of performing them in case dry-run mode is enabled.
+	if c.DryRun {
+		d.CloudClient = &dryRunCloudClient{client: d.CloudClient}
+		d.FileUploader = &dryRunFileUploader{}
+		d.RemoteExecutor = &dryRunRemoteExecutor{}
+	}
+
+	return d, nil
 }
 
 // Run starts the CSI driver.
 func (d *Driver) Run() {
 	log.Printf("Starting CSI driver '%s' version %s", DriverName, DriverVersion)
 
-	d.Driver = csicommon.NewCSIDriver(DriverName, DriverVersion, d.Configuration.NodeID)
+	d.ControllerServer = newControllerServer(d)
+	d.GroupControllerServer = newGroupControllerServer(d)
+	d.IdentityServer = newIdentityServer(d)
+	d.NodeServer = newNodeServer(d)
 
-	if d.Driver == nil {
-		log.Fatalf("Failed to initialize CSI Driver '%s'", DriverName)
+	// Expose the admin endpoint on the loopback interface, if enabled.
+	if d.Configuration.AdminPort > 0 {
+		go serveAdmin(d)
 	}
 
-	csCaps := []csi.ControllerServiceCapability_RPC_Type{}
+	// Periodically check the storage servers for export drift, if enabled.
+	if d.Configuration.DriftDetectionInterval > 0 {
+		go driftDetectionLoop(d)
+	}
 
-	for _, cap := range d.ControllerCapabilities {
-		csCaps = append(csCaps, cap.Type.(*csi.ControllerServiceCapability_Rpc).Rpc.Type)
+	// Periodically remove the servers whose deletion grace period has expired, if enabled.
+	if d.Configuration.DeletionGracePeriod > 0 {
+		go deletionReaperLoop(d)
 	}
 
-	volCaps := []csi.VolumeCapability_AccessMode_Mode{}
+	// Periodically probe the health of the storage servers, if enabled.
+	if d.Configuration.HealthProbeInterval > 0 {
+		go healthProbeLoop(d)
+	}
 
-	for _, cap := range d.VolumeCapabilities {
-		volCaps = append(volCaps, cap.AccessMode.Mode)
+	// Periodically scale down the storage servers which have been idle for too long, if enabled.
+	if d.Configuration.IdleScaleDownDays > 0 {
+		go idleScaleDownLoop(d)
 	}
 
-	d.Driver.AddControllerServiceCapabilities(csCaps)
-	d.Driver.AddVolumeCapabilityAccessModes(volCaps)
+	// Expose the cost estimate metrics over HTTP, if enabled.
+	if d.Configuration.MetricsPort > 0 {
+		go serveMetrics(d)
+	}
 
-	d.ControllerServer = newControllerServer(d)
-	d.IdentityServer = newIdentityServer(d)
-	d.NodeServer = newNodeServer(d)
+	// Periodically apply security updates during the maintenance window, if enabled.
+	if d.Configuration.MaintenanceWindow != "" {
+		go patchingLoop(d)
+	}
+
+	// Periodically check the staged mounts on this node for staleness, if enabled.
+	if d.Configuration.MountCheckInterval > 0 {
+		go mountWatchdogLoop(d)
+	}
+
+	// Roll out profile upgrades to existing storage servers, if enabled.
+	if d.Configuration.ProfileRolloutPercentage > 0 {
+		go func() {
+			err := rolloutProfileUpgrades(d, d.Configuration.ProfileRolloutPercentage)
+
+			if err != nil {
+				log.Printf("Failed to roll out profile upgrades - Reason: %s", err.Error())
+			}
+		}()
+	}
+
+	// Enable transport security for TCP endpoints in case a certificate is provided.
+	var tlsConfig *tls.Config
+
+	if d.Configuration.TLSCertFile != "" {
+		c, err := newTLSConfig(d.Configuration.TLSCertFile, d.Configuration.TLSKeyFile, d.Configuration.TLSCACertFile)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		tlsConfig = c
+	}
+
+	d.Server = newGRPCServer(d.Configuration, tlsConfig, correlateGRPC)
+	d.Server.SocketGID = d.Configuration.SocketGID
+	d.Server.SocketMode = d.Configuration.SocketMode
+	d.Server.SocketUID = d.Configuration.SocketUID
+
+	err := d.Server.Start(d.Configuration.Endpoint, d.IdentityServer, d.ControllerServer, d.GroupControllerServer, d.NodeServer)
+
+	if err != nil {
+		log.Fatalf("Failed to start the gRPC server - Reason: %s", err.Error())
+	}
+
+	// Stop the server gracefully when a termination signal is received.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+
+		log.Printf("Received signal '%s' and stopping the gRPC server", sig)
+
+		d.Server.Stop()
+	}()
 
-	s := csicommon.NewNonBlockingGRPCServer()
-	s.Start(d.Configuration.Endpoint, d.IdentityServer, d.ControllerServer, d.NodeServer)
-	s.Wait()
+	d.Server.Wait()
 }
@@ -0,0 +1,51 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// dryRunCloudClient implements the CloudClient interface by logging mutating requests instead of performing them.
+// Read-only requests are passed through to the wrapped client so existing resources can still be inspected.
+type dryRunCloudClient struct {
+	client CloudClient
+}
+
+// DoRequest performs read-only requests and logs all other requests.
+func (c *dryRunCloudClient) DoRequest(method string, path string, body *bytes.Buffer, successCodes []int, retryLimit int, retryDelay int) (*http.Response, error) {
+	if method == "GET" {
+		return c.client.DoRequest(method, path, body, successCodes, retryLimit, retryDelay)
+	}
+
+	debugCloudAction(rtCommon, "DRY-RUN: Would perform API request - Method: %s - Path: %s - Body: %s", method, path, body.String())
+
+	return &http.Response{
+		StatusCode: successCodes[0],
+		Body:       ioutil.NopCloser(new(bytes.Buffer)),
+	}, nil
+}
+
+// dryRunFileUploader implements the FileUploader interface by logging uploads instead of performing them.
+type dryRunFileUploader struct{}
+
+// Upload logs the file which would be uploaded to the storage server.
+func (u *dryRunFileUploader) Upload(ns *NetworkStorage, filePath string, fileContents *bytes.Buffer) error {
+	debugCloudAction(rtNetworkStorage, "DRY-RUN: Would upload file (id: %s - path: %s - size: %d bytes)", ns.ID, filePath, fileContents.Len())
+
+	return nil
+}
+
+// dryRunRemoteExecutor implements the RemoteExecutor interface by logging commands instead of executing them.
+type dryRunRemoteExecutor struct{}
+
+// Execute logs the command which would be executed on the storage server.
+func (e *dryRunRemoteExecutor) Execute(ns *NetworkStorage, command string) ([]byte, error) {
+	debugCloudAction(rtNetworkStorage, "DRY-RUN: Would execute command (id: %s) - Command: %s", ns.ID, command)
+
+	return []byte{}, nil
+}
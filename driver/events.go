@@ -0,0 +1,223 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// eventTypeNormal defines the event type for informational events.
+	eventTypeNormal = "Normal"
+
+	// eventTypeWarning defines the event type for failure events.
+	eventTypeWarning = "Warning"
+
+	// parameterPVCName specifies the name of the parameter containing the name of the PVC. The
+	// parameter is injected by the external provisioner when extra create metadata is enabled.
+	parameterPVCName = "csi.storage.k8s.io/pvc/name"
+
+	// parameterPVCNamespace specifies the name of the parameter containing the namespace of the PVC.
+	parameterPVCNamespace = "csi.storage.k8s.io/pvc/namespace"
+
+	// saPathCACert defines the path to the cluster CA certificate inside a pod.
+	saPathCACert = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+
+	// saPathToken defines the path to the service account token inside a pod.
+	saPathToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+var (
+	// eventClient contains the HTTP client for the Kubernetes API and is initialized on first use.
+	eventClient     *http.Client
+	eventClientHost string
+	eventClientOnce = sync.Once{}
+
+	// eventTargets maps volume names to the PVCs the events should be attached to.
+	eventTargets      = map[string]eventObjectReference{}
+	eventTargetsMutex = sync.Mutex{}
+)
+
+// eventBody describes a Kubernetes Event object.
+type eventBody struct {
+	Metadata       eventMetadata        `json:"metadata"`
+	InvolvedObject eventObjectReference `json:"involvedObject"`
+	Reason         string               `json:"reason"`
+	Message        string               `json:"message"`
+	Source         eventSource          `json:"source"`
+	FirstTimestamp string               `json:"firstTimestamp"`
+	LastTimestamp  string               `json:"lastTimestamp"`
+	Count          int                  `json:"count"`
+	Type           string               `json:"type"`
+}
+
+// eventMetadata describes the metadata of a Kubernetes Event object.
+type eventMetadata struct {
+	GenerateName string `json:"generateName"`
+	Namespace    string `json:"namespace"`
+}
+
+// eventObjectReference describes the object a Kubernetes Event is attached to.
+type eventObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+}
+
+// eventSource describes the component a Kubernetes Event originates from.
+type eventSource struct {
+	Component string `json:"component"`
+}
+
+// registerEventTarget records the PVC the events for the given volume name should be attached to.
+func registerEventTarget(volumeName string, namespace string, name string) {
+	if namespace == "" || name == "" {
+		return
+	}
+
+	eventTargetsMutex.Lock()
+	defer eventTargetsMutex.Unlock()
+
+	eventTargets[volumeName] = eventObjectReference{
+		APIVersion: "v1",
+		Kind:       "PersistentVolumeClaim",
+		Name:       name,
+		Namespace:  namespace,
+	}
+}
+
+// unregisterEventTarget removes the PVC recorded for the given volume name.
+func unregisterEventTarget(volumeName string) {
+	eventTargetsMutex.Lock()
+	defer eventTargetsMutex.Unlock()
+
+	delete(eventTargets, volumeName)
+}
+
+// getEventClient initializes the HTTP client for the in-cluster Kubernetes API on first use.
+func getEventClient() (*http.Client, string) {
+	eventClientOnce.Do(func() {
+		host := os.Getenv("KUBERNETES_SERVICE_HOST")
+		port := os.Getenv("KUBERNETES_SERVICE_PORT")
+
+		if host == "" || port == "" {
+			return
+		}
+
+		caCert, err := ioutil.ReadFile(saPathCACert)
+
+		if err != nil {
+			return
+		}
+
+		caCertPool := x509.NewCertPool()
+
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return
+		}
+
+		eventClient = &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: caCertPool},
+			},
+		}
+		eventClientHost = fmt.Sprintf("https://%s:%s", host, port)
+	})
+
+	return eventClient, eventClientHost
+}
+
+// emitEvent posts a Kubernetes Event on the PVC recorded for the given volume name. The events are
+// emitted on a best effort basis and never fail the invoking operation.
+func emitEvent(d *Driver, volumeName string, eventType string, reason string, format string, v ...interface{}) {
+	if !d.Configuration.KubernetesEvents {
+		return
+	}
+
+	eventTargetsMutex.Lock()
+	target, ok := eventTargets[volumeName]
+	eventTargetsMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	client, host := getEventClient()
+
+	if client == nil {
+		debugCloudAction(rtCommon, "Failed to emit event '%s' because the Kubernetes API is unavailable", reason)
+
+		return
+	}
+
+	token, err := ioutil.ReadFile(saPathToken)
+
+	if err != nil {
+		debugCloudAction(rtCommon, "Failed to emit event '%s' because the service account token could not be read", reason)
+
+		return
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	body := eventBody{
+		Metadata: eventMetadata{
+			GenerateName: DriverName + "-",
+			Namespace:    target.Namespace,
+		},
+		InvolvedObject: target,
+		Reason:         reason,
+		Message:        fmt.Sprintf(format, v...),
+		Source:         eventSource{Component: DriverName},
+		FirstTimestamp: timestamp,
+		LastTimestamp:  timestamp,
+		Count:          1,
+		Type:           eventType,
+	}
+
+	reqBody := new(bytes.Buffer)
+	err = json.NewEncoder(reqBody).Encode(body)
+
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(
+		"POST",
+		fmt.Sprintf("%s/api/v1/namespaces/%s/events", host, target.Namespace),
+		reqBody,
+	)
+
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Authorization", "Bearer "+string(bytes.TrimSpace(token)))
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+
+	if err != nil {
+		debugCloudAction(rtCommon, "Failed to emit event '%s' - Error: %s", reason, err.Error())
+
+		return
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != 201 {
+		debugCloudAction(rtCommon, "Failed to emit event '%s' - Status: %d", reason, res.StatusCode)
+	}
+}
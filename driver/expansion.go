@@ -0,0 +1,274 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+// ExpandDisk grows the data capacity of the volume to the given size. A single data disk is
+// grown in place while striped layouts attach additional disks and merge them into the logical
+// volume.
+func (ns *NetworkStorage) ExpandDisk(size int) (err error) {
+	debugCloudAction(rtNetworkStorage, "Expanding disk (id: %s - size: %d GB)", ns.ID, size)
+
+	if ns.Redundancy == nsRedundancyMirror {
+		return fmt.Errorf("Mirrored volumes cannot be expanded")
+	}
+
+	// Wait for all transactions to end before proceeding.
+	err = ns.Wait()
+
+	if err != nil {
+		return err
+	}
+
+	// Retrieve the list of disks attached to the server in order to determine the current layout.
+	res, err := ns.driver.CloudClient.DoRequest(
+		"GET",
+		fmt.Sprintf("cloudservers/%s/disks", ns.ID),
+		new(bytes.Buffer),
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to retrieve list of disks (id: %s)", ns.ID)
+
+		return err
+	}
+
+	diskList := clouddk.DiskListBody{}
+	err = json.NewDecoder(res.Body).Decode(&diskList)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to decode list of disks (id: %s)", ns.ID)
+
+		return err
+	}
+
+	disksFound := 0
+	currentSize := 0
+
+	for _, v := range diskList {
+		if v.Label == nsDiskLabel {
+			disksFound++
+			currentSize += int(v.Size)
+		}
+	}
+
+	if size <= currentSize {
+		return nil
+	}
+
+	if disksFound == 0 {
+		return fmt.Errorf("The volume has no data disks (id: %s)", ns.ID)
+	}
+
+	// Grow the only data disk in place in case the filesystem sits directly on it, which covers
+	// every volume below the striping threshold.
+	if disksFound == 1 {
+		if size > maximumDiskCapacityInGigabytes {
+			return fmt.Errorf("The volume does not use the striped layout and cannot grow beyond %d GB", maximumDiskCapacityInGigabytes)
+		}
+
+		return ns.growSingleDisk(size, diskList)
+	}
+
+	// Attach additional disks covering the missing capacity.
+	remaining := size - currentSize
+	disksAdded := 0
+
+	for remaining > 0 {
+		diskSize := remaining
+
+		if diskSize > maximumDiskCapacityInGigabytes {
+			diskSize = maximumDiskCapacityInGigabytes
+		}
+
+		debugCloudAction(rtNetworkStorage, "Creating data disk (id: %s - size: %d GB)", ns.ID, diskSize)
+
+		createBody := clouddk.DiskCreateBody{
+			Label: nsDiskLabel,
+			Size:  clouddk.CustomInt(diskSize),
+		}
+
+		reqBody := new(bytes.Buffer)
+		err = json.NewEncoder(reqBody).Encode(createBody)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = ns.driver.CloudClient.DoRequest(
+			"POST",
+			fmt.Sprintf("cloudservers/%s/disks", ns.ID),
+			reqBody,
+			[]int{200},
+			1,
+			1,
+		)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to create data disk (id: %s)", ns.ID)
+
+			return err
+		}
+
+		err = ns.Wait()
+
+		if err != nil {
+			return err
+		}
+
+		remaining -= diskSize
+		disksAdded++
+	}
+
+	// Merge the new disks into the volume group and grow the logical volume and its filesystem,
+	// which keeps the exports intact since the mount point never disappears.
+	devices := make([]string, disksAdded)
+
+	for i := 0; i < disksAdded; i++ {
+		devices[i] = fmt.Sprintf("/dev/vd%c", 'b'+disksFound+i)
+	}
+
+	deviceList := ""
+
+	for _, device := range devices {
+		deviceList += " " + device
+	}
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"pvcreate"+deviceList+
+			"&& vgextend data"+deviceList+
+			"&& lvextend -l +100%FREE /dev/data/volume"+
+			"&& resize2fs /dev/data/volume",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to expand the logical volume (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// growSingleDisk grows the only data disk of the volume in place and resizes the filesystem on
+// it, which keeps the exports intact since the mount point never disappears.
+func (ns *NetworkStorage) growSingleDisk(size int, diskList clouddk.DiskListBody) (err error) {
+	for _, v := range diskList {
+		if v.Label != nsDiskLabel {
+			continue
+		}
+
+		debugCloudAction(rtNetworkStorage, "Growing data disk (id: %s - current: %d GB - size: %d GB)", ns.ID, int(v.Size), size)
+
+		updateBody := clouddk.DiskCreateBody{
+			Label: nsDiskLabel,
+			Size:  clouddk.CustomInt(size),
+		}
+
+		reqBody := new(bytes.Buffer)
+		err = json.NewEncoder(reqBody).Encode(updateBody)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = ns.driver.CloudClient.DoRequest(
+			"PUT",
+			fmt.Sprintf("cloudservers/%s/disks/%s", ns.ID, v.Identifier),
+			reqBody,
+			[]int{200},
+			1,
+			1,
+		)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to grow data disk (id: %s)", ns.ID)
+
+			return err
+		}
+
+		err = ns.Wait()
+
+		if err != nil {
+			return err
+		}
+
+		output, err := ns.driver.RemoteExecutor.Execute(ns, "resize2fs /dev/vdb")
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to resize the filesystem after growing the data disk (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+			return err
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("The volume has no data disks (id: %s)", ns.ID)
+}
+
+// expansionMemoryRequirement returns the amount of memory in MB a storage server should have in
+// order to serve a volume of the given size in GB with reasonable NFS performance.
+func expansionMemoryRequirement(size int) int {
+	switch {
+	case size <= 1024:
+		return 4096
+	case size <= 4096:
+		return 8192
+	case size <= 16384:
+		return 16384
+	default:
+		return 32768
+	}
+}
+
+// UpgradePackage moves the storage server to the given package. The server reboots as part of
+// the upgrade, so the upgrade must only be requested within an acceptable reboot window.
+func (ns *NetworkStorage) UpgradePackage(packageID string) (err error) {
+	debugCloudAction(rtNetworkStorage, "Upgrading server package (id: %s - package: %s)", ns.ID, packageID)
+
+	upgradeBody := clouddk.ServerUpgradeBody{
+		Package:     packageID,
+		UpgradeDisk: clouddk.CustomBool(false),
+	}
+
+	reqBody := new(bytes.Buffer)
+	err = json.NewEncoder(reqBody).Encode(upgradeBody)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = ns.driver.CloudClient.DoRequest(
+		"POST",
+		fmt.Sprintf("cloudservers/%s/upgrade", ns.ID),
+		reqBody,
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to upgrade the server package (id: %s)", ns.ID)
+
+		return err
+	}
+
+	ns.driver.serverCache.invalidate(ns.ID, ns.Hostname)
+
+	// Wait for the server to come back after the upgrade before serving traffic again.
+	return ns.Wait()
+}
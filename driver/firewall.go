@@ -0,0 +1,35 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+)
+
+// VerifyFirewall determines if the baseline firewall rules and ipsets are present on the storage
+// server. A missing baseline means the NFS ports are exposed to the internet, so the callers must
+// refuse to hand out access to the volume until the baseline has been restored.
+func (ns *NetworkStorage) VerifyFirewall() (err error) {
+	c := ns.driver.Configuration
+	nfsPorts := fmt.Sprintf("2049,%d,%d,%d", c.NFSStatdPort, c.NFSLockdPort, c.NFSMountdPort)
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"ipset list -n | grep -qx nodes"+
+			"&& ipset list -n | grep -qx networks"+
+			"&& iptables -C INPUT -i eth0 -p tcp -m multiport --dports "+nfsPorts+" -j DROP"+
+			"&& iptables -C INPUT -i eth0 -p udp -m multiport --dports "+nfsPorts+" -j DROP"+
+			"&& iptables -C INPUT -i eth0 -p tcp --dport 111 -j DROP"+
+			"&& iptables -C INPUT -i eth0 -p udp --dport 111 -j DROP",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Detected a missing firewall baseline (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return fmt.Errorf("The firewall baseline is missing and the NFS ports may be exposed (id: %s)", ns.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+// UpgradeFleet rolls the current bootstrap configuration out to all managed storage servers. The
+// upgrades run with the given concurrency and each server is health checked before the next batch
+// is dispatched, so a bad configuration cannot take down the whole fleet.
+func (d *Driver) UpgradeFleet(concurrency int) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		return err
+	}
+
+	hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+	servers := []clouddk.ServerBody{}
+
+	for _, v := range serverList {
+		if strings.HasPrefix(v.Hostname, hostnamePrefix) {
+			servers = append(servers, v)
+		}
+	}
+
+	log.Printf("Upgrading %d storage servers with a concurrency of %d", len(servers), concurrency)
+
+	semaphore := make(chan struct{}, concurrency)
+	waitGroup := sync.WaitGroup{}
+
+	failedMutex := sync.Mutex{}
+	failed := []string{}
+
+	for _, v := range servers {
+		// Stop dispatching further upgrades once a server has failed its health check.
+		failedMutex.Lock()
+		aborted := len(failed) > 0
+		failedMutex.Unlock()
+
+		if aborted {
+			break
+		}
+
+		semaphore <- struct{}{}
+		waitGroup.Add(1)
+
+		go func(v clouddk.ServerBody) {
+			defer waitGroup.Done()
+			defer func() { <-semaphore }()
+
+			ns := &NetworkStorage{
+				driver:   d,
+				Hostname: v.Hostname,
+				ID:       v.Identifier,
+			}
+
+			ip := getServerPrivateIP(&v)
+
+			if ip == "" && len(v.NetworkInterfaces) > 0 && len(v.NetworkInterfaces[0].IPAddresses) > 0 {
+				ip = v.NetworkInterfaces[0].IPAddresses[0].Address
+			}
+
+			ns.IP = ip
+
+			err := ns.Rebuild()
+
+			if err != nil {
+				log.Printf("Failed to upgrade server '%s' (id: %s) - Reason: %s", ns.Hostname, ns.ID, err.Error())
+
+				failedMutex.Lock()
+				failed = append(failed, ns.Hostname)
+				failedMutex.Unlock()
+
+				return
+			}
+
+			log.Printf("Successfully upgraded server '%s' (id: %s)", ns.Hostname, ns.ID)
+		}(v)
+	}
+
+	waitGroup.Wait()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("The fleet upgrade was aborted because the following servers failed: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+)
+
+const (
+	// nsPathFreezeScript defines the path to the freeze script on a storage server.
+	nsPathFreezeScript = "/etc/clouddk_network_storage_freeze.sh"
+)
+
+// nsFreezeScript freezes writes to the data directory for a bounded time window.
+// The timeout protection ensures that the filesystem is thawed again even if the driver never issues a thaw request.
+var nsFreezeScript = heredoc.Doc(`
+	#!/bin/sh
+	# Specify the directory and the time window.
+	DATA_DIRECTORY="/mnt/data"
+	TIMEOUT="${1:-60}"
+
+	# Freeze writes to the data directory unless the watchdog is merely being rescheduled. The
+	# freeze runs synchronously so that failures are reported to the caller instead of producing
+	# an archive which is not crash-consistent.
+	if [ "$2" != "refresh" ]; then
+		fsfreeze -f "$DATA_DIRECTORY" || exit 1
+	fi
+
+	# Thaw the directory again in the background once the time window has passed in case the
+	# driver never issues a thaw request.
+	(sleep "$TIMEOUT" && fsfreeze -u "$DATA_DIRECTORY") > /dev/null 2>&1 &
+`)
+
+// Freeze temporarily freezes writes to the network storage.
+// The filesystem is automatically thawed again after the given number of seconds.
+func (ns *NetworkStorage) Freeze(timeout int) (err error) {
+	debugCloudAction(rtNetworkStorage, "Freezing writes for %d seconds (id: %s)", timeout, ns.ID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"/bin/sh %s %d",
+		nsPathFreezeScript,
+		timeout,
+	))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to freeze writes (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// RefreshFreeze reschedules the auto-thaw watchdog of a frozen volume, keeping the filesystem
+// frozen while a long-running archive completes.
+func (ns *NetworkStorage) RefreshFreeze(timeout int) (err error) {
+	debugCloudAction(rtNetworkStorage, "Refreshing the freeze window for %d seconds (id: %s)", timeout, ns.ID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"pkill -f clouddk_network_storage_freeze.sh; /bin/sh %s %d refresh",
+		nsPathFreezeScript,
+		timeout,
+	))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to refresh the freeze window (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// Thaw resumes writes to the network storage before the freeze time window has passed.
+func (ns *NetworkStorage) Thaw() (err error) {
+	debugCloudAction(rtNetworkStorage, "Thawing writes (id: %s)", ns.ID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"pkill -f clouddk_network_storage_freeze.sh;"+
+			"fsfreeze -u /mnt/data 2>/dev/null;"+
+			"true",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to thaw writes (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// FreezeVolume temporarily freezes writes to the given volume.
+func (d *Driver) FreezeVolume(volumeID string, timeout int) error {
+	ns, err := d.loadNetworkStorageByVolumeID(volumeID)
+
+	if err != nil {
+		return err
+	}
+
+	return ns.Freeze(timeout)
+}
+
+// ThawVolume resumes writes to the given volume.
+func (d *Driver) ThawVolume(volumeID string) error {
+	ns, err := d.loadNetworkStorageByVolumeID(volumeID)
+
+	if err != nil {
+		return err
+	}
+
+	return ns.Thaw()
+}
+
+// loadNetworkStorageByVolumeID initializes the network storage handler for the given volume id.
+func (d *Driver) loadNetworkStorageByVolumeID(volumeID string) (*NetworkStorage, error) {
+	volumeInfo := strings.Split(volumeID, "-")
+
+	if len(volumeInfo) != 2 {
+		return nil, fmt.Errorf("Invalid volume ID '%s'", volumeID)
+	}
+
+	if volumeInfo[0] != volumePrefixNetworkStorage {
+		return nil, fmt.Errorf("The volume type '%s' does not use managed storage servers", volumeInfo[0])
+	}
+
+	ns, _, err := loadNetworkStorage(d, volumeInfo[1])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ns, nil
+}
@@ -0,0 +1,346 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// gsFreezeWindowSeconds defines the maximum number of seconds the member volumes remain frozen
+	// in case the driver never issues a thaw request.
+	gsFreezeWindowSeconds = 60
+
+	// gsIDPrefix defines the prefix for group snapshot identifiers.
+	gsIDPrefix = "gs"
+
+	// gsSnapshotIDSeparator separates the volume id from the group snapshot name in a snapshot identifier.
+	gsSnapshotIDSeparator = "@"
+
+	// nsPathSnapshotDirectory defines the directory on a storage server holding the snapshot archives.
+	// The directory resides on the boot disk so the archives survive changes to the data disk.
+	nsPathSnapshotDirectory = "/var/lib/clouddk-snapshots"
+)
+
+// GroupControllerServer implements the csi.GroupControllerServer interface.
+type GroupControllerServer struct {
+	driver *Driver
+}
+
+// newGroupControllerServer creates a new group controller server.
+func newGroupControllerServer(d *Driver) *GroupControllerServer {
+	return &GroupControllerServer{
+		driver: d,
+	}
+}
+
+// GroupControllerGetCapabilities returns the capabilities of the group controller service.
+func (gcs *GroupControllerServer) GroupControllerGetCapabilities(ctx context.Context, req *csi.GroupControllerGetCapabilitiesRequest) (*csi.GroupControllerGetCapabilitiesResponse, error) {
+	return &csi.GroupControllerGetCapabilitiesResponse{
+		Capabilities: gcs.driver.GroupControllerCapabilities,
+	}, nil
+}
+
+// CreateVolumeGroupSnapshot creates a crash-consistent snapshot of all the volumes in the given
+// group by freezing the members in one pass before any of them is archived. The function is
+// idempotent.
+func (gcs *GroupControllerServer) CreateVolumeGroupSnapshot(ctx context.Context, req *csi.CreateVolumeGroupSnapshotRequest) (*csi.CreateVolumeGroupSnapshotResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "The group snapshot name must be provided")
+	} else if len(req.SourceVolumeIds) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "The source volume IDs must be provided")
+	}
+
+	// Validate the name before embedding it in file names and shell commands.
+	if !isSafeSnapshotName(req.Name) {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid group snapshot name '%s'", req.Name))
+	}
+
+	defer endOperation(beginOperation("CreateVolumeGroupSnapshot", req.Name))
+
+	members := make([]*NetworkStorage, 0, len(req.SourceVolumeIds))
+
+	for _, volumeID := range req.SourceVolumeIds {
+		ns, err := gcs.driver.loadNetworkStorageByVolumeID(volumeID)
+
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		members = append(members, ns)
+	}
+
+	// Serialize operations targeting the member volumes and acquire the locks in a deterministic
+	// order so concurrent group snapshots cannot deadlock.
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Hostname < members[j].Hostname
+	})
+
+	for _, ns := range members {
+		lockVolume(ns.Hostname)
+		defer unlockVolume(ns.Hostname)
+	}
+
+	// Freeze writes to all the member volumes before archiving any of them so the snapshots form a
+	// single crash-consistent point in time. The members are thawed again regardless of the outcome.
+	frozen := []*NetworkStorage{}
+
+	defer func() {
+		for _, ns := range frozen {
+			thawErr := ns.Thaw()
+
+			if thawErr != nil {
+				debugCloudAction(rtNetworkStorage, "Failed to thaw a group snapshot member (id: %s) - Error: %s", ns.ID, thawErr.Error())
+			}
+		}
+	}()
+
+	for _, ns := range members {
+		err := ns.Freeze(gsFreezeWindowSeconds)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		frozen = append(frozen, ns)
+	}
+
+	// Keep the members frozen while the archives are being taken by rescheduling the auto-thaw
+	// watchdog, which would otherwise expire in the middle of archiving a non-trivial volume.
+	refreshDone := make(chan struct{})
+	defer close(refreshDone)
+
+	go func() {
+		ticker := time.NewTicker(gsFreezeWindowSeconds / 2 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-refreshDone:
+				return
+			case <-ticker.C:
+				for _, ns := range frozen {
+					refreshErr := ns.RefreshFreeze(gsFreezeWindowSeconds)
+
+					if refreshErr != nil {
+						debugCloudAction(rtNetworkStorage, "Failed to refresh the freeze window of a group snapshot member (id: %s) - Error: %s", ns.ID, refreshErr.Error())
+					}
+				}
+			}
+		}
+	}()
+
+	creationTime, err := ptypes.TimestampProto(time.Now())
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	snapshots := make([]*csi.Snapshot, 0, len(members))
+
+	for i, ns := range members {
+		err := ns.CreateSnapshotArchive(req.Name)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		auditEvent("snapshot-create", ns.ID, "", "Created snapshot '%s'", req.Name)
+
+		snapshots = append(snapshots, &csi.Snapshot{
+			CreationTime:    creationTime,
+			GroupSnapshotId: fmt.Sprintf("%s-%s", gsIDPrefix, req.Name),
+			ReadyToUse:      true,
+			SizeBytes:       int64(ns.Size * 1073741824),
+			SnapshotId:      fmt.Sprintf("%s%s%s", req.SourceVolumeIds[i], gsSnapshotIDSeparator, req.Name),
+			SourceVolumeId:  req.SourceVolumeIds[i],
+		})
+	}
+
+	return &csi.CreateVolumeGroupSnapshotResponse{
+		GroupSnapshot: &csi.VolumeGroupSnapshot{
+			CreationTime:    creationTime,
+			GroupSnapshotId: fmt.Sprintf("%s-%s", gsIDPrefix, req.Name),
+			ReadyToUse:      true,
+			Snapshots:       snapshots,
+		},
+	}, nil
+}
+
+// DeleteVolumeGroupSnapshot deletes the given group snapshot. The function is idempotent.
+func (gcs *GroupControllerServer) DeleteVolumeGroupSnapshot(ctx context.Context, req *csi.DeleteVolumeGroupSnapshotRequest) (*csi.DeleteVolumeGroupSnapshotResponse, error) {
+	if req.GroupSnapshotId == "" {
+		return nil, status.Error(codes.InvalidArgument, "The group snapshot ID must be provided")
+	}
+
+	defer endOperation(beginOperation("DeleteVolumeGroupSnapshot", req.GroupSnapshotId))
+
+	for _, snapshotID := range req.SnapshotIds {
+		volumeID, name, err := parseSnapshotID(snapshotID)
+
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		volumeInfo := strings.Split(volumeID, "-")
+
+		if len(volumeInfo) != 2 || volumeInfo[0] != volumePrefixNetworkStorage {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid volume ID '%s'", volumeID))
+		}
+
+		ns, notFound, err := loadNetworkStorage(gcs.driver, volumeInfo[1])
+
+		if notFound {
+			// The storage server no longer exists which means that its archives are gone as well.
+			debugCloudAction(rtNetworkStorage, "Skipping the snapshot deletion for a missing volume '%s'", volumeID)
+
+			continue
+		}
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		err = ns.DeleteSnapshotArchive(name)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		auditEvent("snapshot-delete", ns.ID, "", "Deleted snapshot '%s'", name)
+	}
+
+	return &csi.DeleteVolumeGroupSnapshotResponse{}, nil
+}
+
+// GetVolumeGroupSnapshot returns the information about the given group snapshot.
+func (gcs *GroupControllerServer) GetVolumeGroupSnapshot(ctx context.Context, req *csi.GetVolumeGroupSnapshotRequest) (*csi.GetVolumeGroupSnapshotResponse, error) {
+	if req.GroupSnapshotId == "" {
+		return nil, status.Error(codes.InvalidArgument, "The group snapshot ID must be provided")
+	}
+
+	snapshots := make([]*csi.Snapshot, 0, len(req.SnapshotIds))
+
+	for _, snapshotID := range req.SnapshotIds {
+		volumeID, name, err := parseSnapshotID(snapshotID)
+
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		ns, err := gcs.driver.loadNetworkStorageByVolumeID(volumeID)
+
+		if err != nil {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+
+		exists, err := ns.HasSnapshotArchive(name)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if !exists {
+			return nil, status.Error(codes.NotFound, fmt.Sprintf("The snapshot '%s' does not exist", snapshotID))
+		}
+
+		snapshots = append(snapshots, &csi.Snapshot{
+			GroupSnapshotId: req.GroupSnapshotId,
+			ReadyToUse:      true,
+			SizeBytes:       int64(ns.Size * 1073741824),
+			SnapshotId:      snapshotID,
+			SourceVolumeId:  volumeID,
+		})
+	}
+
+	return &csi.GetVolumeGroupSnapshotResponse{
+		GroupSnapshot: &csi.VolumeGroupSnapshot{
+			GroupSnapshotId: req.GroupSnapshotId,
+			ReadyToUse:      true,
+			Snapshots:       snapshots,
+		},
+	}, nil
+}
+
+// CreateSnapshotArchive archives the data directory of the network storage to the snapshot
+// directory on the boot disk. An existing archive with the same name is overwritten.
+func (ns *NetworkStorage) CreateSnapshotArchive(name string) error {
+	debugCloudAction(rtNetworkStorage, "Creating snapshot archive '%s' (id: %s)", name, ns.ID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"mkdir -p %s && tar -czf %s/%s.tar.gz -C /mnt/data .",
+		nsPathSnapshotDirectory,
+		nsPathSnapshotDirectory,
+		name,
+	))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to create snapshot archive '%s' (id: %s) - Output: %s - Error: %s", name, ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSnapshotArchive removes the snapshot archive from the network storage, if it exists.
+func (ns *NetworkStorage) DeleteSnapshotArchive(name string) error {
+	debugCloudAction(rtNetworkStorage, "Deleting snapshot archive '%s' (id: %s)", name, ns.ID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"rm -f %s/%s.tar.gz",
+		nsPathSnapshotDirectory,
+		name,
+	))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to delete snapshot archive '%s' (id: %s) - Output: %s - Error: %s", name, ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// HasSnapshotArchive determines if the snapshot archive exists on the network storage.
+func (ns *NetworkStorage) HasSnapshotArchive(name string) (bool, error) {
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"test -f %s/%s.tar.gz && echo present || echo absent",
+		nsPathSnapshotDirectory,
+		name,
+	))
+
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(string(output)) == "present", nil
+}
+
+// isSafeSnapshotName determines if a snapshot name can be safely embedded in file names and shell
+// commands.
+func isSafeSnapshotName(name string) bool {
+	return isSafeNodeID(name)
+}
+
+// parseSnapshotID splits a snapshot identifier into the volume id and the group snapshot name.
+func parseSnapshotID(snapshotID string) (volumeID string, name string, err error) {
+	snapshotInfo := strings.Split(snapshotID, gsSnapshotIDSeparator)
+
+	if len(snapshotInfo) != 2 || !isSafeSnapshotName(snapshotInfo[1]) {
+		return "", "", fmt.Errorf("Invalid snapshot ID '%s'", snapshotID)
+	}
+
+	return snapshotInfo[0], snapshotInfo[1], nil
+}
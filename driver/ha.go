@@ -0,0 +1,86 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// EnableHA configures DRBD replication of the data disk and keepalived based failover between
+// the storage server and its replica. The primary keeps serving the NFS exports while the
+// replica takes over the virtual address in case the primary fails.
+func (ns *NetworkStorage) EnableHA(replica *NetworkStorage) (err error) {
+	debugCloudAction(rtNetworkStorage, "Enabling high availability (primary: %s - replica: %s)", ns.ID, replica.ID)
+
+	drbdResource := fmt.Sprintf(nsDRBDResource, ns.Hostname, ns.IP, replica.Hostname, replica.IP)
+
+	members := []struct {
+		server   *NetworkStorage
+		state    string
+		priority int
+	}{
+		{ns, "MASTER", 150},
+		{replica, "BACKUP", 100},
+	}
+
+	for _, v := range members {
+		err = ns.driver.FileUploader.Upload(v.server, nsPathDRBDResource, bytes.NewBufferString(drbdResource))
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to enable high availability due to file creation errors (id: %s)", v.server.ID)
+
+			return err
+		}
+
+		err = ns.driver.FileUploader.Upload(v.server, nsPathKeepalivedConf, bytes.NewBufferString(
+			fmt.Sprintf(nsKeepalivedConf, v.state, v.priority, ns.IP),
+		))
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to enable high availability due to file creation errors (id: %s)", v.server.ID)
+
+			return err
+		}
+
+		// Install the replication and failover services and bring the replicated device up.
+		output, err := ns.driver.RemoteExecutor.Execute(
+			v.server,
+			"export DEBIAN_FRONTEND=noninteractive"+
+				"&& apt-get -qq update"+
+				"&& apt-get -qq install -y drbd-utils keepalived"+
+				"&& (umount /mnt/data 2> /dev/null || true)"+
+				"&& sed -i '\\#/mnt/data#d' /etc/fstab"+
+				"&& drbdadm create-md --force k8s"+
+				"&& drbdadm up k8s"+
+				"&& systemctl enable keepalived"+
+				"&& systemctl restart keepalived",
+		)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to enable high availability (id: %s) - Output: %s - Error: %s", v.server.ID, string(output), err.Error())
+
+			return err
+		}
+	}
+
+	// Promote the primary and serve the exports from the replicated device.
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"drbdadm primary --force k8s"+
+			"&& echo '/dev/drbd0 /mnt/data ext4 defaults,noatime,nodiratime,nofail 0 2' >> /etc/fstab"+
+			"&& mount /dev/drbd0 /mnt/data"+
+			"&& chown -R nobody:nogroup /mnt/data"+
+			"&& exportfs -ra",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to promote the primary (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// autoHealFailureThreshold defines the number of consecutive failed health probes after which a
+// rebuild is attempted automatically.
+const autoHealFailureThreshold = 3
+
+var (
+	// probeFailures tracks the number of consecutive failed health probes per storage server.
+	probeFailures      = map[string]int{}
+	probeFailuresMutex = sync.Mutex{}
+)
+
+// Rebuild restores a corrupted storage server from its surviving data disk. The cloud API cannot
+// move disks between servers, so the recovery re-applies the full bootstrap configuration on the
+// existing server and remounts the data disk without touching its contents.
+func (ns *NetworkStorage) Rebuild() (err error) {
+	debugCloudAction(rtNetworkStorage, "Rebuilding server (id: %s - hostname: %s)", ns.ID, ns.Hostname)
+
+	// Serialize operations targeting the same volume.
+	lockVolume(ns.Hostname)
+	defer unlockVolume(ns.Hostname)
+
+	d := ns.driver
+
+	// The recovery relies on key based SSH access, so a server whose SSH service is beyond repair
+	// cannot be rebuilt automatically.
+	sshClient, err := ns.CreateSSHClient()
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to rebuild server due to SSH errors (id: %s)", ns.ID)
+
+		return err
+	}
+
+	defer sshClient.Close()
+
+	sftpClient, err := ns.CreateSFTPClient(sshClient)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to rebuild server due to SFTP errors (id: %s)", ns.ID)
+
+		return err
+	}
+
+	defer sftpClient.Close()
+
+	// Recover the provisioning choices recorded on the server so the rebuild re-applies the
+	// configuration the volume was created with instead of assuming defaults.
+	settings := ns.loadServerSettings()
+
+	if ns.Filesystem == "" {
+		ns.Filesystem = settings["FILESYSTEM"]
+	}
+
+	if ns.NFSVersion == "" {
+		ns.NFSVersion = settings["NFS_VERSION"]
+	}
+
+	// Re-upload the managed files and scripts.
+	needIDMapD := "yes"
+
+	if ns.NFSVersion == nsNFSVersion3 || settings["NEED_IDMAPD"] == "no" {
+		needIDMapD = ""
+	}
+
+	retainSwap := settings["RETAIN_SWAP"]
+
+	if retainSwap != "true" {
+		retainSwap = "false"
+	}
+
+	mountScript := nsMountScript
+
+	if ns.Filesystem == nsFilesystemZFS {
+		mountScript = nsMountScriptZFS
+	}
+
+	files := []struct {
+		path     string
+		contents string
+	}{
+		{nsPathAPTAutoConf, strings.ReplaceAll(nsAPTAutoConf, "\r", "")},
+		{nsPathBootstrapScript, strings.ReplaceAll(strings.ReplaceAll(d.renderScript(nsBootstrapScript), "{{NFS_NEED_IDMAPD}}", needIDMapD), "{{RETAIN_SWAP}}", retainSwap)},
+		{nsPathFirewallScript, d.renderScript(nsFirewallScript)},
+		{nsPathFreezeScript, strings.ReplaceAll(nsFreezeScript, "\r", "")},
+		{nsPathLimitsConf, strings.ReplaceAll(nsLimitsConf, "\r", "")},
+		{nsPathMountScript, strings.ReplaceAll(mountScript, "\r", "")},
+		{nsPathProfileVersion, fmt.Sprintf("%d\n", nsProfileVersion)},
+		{nsPathPublicKey, strings.ReplaceAll(d.Configuration.PublicKey, "\r", "")},
+	}
+
+	// Re-apply the sysctl profile the server was provisioned with. The file is left untouched
+	// when the profile cannot be determined, since it survives on the root disk.
+	switch settings["TUNING_PROFILE"] {
+	case nsTuningProfileConservative:
+		files = append(files, struct {
+			path     string
+			contents string
+		}{nsPathSysctlConf, strings.ReplaceAll(nsSysctlConfConservative, "\r", "")})
+	case nsTuningProfilePerformance:
+		files = append(files, struct {
+			path     string
+			contents string
+		}{nsPathSysctlConf, strings.ReplaceAll(nsSysctlConf, "\r", "")})
+	}
+
+	for _, file := range files {
+		err = ns.CreateFile(sftpClient, file.path, bytes.NewBufferString(file.contents))
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to rebuild server because file '%s' could not be created (id: %s)", file.path, ns.ID)
+
+			return err
+		}
+	}
+
+	// Upload the offline dependency bundle so the bootstrap script can skip the network
+	// operations, if configured.
+	err = ns.uploadOfflineBundle(sftpClient)
+
+	if err != nil {
+		return err
+	}
+
+	// Re-run the bootstrap script and remount the data disk. The mount script only formats devices
+	// without an existing filesystem, so the data disk contents are preserved.
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput("/bin/sh " + nsPathBootstrapScript + " && /bin/sh " + nsPathMountScript)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to rebuild server (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	// Restore the node exports which were published before the failure.
+	exportRecordsMutex.Lock()
+	accessIPs := []string{}
+
+	for ip := range exportRecords[ns.ID] {
+		accessIPs = append(accessIPs, ip)
+	}
+
+	exportRecordsMutex.Unlock()
+
+	if len(accessIPs) > 0 {
+		err = ns.RepairExports(accessIPs, nil)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Restore the access for the configured cluster networks, if any.
+	if len(d.Configuration.ClusterCIDRs) > 0 {
+		err = ns.AuthorizeNetworks(sshClient, sftpClient, d.Configuration.ClusterCIDRs)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ns.VerifyHealth()
+
+	if err != nil {
+		return err
+	}
+
+	debugCloudAction(rtNetworkStorage, "Successfully rebuilt server (id: %s)", ns.ID)
+
+	notifyWebhook(d, "Rebuilt storage server '%s' (id: %s)", ns.Hostname, ns.ID)
+
+	return nil
+}
+
+// loadServerSettings recovers the provisioning choices recorded on a storage server. Servers
+// which predate the settings file are inspected directly so that a rebuild does not apply
+// defaults which disagree with the running configuration.
+func (ns *NetworkStorage) loadServerSettings() map[string]string {
+	settings := map[string]string{}
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"cat %s 2> /dev/null || { "+
+			"echo \"FILESYSTEM=$(findmnt -n -o FSTYPE /mnt/data 2> /dev/null)\"; "+
+			"echo \"NEED_IDMAPD=$(grep -s -q NEED_IDMAPD=yes /etc/default/nfs-common && echo yes || echo no)\"; "+
+			"echo \"RETAIN_SWAP=$([ -n \"$(swapon --noheadings 2> /dev/null)\" ] && echo true || echo false)\"; }",
+		nsPathSettingsConf,
+	))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to recover the server settings (id: %s) - Error: %s", ns.ID, err.Error())
+
+		return settings
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+
+		if len(parts) == 2 && parts[1] != "" {
+			settings[parts[0]] = parts[1]
+		}
+	}
+
+	return settings
+}
+
+// RebuildVolume restores the storage server backing the given volume from its data disk.
+func (d *Driver) RebuildVolume(volumeID string) error {
+	ns, err := d.loadNetworkStorageByVolumeID(volumeID)
+
+	if err != nil {
+		return err
+	}
+
+	return ns.Rebuild()
+}
+
+// recordProbeFailure increments the consecutive failure count for a storage server and triggers an
+// automatic rebuild once the threshold is reached, if enabled.
+func recordProbeFailure(d *Driver, ns *NetworkStorage) {
+	probeFailuresMutex.Lock()
+	probeFailures[ns.ID]++
+	failures := probeFailures[ns.ID]
+
+	if failures >= autoHealFailureThreshold {
+		probeFailures[ns.ID] = 0
+	}
+
+	probeFailuresMutex.Unlock()
+
+	if !d.Configuration.AutoHeal || failures < autoHealFailureThreshold {
+		return
+	}
+
+	debugCloudAction(rtNetworkStorage, "Attempting an automatic rebuild after %d failed probes (id: %s)", failures, ns.ID)
+
+	go func() {
+		err := ns.Rebuild()
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to rebuild server automatically (id: %s) - Error: %s", ns.ID, err.Error())
+		}
+	}()
+}
+
+// resetProbeFailures clears the consecutive failure count for a storage server.
+func resetProbeFailures(id string) {
+	probeFailuresMutex.Lock()
+	defer probeFailuresMutex.Unlock()
+
+	delete(probeFailures, id)
+}
@@ -6,6 +6,7 @@ package driver
 
 import (
 	"context"
+	"runtime"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/protobuf/ptypes/wrappers"
@@ -35,6 +36,11 @@ func (is *IdentityServer) GetPluginInfo(ctx context.Context, req *csi.GetPluginI
 	return &csi.GetPluginInfoResponse{
 		Name:          DriverName,
 		VendorVersion: DriverVersion,
+		Manifest: map[string]string{
+			"buildCommit": BuildCommit,
+			"buildDate":   BuildDate,
+			"goVersion":   runtime.Version(),
+		},
 	}, nil
 }
 
@@ -0,0 +1,186 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// nsISCSIDeviceTimeoutSeconds defines the number of seconds to wait for the iSCSI device to appear after logging in.
+	nsISCSIDeviceTimeoutSeconds = 30
+
+	// nsISCSIPort defines the port for the iSCSI portal on a storage server.
+	nsISCSIPort = 3260
+
+	// nsFormatISCSITargetIQN defines the format of the iSCSI qualified name for the target exposing a volume.
+	nsFormatISCSITargetIQN = "iqn.2020-01.dk.cloud.csi:%s"
+
+	// nsPathISCSIScript defines the path to the iSCSI setup script on a storage server.
+	nsPathISCSIScript = "/etc/clouddk_network_storage_iscsi.sh"
+
+	// nsTransportISCSI identifies the transport where the data disk is exported as an iSCSI LUN instead of an NFS share.
+	nsTransportISCSI = "iscsi"
+)
+
+// nsISCSIScript installs the LIO target on a storage server and exports the data disk as an iSCSI LUN.
+var nsISCSIScript = heredoc.Doc(`
+	#!/bin/sh
+	# Specify the required environment variables.
+	export DEBIAN_FRONTEND=noninteractive
+
+	# The iSCSI qualified name of the target is passed as the first argument.
+	TARGET_IQN="$1"
+
+	# Install the LIO management tool in case it is missing.
+	if ! command -v targetcli >/dev/null 2>&1; then
+		apt-get -qq update
+		apt-get -qq install -y targetcli-fb
+	fi
+
+	# Release the data disk from the local filesystem so that it can back the LUN.
+	if mountpoint -q /mnt/data; then
+		umount /mnt/data
+		sed -i '\#/mnt/data#d' /etc/fstab
+	fi
+
+	# Export the data disk as a LUN and accept any initiator since access is restricted by the firewall.
+	if ! targetcli ls /backstores/block/data >/dev/null 2>&1; then
+		targetcli /backstores/block create name=data dev=/dev/vdb
+		targetcli /iscsi create "$TARGET_IQN"
+		targetcli "/iscsi/$TARGET_IQN/tpg1/luns" create /backstores/block/data
+		targetcli "/iscsi/$TARGET_IQN/tpg1" set attribute authentication=0 generate_node_acls=1 demo_mode_write_protect=0
+		targetcli saveconfig
+	fi
+
+	# Restrict the iSCSI portal to the authorized nodes and cluster networks.
+	if ! iptables -C INPUT -p tcp --dport 3260 -j DROP 2> /dev/null; then
+		iptables -I INPUT -p tcp --dport 3260 -j DROP
+		iptables -I INPUT -p tcp --dport 3260 -m set --match-set nodes src -j ACCEPT
+		iptables -I INPUT -p tcp --dport 3260 -m set --match-set networks src -j ACCEPT
+	fi
+
+	# Enable the service which restores the target configuration after a reboot.
+	systemctl enable rtslib-fb-targetctl
+`)
+
+// EnableISCSI installs and configures the iSCSI target on the storage server.
+func (ns *NetworkStorage) EnableISCSI(sshClient *ssh.Client, sftpClient *sftp.Client) (err error) {
+	debugCloudAction(rtNetworkStorage, "Enabling the iSCSI target (id: %s)", ns.ID)
+
+	err = ns.CreateFile(sftpClient, nsPathISCSIScript, bytes.NewBufferString(strings.ReplaceAll(nsISCSIScript, "\r", "")))
+
+	if err != nil {
+		return err
+	}
+
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput(fmt.Sprintf("/bin/sh %s '%s'", nsPathISCSIScript, fmt.Sprintf(nsFormatISCSITargetIQN, ns.ID)))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to enable the iSCSI target (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// setupISCSIClient logs in to the iSCSI target of the storage server and returns the local device path for the LUN.
+func setupISCSIClient(id string, serverIP string) (device string, err error) {
+	targetIQN := fmt.Sprintf(nsFormatISCSITargetIQN, id)
+	portal := fmt.Sprintf("%s:%d", serverIP, nsISCSIPort)
+
+	output, err := exec.Command("iscsiadm", "-m", "discovery", "-t", "sendtargets", "-p", portal).CombinedOutput()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to discover the iSCSI target for volume '%s' - Output: %s - Error: %s", id, string(output), err.Error())
+	}
+
+	output, err = exec.Command("iscsiadm", "-m", "node", "-T", targetIQN, "-p", portal, "--login").CombinedOutput()
+
+	// Treat an existing session as a success in order for the operation to be idempotent.
+	if err != nil && !strings.Contains(string(output), "already") {
+		return "", fmt.Errorf("Failed to log in to the iSCSI target for volume '%s' - Output: %s - Error: %s", id, string(output), err.Error())
+	}
+
+	// Wait for the kernel to create the device node for the LUN.
+	device = fmt.Sprintf("/dev/disk/by-path/ip-%s-iscsi-%s-lun-0", portal, targetIQN)
+
+	for i := 0; i < nsISCSIDeviceTimeoutSeconds; i++ {
+		_, err = os.Stat(device)
+
+		if err == nil {
+			return device, nil
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return "", fmt.Errorf("The iSCSI device for volume '%s' did not appear within %d seconds", id, nsISCSIDeviceTimeoutSeconds)
+}
+
+// teardownISCSIClient logs out from the iSCSI target of the storage server hosting a volume.
+func teardownISCSIClient(id string) (err error) {
+	// Nodes which never staged an iSCSI volume do not have the open-iscsi tools installed.
+	_, err = exec.LookPath("iscsiadm")
+
+	if err != nil {
+		return nil
+	}
+
+	targetIQN := fmt.Sprintf(nsFormatISCSITargetIQN, id)
+
+	output, err := exec.Command("iscsiadm", "-m", "node", "-T", targetIQN, "--logout").CombinedOutput()
+
+	// Treat a missing session or node record as a success in order for the operation to be idempotent.
+	if err != nil && !strings.Contains(string(output), "No matching sessions") && !strings.Contains(string(output), "no records found") {
+		return fmt.Errorf("Failed to log out from the iSCSI target for volume '%s' - Output: %s - Error: %s", id, string(output), err.Error())
+	}
+
+	return nil
+}
+
+// mountISCSIDevice mounts the iSCSI device at the given path. The filesystem was created by the
+// storage server when the data disk was provisioned.
+func mountISCSIDevice(device string, path string, options ...string) (err error) {
+	err = os.MkdirAll(path, 0750)
+
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-t", "ext4"}
+
+	if len(options) > 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+
+	args = append(args, device, path)
+
+	output, err := exec.Command("mount", args...).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("Failed to mount the iSCSI device '%s' - Output: %s - Error: %s", device, string(output), err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RotateSSHKey installs the given public key as the only authorized key on every managed storage
+// server, retiring any previously installed driver keys. The connections still use the configured
+// key pair, so the rotation must run before the driver configuration is switched to the new pair.
+// The public key is passed Base64 encoded like the configured keys.
+func (d *Driver) RotateSSHKey(encodedPublicKey string) error {
+	decoded, err := base64.StdEncoding.DecodeString(encodedPublicKey)
+
+	if err != nil {
+		return fmt.Errorf("Failed to decode the new public SSH key - Reason: %s", err.Error())
+	}
+
+	publicKey := strings.TrimSpace(string(decoded))
+	_, _, _, _, err = ssh.ParseAuthorizedKey([]byte(publicKey))
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse the new public SSH key - Reason: %s", err.Error())
+	}
+
+	// The key is embedded in a shell command and must not be able to escape the quoting.
+	if strings.Contains(publicKey, "'") {
+		return fmt.Errorf("The new public SSH key contains unsupported characters")
+	}
+
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		return err
+	}
+
+	hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+	failed := []string{}
+	rotated := 0
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, hostnamePrefix) {
+			continue
+		}
+
+		ip := getServerPrivateIP(&v)
+
+		if ip == "" && len(v.NetworkInterfaces) > 0 && len(v.NetworkInterfaces[0].IPAddresses) > 0 {
+			ip = v.NetworkInterfaces[0].IPAddresses[0].Address
+		}
+
+		ns := &NetworkStorage{
+			driver:   d,
+			Hostname: v.Hostname,
+			ID:       v.Identifier,
+			IP:       ip,
+		}
+
+		// Replace both the authorized keys and the key file the bootstrap script re-installs, so
+		// a later rebuild does not resurrect the retired key.
+		output, err := d.RemoteExecutor.Execute(
+			ns,
+			"echo '"+publicKey+"' > /root/.ssh/authorized_keys"+
+				"&& echo '"+publicKey+"' > "+nsPathPublicKey+
+				"&& chmod 600 /root/.ssh/authorized_keys",
+		)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to rotate the SSH key (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+			failed = append(failed, ns.Hostname)
+
+			continue
+		}
+
+		rotated++
+	}
+
+	log.Printf("Rotated the SSH key on %d storage servers", rotated)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("The SSH key rotation failed on the following servers: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
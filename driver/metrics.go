@@ -0,0 +1,123 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	// metricsDiskPricePerGigabyte defines the estimated monthly price per gigabyte of disk in DKK.
+	metricsDiskPricePerGigabyte = 0.40
+)
+
+var (
+	// serverPackagePrices contains the estimated monthly prices in DKK for the packages listed in
+	// serverPackageIDs. The prices are estimates and only intended for chargeback dashboards.
+	serverPackagePrices = map[string]float64{
+		"ac949a1cb4731d": 20,
+		"89833c1dfa7010": 40,
+		"0469d586374e76": 80,
+		"e991abd8ef15c7": 160,
+		"489b7df86d4b76": 240,
+		"9559dbb4b71c45": 320,
+		"ebf313a9994c1e": 640,
+		"86fa7f6209ba2a": 1280,
+		"25848db6009838": 2560,
+		"115f1d99e8e9e4": 3840,
+	}
+)
+
+// metricsHandler serves the cost estimates for the storage servers in the Prometheus text
+// exposition format.
+func metricsHandler(d *Driver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverList, err := listServers(d, "")
+
+		if err != nil {
+			http.Error(w, "Failed to retrieve the list of servers", http.StatusInternalServerError)
+
+			return
+		}
+
+		hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+		buf := new(bytes.Buffer)
+
+		buf.WriteString("# HELP clouddk_csi_server_capacity_gigabytes The provisioned disk capacity of a storage server in gigabytes.\n")
+		buf.WriteString("# TYPE clouddk_csi_server_capacity_gigabytes gauge\n")
+		buf.WriteString("# HELP clouddk_csi_server_cost_estimate_dkk The estimated monthly cost of a storage server in DKK.\n")
+		buf.WriteString("# TYPE clouddk_csi_server_cost_estimate_dkk gauge\n")
+		buf.WriteString("# HELP clouddk_csi_storage_server_healthy Whether the storage server passed the most recent health probe.\n")
+		buf.WriteString("# TYPE clouddk_csi_storage_server_healthy gauge\n")
+
+		serversTotal := 0
+		costTotal := float64(0)
+
+		for _, v := range serverList {
+			if !strings.HasPrefix(v.Hostname, hostnamePrefix) {
+				continue
+			}
+
+			capacity := 0
+
+			for _, disk := range v.Disks {
+				if disk.Label == nsDiskLabel || disk.Label == nsDiskLabelMirror {
+					capacity += int(disk.Size)
+				}
+			}
+
+			cost := serverPackagePrices[v.Package.Identifier] + float64(capacity)*metricsDiskPricePerGigabyte
+			labels := fmt.Sprintf(
+				"{hostname=\"%s\",id=\"%s\",package=\"%s\"}",
+				v.Hostname,
+				v.Identifier,
+				v.Package.Identifier,
+			)
+
+			fmt.Fprintf(buf, "clouddk_csi_server_capacity_gigabytes%s %d\n", labels, capacity)
+			fmt.Fprintf(buf, "clouddk_csi_server_cost_estimate_dkk%s %.2f\n", labels, cost)
+
+			if health, ok := getServerHealth(v.Identifier); ok {
+				healthy := 0
+
+				if health.Healthy {
+					healthy = 1
+				}
+
+				fmt.Fprintf(buf, "clouddk_csi_storage_server_healthy%s %d\n", labels, healthy)
+			}
+
+			serversTotal++
+			costTotal += cost
+		}
+
+		buf.WriteString("# HELP clouddk_csi_storage_servers The number of storage servers on the account.\n")
+		buf.WriteString("# TYPE clouddk_csi_storage_servers gauge\n")
+		fmt.Fprintf(buf, "clouddk_csi_storage_servers %d\n", serversTotal)
+		buf.WriteString("# HELP clouddk_csi_total_cost_estimate_dkk The estimated total monthly cost of the storage servers in DKK.\n")
+		buf.WriteString("# TYPE clouddk_csi_total_cost_estimate_dkk gauge\n")
+		fmt.Fprintf(buf, "clouddk_csi_total_cost_estimate_dkk %.2f\n", costTotal)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(buf.Bytes())
+	}
+}
+
+// serveMetrics exposes the metrics endpoint on the configured port.
+func serveMetrics(d *Driver) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler(d))
+	mux.HandleFunc("/targets", scrapeTargetsHandler(d))
+
+	err := http.ListenAndServe(fmt.Sprintf(":%d", d.Configuration.MetricsPort), mux)
+
+	if err != nil {
+		log.Printf("Failed to serve the metrics endpoint - Reason: %s", err.Error())
+	}
+}
@@ -0,0 +1,282 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+const (
+	// nsMigrationSuffix is appended to the volume name while the migration target server is being
+	// provisioned, keeping the original hostname free until the cutover.
+	nsMigrationSuffix = "-migrate"
+
+	// nsPathMigrationKey specifies the path of the temporary private key which allows the source
+	// server to reach the migration target over SSH.
+	nsPathMigrationKey = "/root/.ssh/clouddk_migration_key"
+)
+
+// MigrateVolume moves the data of the given volume to a newly provisioned storage server with a
+// different package or location. The data is synchronized with rsync while the old server keeps
+// serving the exports, followed by a brief cutover in which the exports are suspended, a final
+// synchronization pass runs and the new server adopts the hostname and static address of the old
+// one. The old server is scheduled for deletion afterwards, honoring the configured grace period,
+// and the new volume id is printed so that statically provisioned volume handles can be updated.
+func (d *Driver) MigrateVolume(volumeID string, packageID string, location string) error {
+	ns, err := d.loadNetworkStorageByVolumeID(volumeID)
+
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimPrefix(ns.Hostname, fmt.Sprintf(nsFormatHostname, ""))
+
+	if name == ns.Hostname {
+		return fmt.Errorf("The volume '%s' is not backed by a storage server (hostname: %s)", volumeID, ns.Hostname)
+	}
+
+	debugCloudAction(rtNetworkStorage, "Migrating server (id: %s - package: %s - location: %s)", ns.ID, packageID, location)
+
+	// Provision the target server while the old one keeps serving the exports. An existing
+	// target server is reused so that an interrupted migration can be resumed.
+	target, exists, err := createNetworkStorage(d, &NetworkStorageConfig{
+		Location:  location,
+		Name:      name + nsMigrationSuffix,
+		PackageID: packageID,
+		Size:      ns.Size,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		fmt.Printf("Reusing the existing target server (id: %s)\n", target.ID)
+	}
+
+	// Allow the source server to reach the target over SSH by placing the driver key on it
+	// temporarily. The target authorizes the corresponding public key during bootstrapping.
+	err = ns.CreateFile(nil, nsPathMigrationKey, bytes.NewBufferString(d.Configuration.PrivateKey))
+
+	if err != nil {
+		return err
+	}
+
+	output, err := d.RemoteExecutor.Execute(ns, "chmod 600 "+nsPathMigrationKey)
+
+	if err != nil {
+		return fmt.Errorf("Failed to secure the migration key (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+	}
+
+	// Synchronize the data while the source keeps serving the exports.
+	fmt.Printf("Synchronizing data to the target server (id: %s)\n", target.ID)
+
+	err = d.syncMigrationData(ns, target)
+
+	if err != nil {
+		return err
+	}
+
+	// Cut over by suspending the exports, running a final synchronization pass and letting the
+	// target adopt the identity of the source. The exports remain suspended on the source since
+	// it is scheduled for deletion afterwards.
+	lockVolume(ns.Hostname)
+	defer unlockVolume(ns.Hostname)
+
+	fmt.Printf("Cutting over to the target server (id: %s)\n", target.ID)
+
+	output, err = d.RemoteExecutor.Execute(ns, "exportfs -ua")
+
+	if err != nil {
+		return fmt.Errorf("Failed to suspend the exports (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+	}
+
+	err = d.syncMigrationData(ns, target)
+
+	if err != nil {
+		return err
+	}
+
+	err = d.moveStaticIP(ns, target)
+
+	if err != nil {
+		return err
+	}
+
+	// The key is no longer needed once the final synchronization pass has completed.
+	_, _ = d.RemoteExecutor.Execute(ns, "rm -f "+nsPathMigrationKey)
+
+	oldHostname := ns.Hostname
+	oldID := ns.ID
+
+	// Read the source label before the source is removed so the target can adopt a
+	// retain-data-disk policy or owner record along with the hostname.
+	sourceServer, _, err := getServerByID(d, oldID)
+
+	if err != nil {
+		return err
+	}
+
+	label := sourceServer.Label
+
+	if label == "" {
+		label = oldHostname
+	}
+
+	// Free the hostname before the target takes it over, honoring the deletion grace period in
+	// the same way as DeleteVolume. The reaper only runs when a grace period is configured, so
+	// the source must be deleted immediately otherwise.
+	if d.Configuration.DeletionGracePeriod > 0 {
+		err = ns.MarkPendingDeletion(d.Configuration.DeletionGracePeriod)
+	} else {
+		err = ns.Delete()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = target.rename(oldHostname, label)
+
+	if err != nil {
+		return err
+	}
+
+	// Transfer the node exports which were published on the source server.
+	exportRecordsMutex.Lock()
+	accessIPs := []string{}
+
+	for ip := range exportRecords[oldID] {
+		accessIPs = append(accessIPs, ip)
+	}
+
+	if len(accessIPs) > 0 {
+		exportRecords[target.ID] = exportRecords[oldID]
+	}
+
+	delete(exportRecords, oldID)
+	exportRecordsMutex.Unlock()
+
+	if len(accessIPs) > 0 {
+		err = target.RepairExports(accessIPs, nil)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	auditEvent("server-migrate", target.ID, "", "Migrated volume '%s' from server '%s'", name, oldID)
+
+	notifyWebhook(d, "Migrated volume '%s' to server '%s' (package: %s)", name, target.ID, packageID)
+
+	fmt.Printf("Migrated volume to server '%s' (new volume id: %s-%s)\n", target.ID, volumePrefixNetworkStorage, target.ID)
+
+	return nil
+}
+
+// syncMigrationData synchronizes the data disk of the source server onto the target server with
+// rsync, installing it on demand on both ends.
+func (d *Driver) syncMigrationData(ns *NetworkStorage, target *NetworkStorage) error {
+	install := "which rsync > /dev/null || (apt-get -qq update&& apt-get -qq -y install rsync)"
+
+	output, err := d.RemoteExecutor.Execute(target, install)
+
+	if err != nil {
+		return fmt.Errorf("Failed to install rsync on the target server (id: %s) - Output: %s - Error: %s", target.ID, string(output), err.Error())
+	}
+
+	output, err = d.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"%s&& rsync -aH --delete --numeric-ids -e 'ssh -i %s -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null' /mnt/data/ root@%s:/mnt/data/",
+		install,
+		nsPathMigrationKey,
+		target.IP,
+	))
+
+	if err != nil {
+		return fmt.Errorf("Failed to synchronize the data (id: %s) - Output: %s - Error: %s", ns.ID, outputTail(output, outputTailLines), err.Error())
+	}
+
+	return nil
+}
+
+// moveStaticIP carries a configured static service address over to the target server during the
+// cutover, in case the source server announces one.
+func (d *Driver) moveStaticIP(ns *NetworkStorage, target *NetworkStorage) error {
+	script, err := d.RemoteExecutor.Execute(ns, "cat "+nsPathStaticIPScript+" 2> /dev/null || true")
+
+	if err != nil || len(bytes.TrimSpace(script)) == 0 {
+		return nil
+	}
+
+	debugCloudAction(rtNetworkStorage, "Moving the static address to the target server (id: %s)", target.ID)
+
+	err = target.CreateFile(nil, nsPathStaticIPScript, bytes.NewBuffer(script))
+
+	if err != nil {
+		return err
+	}
+
+	// Release the address on the source before the target claims it.
+	output, err := d.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"ip addr del $(awk '/ip addr replace/ {print $4}' %s) dev eth0; rm -f %s",
+		nsPathStaticIPScript,
+		nsPathStaticIPScript,
+	))
+
+	if err != nil {
+		return fmt.Errorf("Failed to release the static address (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+	}
+
+	output, err = d.RemoteExecutor.Execute(target, "chmod +x "+nsPathStaticIPScript+" && IFACE=eth0 "+nsPathStaticIPScript)
+
+	if err != nil {
+		return fmt.Errorf("Failed to apply the static address (id: %s) - Output: %s - Error: %s", target.ID, string(output), err.Error())
+	}
+
+	return nil
+}
+
+// rename renames the server in order to let it adopt the hostname and label of a migrated
+// volume.
+func (ns *NetworkStorage) rename(hostname string, label string) (err error) {
+	debugCloudAction(rtNetworkStorage, "Renaming server (id: %s - hostname: %s)", ns.ID, hostname)
+
+	body := clouddk.ServerUpdateBody{
+		Hostname: hostname,
+		Label:    label,
+	}
+
+	reqBody := new(bytes.Buffer)
+	err = json.NewEncoder(reqBody).Encode(body)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = ns.driver.CloudClient.DoRequest(
+		"PUT",
+		fmt.Sprintf("cloudservers/%s", ns.ID),
+		reqBody,
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to rename server (id: %s)", ns.ID)
+
+		return err
+	}
+
+	ns.driver.serverCache.invalidate(ns.ID, ns.Hostname)
+	ns.Hostname = hostname
+
+	return nil
+}
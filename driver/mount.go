@@ -0,0 +1,160 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// unmountAttemptTimeoutSeconds specifies the number of seconds to wait for a single unmount attempt.
+	unmountAttemptTimeoutSeconds = 10
+
+	// unmountRetryCount specifies the number of unmount attempts before falling back to a forced, lazy unmount.
+	unmountRetryCount = 3
+
+	// unmountRetryIntervalSeconds specifies the number of seconds to wait between unmount attempts.
+	unmountRetryIntervalSeconds = 2
+)
+
+// isLikelyNotMountPoint determines whether the given path is unlikely to be a mountpoint by
+// comparing its device number to that of its parent directory. Bind mounts to a path on the
+// same filesystem are not detected, but the driver only bind mounts network filesystems.
+func isLikelyNotMountPoint(path string) (bool, error) {
+	pathStat, err := os.Stat(path)
+
+	if err != nil {
+		return true, err
+	}
+
+	parentStat, err := os.Stat(filepath.Dir(strings.TrimSuffix(path, "/")))
+
+	if err != nil {
+		return true, err
+	}
+
+	if pathStat.Sys().(*syscall.Stat_t).Dev != parentStat.Sys().(*syscall.Stat_t).Dev {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// recoverMountPoint determines whether the given path is already a healthy mountpoint and
+// unmounts it in case the mount turns out to be corrupted, e.g. by a stale NFS file handle,
+// so the caller can mount it again.
+func recoverMountPoint(path string) (mounted bool, err error) {
+	notMountPoint, err := isLikelyNotMountPoint(path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if notMountPoint {
+		return false, nil
+	}
+
+	// Probe the mountpoint in order to detect corrupted mounts.
+	stats := syscall.Statfs_t{}
+	err = syscall.Statfs(path, &stats)
+
+	if err == nil {
+		return true, nil
+	}
+
+	// Unmount the corrupted mountpoint so a fresh mount can be attempted.
+	err = runUnmount(path)
+
+	if err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// runMount invokes the mount command with the given arguments and includes the command output in any error.
+func runMount(args ...string) error {
+	output, err := exec.Command("mount", args...).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("Failed to mount '%s' - Output: %s - Error: %s", strings.Join(args, " "), strings.TrimSpace(string(output)), err.Error())
+	}
+
+	return nil
+}
+
+// runUnmount invokes the umount command with the given arguments and includes the command output in any error.
+// The command is aborted after a timeout in case it hangs on an unreachable storage server.
+func runUnmount(args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), unmountAttemptTimeoutSeconds*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "umount", args...).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("Failed to unmount '%s' - Output: %s - Error: %s", strings.Join(args, " "), strings.TrimSpace(string(output)), err.Error())
+	}
+
+	return nil
+}
+
+// safeUnmount unmounts the given path, retrying on failure and falling back to a forced, lazy
+// unmount in case the storage server has become unreachable. The path is only removed once it
+// is verified to no longer be a mountpoint.
+func safeUnmount(path string) error {
+	notMountPoint, err := isLikelyNotMountPoint(path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !notMountPoint {
+		for attempt := 0; attempt < unmountRetryCount; attempt++ {
+			err = runUnmount(path)
+
+			if err == nil {
+				break
+			}
+
+			time.Sleep(unmountRetryIntervalSeconds * time.Second)
+		}
+
+		// Fall back to a forced, lazy unmount in order to detach the mountpoint even when the storage server no longer responds.
+		if err != nil {
+			err = runUnmount("-f", "-l", path)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		notMountPoint, err = isLikelyNotMountPoint(path)
+
+		if err != nil {
+			return err
+		}
+
+		if !notMountPoint {
+			return fmt.Errorf("The path '%s' is still mounted after unmounting it", path)
+		}
+	}
+
+	return os.RemoveAll(path)
+}
@@ -0,0 +1,134 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	// mountProfileLatency identifies the profile tuned for low latency at the cost of throughput.
+	mountProfileLatency = "latency"
+
+	// mountProfileResilient identifies the profile tuned for surviving short storage server outages.
+	mountProfileResilient = "resilient"
+
+	// mountProfileThroughput identifies the profile tuned for streaming workloads.
+	mountProfileThroughput = "throughput"
+)
+
+// mountProfiles maps each curated profile name to the NFS client options it expands to.
+var mountProfiles = map[string][]string{
+	mountProfileLatency: {
+		"actimeo=1",
+		"hard",
+		"rsize=65536",
+		"timeo=150",
+		"wsize=65536",
+	},
+	mountProfileResilient: {
+		"actimeo=30",
+		"hard",
+		"retrans=5",
+		"timeo=600",
+	},
+	mountProfileThroughput: {
+		"actimeo=30",
+		"hard",
+		"nconnect=8",
+		"rsize=1048576",
+		"timeo=600",
+		"wsize=1048576",
+	},
+}
+
+// applyMountProfile prepends the options of the given profile to the explicitly requested
+// options. Explicitly requested options take precedence over the values provided by the profile.
+func applyMountProfile(profile string, options []string) []string {
+	profileOptions, ok := mountProfiles[profile]
+
+	if !ok {
+		return options
+	}
+
+	explicit := map[string]bool{}
+
+	for _, option := range options {
+		explicit[mountOptionKey(option)] = true
+	}
+
+	merged := []string{}
+
+	for _, option := range profileOptions {
+		if !explicit[mountOptionKey(option)] {
+			merged = append(merged, option)
+		}
+	}
+
+	return append(merged, options...)
+}
+
+// filterMountOptions removes the options which the kernel of the node does not support, since
+// the mount would otherwise be rejected.
+func filterMountOptions(options []string) []string {
+	filtered := []string{}
+
+	for _, option := range options {
+		if strings.HasPrefix(option, "nconnect=") && !kernelSupportsNconnect() {
+			log.Printf("WARNING: Dropping the '%s' mount option because the kernel does not support it", option)
+
+			continue
+		}
+
+		filtered = append(filtered, option)
+	}
+
+	return filtered
+}
+
+// kernelSupportsNconnect determines if the kernel of the node supports the nconnect mount
+// option, which requires Linux 5.3 or newer for NFS.
+func kernelSupportsNconnect() bool {
+	release, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+
+	if err != nil {
+		return false
+	}
+
+	components := strings.Split(strings.TrimSpace(string(release)), ".")
+
+	if len(components) < 2 {
+		return false
+	}
+
+	major, err := strconv.Atoi(components[0])
+
+	if err != nil {
+		return false
+	}
+
+	minor, err := strconv.Atoi(strings.SplitN(components[1], "-", 2)[0])
+
+	if err != nil {
+		return false
+	}
+
+	return major > 5 || (major == 5 && minor >= 3)
+}
+
+// mountOptionKey returns the name of a mount option, treating the hard and soft flags as the
+// same option since they are mutually exclusive.
+func mountOptionKey(option string) string {
+	key := strings.SplitN(option, "=", 2)[0]
+
+	if key == "soft" {
+		key = "hard"
+	}
+
+	return key
+}
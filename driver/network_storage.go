@@ -9,9 +9,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
+	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,16 +24,45 @@ import (
 )
 
 const (
+	nsFilesystemEXT4              = "ext4"
+	nsFilesystemZFS               = "zfs"
+	nsNFSVersion3                 = "3"
+	nsNFSVersion41                = "4.1"
+	nsNFSVersion42                = "4.2"
 	nsDiskLabel                   = "k8s-network-storage"
+	nsDiskLabelMirror             = "k8s-network-storage-mirror"
 	nsFormatHostname              = "k8s-network-storage-%s"
+	nsFormatNodeExportPath        = "/etc/exports.d/k8s-%s.exports"
 	nsFormatNodeNetworkScriptPath = "/etc/network/if-up.d/10-nfs-%s"
+	nsFormatOwnerLabel            = "pv:%s pvc:%s/%s"
+	nsFormatPendingHostname       = "k8s-pending-deletion-%s"
+	nsFormatPendingLabel          = "pending-deletion-%d"
+	nsFormatRetainedHostname      = "k8s-retained-data-%s"
+	nsInterfaceWaitSeconds        = 120
+	nsInterfaceWaitDelaySeconds   = 5
+	nsLabelRetainDataDisk         = "retain-data-disk"
+	nsRedundancyMirror            = "mirror"
+	nsRedundancyNone              = "none"
+	nsReplicaSuffix               = "-replica"
 	nsPathAPTAutoConf             = "/etc/apt/apt.conf.d/00auto-conf"
+	nsPathNetworksScript          = "/etc/network/if-up.d/05-nfs-networks"
 	nsPathBootstrapScript         = "/etc/clouddk_network_storage_bootstrap.sh"
+	nsPathDRBDResource            = "/etc/drbd.d/k8s.res"
 	nsPathFirewallScript          = "/etc/network/if-up.d/00-nfs-firewall-rules"
+	nsPathKeepalivedConf          = "/etc/keepalived/keepalived.conf"
 	nsPathLimitsConf              = "/etc/security/limits.conf"
 	nsPathMountScript             = "/etc/clouddk_network_storage_mount.sh"
 	nsPathPublicKey               = "/root/.ssh/id_rsa_driver.pub"
+	nsPathSettingsConf            = "/etc/clouddk_network_storage_settings.conf"
+	nsPathStaticIPScript          = "/etc/network/if-up.d/20-nfs-static-ip"
 	nsPathSysctlConf              = "/etc/sysctl.d/20-maximum-performance.conf"
+	nsTuningProfileConservative   = "conservative"
+	nsTuningProfileDefault        = "default"
+	nsTuningProfilePerformance    = "performance"
+	nsWaitBackoffFactor           = 2.0
+	nsWaitIntervalInitialSeconds  = 1.0
+	nsWaitIntervalJitterFactor    = 0.5
+	nsWaitIntervalMaxSeconds      = 30.0
 )
 
 var (
@@ -46,73 +77,120 @@ var (
 		# Specify the required environment variables.
 		export DEBIAN_FRONTEND=noninteractive
 
+		# The script is divided into idempotent steps which record their completion in the step
+		# directory, allowing a resumed provisioning attempt to skip the completed work.
+		STEP_DIR=/var/lib/clouddk-csi/steps
+
+		mkdir -p "$STEP_DIR"
+
+		step_done() {
+			[ -f "$STEP_DIR/$1" ]
+		}
+
+		step_mark() {
+			touch "$STEP_DIR/$1"
+		}
+
 		# Change script permissions.
 		chmod +x /etc/clouddk_*
 		chmod +x /etc/network/if-up.d/*
 
 		# Authorize the SSH key and disable password authentication.
-		if [ ! -f /root/.ssh/authorized_keys ]; then
-			touch /root/.ssh/authorized_keys
+		if ! step_done ssh-access; then
+			if [ ! -f /root/.ssh/authorized_keys ]; then
+				touch /root/.ssh/authorized_keys
+			fi
+
+			grep -q -F -f /root/.ssh/id_rsa_driver.pub /root/.ssh/authorized_keys \
+				|| cat /root/.ssh/id_rsa_driver.pub >> /root/.ssh/authorized_keys
+			sed -i 's/#\?PasswordAuthentication.*/PasswordAuthentication no/' /etc/ssh/sshd_config
+			systemctl restart ssh
+
+			step_mark ssh-access
 		fi
 
-		cat /root/.ssh/id_rsa_driver.pub >> /root/.ssh/authorized_keys
-		sed -i 's/#\?PasswordAuthentication.*/PasswordAuthentication no/' /etc/ssh/sshd_config
-		systemctl restart ssh
+		# Turn off swap to improve performance unless it is retained for memory headroom.
+		if [ "{{RETAIN_SWAP}}" != "true" ] && ! step_done swap; then
+			swapoff -a
+			sed -i '/ swap / s/^/#/' /etc/fstab
 
-		# Turn off swap to improve performance.
-		swapoff -a
-		sed -i '/ swap / s/^/#/' /etc/fstab
+			step_mark swap
+		fi
 
 		# Load the optimized kernel configuration.
 		sysctl --system
 
-		# Configure APT to use a mirror located in Denmark instead of the default US mirror.
-		sed -i 's/us.archive.ubuntu.com/mirrors.dotsrc.org/' /etc/apt/sources.list
-
-		# Wait for APT processes to terminate before proceeding.
-		while ps aux | grep -q [a]pt || fuser /var/lib/apt/lists/lock >/dev/null 2>&1 || fuser /var/lib/dpkg/lock >/dev/null 2>&1; do
-			sleep 2
-		done
-
-		# Upgrade the installed packages as the provided image is often quite old.
-		apt-get -qq update
-		apt-get -qq upgrade -y
-		apt-get -qq dist-upgrade -y
+		# Install the required packages unless a previous attempt already completed the step.
+		if ! step_done packages; then
+			# Configure APT to use a mirror located in Denmark instead of the default US mirror.
+			sed -i 's/us.archive.ubuntu.com/mirrors.dotsrc.org/' /etc/apt/sources.list
+
+			# Wait for APT processes to terminate before proceeding.
+			while ps aux | grep -q [a]pt || fuser /var/lib/apt/lists/lock >/dev/null 2>&1 || fuser /var/lib/dpkg/lock >/dev/null 2>&1; do
+				sleep 2
+			done
+
+			# Install the dependencies from the uploaded bundle in case one exists, since some accounts
+			# block egress from the servers. The bundle contains the package archives and is installed
+			# with dpkg, skipping all network operations.
+			if [ -f /root/clouddk_offline_bundle.tar.gz ]; then
+				mkdir -p /root/clouddk_offline_bundle
+				tar -xzf /root/clouddk_offline_bundle.tar.gz -C /root/clouddk_offline_bundle
+				dpkg -R -i /root/clouddk_offline_bundle
+				rm -rf /root/clouddk_offline_bundle /root/clouddk_offline_bundle.tar.gz
+			else
+				# Upgrade the installed packages as the provided image is often quite old.
+				apt-get -qq update
+				apt-get -qq upgrade -y
+				apt-get -qq dist-upgrade -y
+
+				# Install some additional packages including the NFS kernel server.
+				apt-get -qq install -y \
+					apt-transport-https \
+					ca-certificates \
+					ipset \
+					nfs-kernel-server \
+					software-properties-common
+			fi
 
-		# Install some additional packages including the NFS kernel server.
-		apt-get -qq install -y \
-			apt-transport-https \
-			ca-certificates \
-			ipset \
-			nfs-kernel-server \
-			software-properties-common
+			step_mark packages
+		fi
 
 		# Update the NFS configuration files.
 		(
 			echo 'NEED_GSSD='
-			echo 'NEED_IDMAPD='
+			echo 'NEED_IDMAPD={{NFS_NEED_IDMAPD}}'
 			echo 'NEED_STATD='
-			echo 'STATDOPTS="--port 2050"'
+			echo 'STATDOPTS="--port {{NFS_STATD_PORT}}"'
 		) > /etc/default/nfs-common
 		(
 			PROCESSOR_COUNT="$(nproc)"
 
 			echo 'NEED_SVCGSSD='
-			echo 'RPCMOUNTDOPTS="--manage-gids -p 2052"'
+			echo 'RPCMOUNTDOPTS="--manage-gids -p {{NFS_MOUNTD_PORT}}"'
 			echo "RPCNFSDCOUNT=$((PROCESSOR_COUNT * 8))"
 			echo 'RPCNFSDPRIORITY=0'
 			echo 'RPCSVCGSSDOPTS='
 		) > /etc/default/nfs-kernel-server
 		(
-			echo 'options lockd nlm_udpport=2051 nlm_tcpport=2051'
+			echo 'options lockd nlm_udpport={{NFS_LOCKD_PORT}} nlm_tcpport={{NFS_LOCKD_PORT}}'
 		) > /etc/modprobe.d/nfs.conf
 
+		# Create the directory for the driver managed export fragments.
+		mkdir -p /etc/exports.d
+
 		# Load some additional kernel modules.
 		modprobe lockd
-		echo 'lockd' >> /etc/modules
-
-		# Restart the NFS service.
-		systemctl restart nfs-kernel-server
+		grep -q '^lockd$' /etc/modules || echo 'lockd' >> /etc/modules
+
+		# Restart the NFS service once to apply the port configuration and fall back to reloading
+		# the export list on the later runs, so a resumed bootstrap does not interrupt the service.
+		if ! systemctl is-active --quiet nfs-kernel-server || ! step_done nfs-config; then
+			systemctl restart nfs-kernel-server
+			step_mark nfs-config
+		else
+			exportfs -ra
+		fi
 
 		# Apply the firewall rules for the NFS service.
 		export IFACE="eth0"
@@ -133,18 +211,32 @@ var (
 
 		ipset flush nodes
 
+		# Create the ipset for the cluster networks.
+		if ! ipset list | grep -q -i 'Name: networks'; then
+			ipset create networks hash:net hashsize 1024
+		fi
+
+		# Specify the NFS related ports.
+		NFS_PORTS="2049,{{NFS_STATD_PORT}},{{NFS_LOCKD_PORT}},{{NFS_MOUNTD_PORT}}"
+
 		# Add the firewall rules to iptables.
-		iptables -I INPUT -i "$IFACE" -p udp --dport 2049:2052 -j DROP
-		iptables -I INPUT -i "$IFACE" -p tcp --dport 2049:2052 -j DROP
+		iptables -I INPUT -i "$IFACE" -p udp -m multiport --dports "$NFS_PORTS" -j DROP
+		iptables -I INPUT -i "$IFACE" -p tcp -m multiport --dports "$NFS_PORTS" -j DROP
 
 		iptables -I INPUT -i "$IFACE" -p udp --dport 111 -j DROP
 		iptables -I INPUT -i "$IFACE" -p tcp --dport 111 -j DROP
 
-		iptables -I INPUT -i "$IFACE" -p udp --dport 2049:2052 -m set --match-set nodes src -j ACCEPT
-		iptables -I INPUT -i "$IFACE" -p tcp --dport 2049:2052 -m set --match-set nodes src -j ACCEPT
+		iptables -I INPUT -i "$IFACE" -p udp -m multiport --dports "$NFS_PORTS" -m set --match-set nodes src -j ACCEPT
+		iptables -I INPUT -i "$IFACE" -p tcp -m multiport --dports "$NFS_PORTS" -m set --match-set nodes src -j ACCEPT
 
 		iptables -I INPUT -i "$IFACE" -p udp --dport 111 -m set --match-set nodes src -j ACCEPT
 		iptables -I INPUT -i "$IFACE" -p tcp --dport 111 -m set --match-set nodes src -j ACCEPT
+
+		iptables -I INPUT -i "$IFACE" -p udp -m multiport --dports "$NFS_PORTS" -m set --match-set networks src -j ACCEPT
+		iptables -I INPUT -i "$IFACE" -p tcp -m multiport --dports "$NFS_PORTS" -m set --match-set networks src -j ACCEPT
+
+		iptables -I INPUT -i "$IFACE" -p udp --dport 111 -m set --match-set networks src -j ACCEPT
+		iptables -I INPUT -i "$IFACE" -p tcp --dport 111 -m set --match-set networks src -j ACCEPT
 	`)
 	nsLimitsConf = heredoc.Doc(`
 		* soft nproc 1048576
@@ -156,16 +248,77 @@ var (
 		* soft memlock unlimited
 		* hard memlock unlimited
 	`)
+	nsDRBDResource = heredoc.Doc(`
+		resource k8s {
+			protocol C;
+			device /dev/drbd0;
+			disk /dev/vdb;
+			meta-disk internal;
+
+			on %s {
+				address %s:7789;
+			}
+
+			on %s {
+				address %s:7789;
+			}
+		}
+	`)
+	nsKeepalivedConf = heredoc.Doc(`
+		vrrp_instance k8s {
+			state %s
+			interface eth0
+			virtual_router_id 51
+			priority %d
+			advert_int 1
+
+			virtual_ipaddress {
+				%s
+			}
+		}
+	`)
+	nsStaticIPScript = heredoc.Doc(`
+		#!/bin/sh
+		# Terminate the script if we are not dealing with the public interface.
+		if [ "$IFACE" != "eth0" ]; then
+			exit 0
+		fi
+
+		# Configure the reserved static address as a secondary address for the NFS endpoint.
+		ip addr replace %s/32 dev eth0
+	`)
 	nsMountScript = heredoc.Doc(`
 		#!/bin/sh
-		# Specify the device and directory.
-		DATA_DEVICE="/dev/vdb"
+		# Specify the device and directory. The device can be overridden through the driver
+		# configuration and is otherwise detected, since the bus naming depends on the template.
+		DATA_DEVICE="{{DATA_DEVICE}}"
 		DATA_DIRECTORY="/mnt/data"
 
+		if [ -z "$DATA_DEVICE" ]; then
+			# Prefer the device holding the labeled data filesystem from a previous run and fall
+			# back to the first disk which does not hold the root filesystem.
+			if [ -e /dev/disk/by-label/k8s-data ]; then
+				DATA_DEVICE="/dev/disk/by-label/k8s-data"
+			else
+				for DISK in $(lsblk -dn -o NAME,TYPE | awk '$2 == "disk" { print $1 }'); do
+					if lsblk -n -o MOUNTPOINT "/dev/$DISK" | grep -qx /; then
+						continue
+					fi
+
+					DATA_DEVICE="/dev/$DISK"
+					break
+				done
+			fi
+		fi
+
+		if [ -z "$DATA_DEVICE" ]; then
+			DATA_DEVICE="/dev/vdb"
+		fi
+
 		# Ensure that the device is mounted.
 		if ! mountpoint -q "$DATA_DIRECTORY"; then
 			if [ "$(blkid -s TYPE -o value "$DATA_DEVICE")" = "" ]; then
-				mkfs -t ext4 "$DATA_DEVICE"
+				mkfs -t ext4 -L k8s-data "$DATA_DEVICE"
 			fi
 
 			if ! grep -q "$DATA_DIRECTORY" /etc/fstab; then
@@ -177,6 +330,48 @@ var (
 			chown -R nobody:nogroup "$DATA_DIRECTORY"
 		fi
 	`)
+	nsMountScriptZFS = heredoc.Doc(`
+		#!/bin/sh
+		# Specify the required environment variables.
+		export DEBIAN_FRONTEND=noninteractive
+
+		# Specify the device and directory. The device can be overridden through the driver
+		# configuration and is otherwise detected, since the bus naming depends on the template.
+		DATA_DEVICE="{{DATA_DEVICE}}"
+		DATA_DIRECTORY="/mnt/data"
+
+		if [ -z "$DATA_DEVICE" ]; then
+			for DISK in $(lsblk -dn -o NAME,TYPE | awk '$2 == "disk" { print $1 }'); do
+				if lsblk -n -o MOUNTPOINT "/dev/$DISK" | grep -qx /; then
+					continue
+				fi
+
+				DATA_DEVICE="/dev/$DISK"
+				break
+			done
+		fi
+
+		if [ -z "$DATA_DEVICE" ]; then
+			DATA_DEVICE="/dev/vdb"
+		fi
+
+		# Install the ZFS utilities in case they are missing.
+		if ! command -v zpool >/dev/null 2>&1; then
+			apt-get -qq update
+			apt-get -qq install -y zfsutils-linux
+		fi
+
+		# Ensure that the pool exists and enable lz4 compression.
+		if ! zpool list data >/dev/null 2>&1; then
+			zpool create -o ashift=12 -O compression=lz4 -O atime=off data "$DATA_DEVICE"
+		fi
+
+		# Ensure that the dataset for the volume exists and is mounted.
+		if ! zfs list data/volume >/dev/null 2>&1; then
+			zfs create -o mountpoint="$DATA_DIRECTORY" data/volume
+			chown -R nobody:nogroup "$DATA_DIRECTORY"
+		fi
+	`)
 	nsSysctlConf = heredoc.Doc(`
 		fs.file-max=1048576
 		fs.inotify.max_user_instances=1048576
@@ -205,39 +400,155 @@ var (
 		vm.swappiness=0
 		vm.vfs_cache_pressure=50
 	`)
+	nsSysctlConfConservative = heredoc.Doc(`
+		fs.file-max=1048576
+		net.core.rmem_max=16777216
+		net.core.somaxconn=8192
+		net.core.wmem_max=16777216
+		net.ipv4.tcp_max_syn_backlog=4096
+		vm.max_map_count=262144
+		vm.min_free_kbytes=32768
+		vm.swappiness=10
+	`)
 )
 
 // NetworkStorage implements the logic for creating ReadWriteMany volumes.
 type NetworkStorage struct {
 	driver *Driver
 
-	ID   string
-	IP   string
-	Size int
+	ExportPath string
+	Filesystem string
+	Hostname   string
+	ID         string
+	IP         string
+	NFSVersion string
+	Redundancy string
+	Size       int
+	Transport  string
+}
+
+// NetworkStorageConfig specifies the properties of new network storage.
+type NetworkStorageConfig struct {
+	DiskScheduler       string
+	ExtraAllowedCIDRs   []string
+	Filesystem          string
+	HA                  bool
+	Location            string
+	Name                string
+	NFSThreads          int
+	NFSVersion          string
+	PackageID           string
+	Protocol            string
+	PVCName             string
+	PVCNamespace        string
+	PVName              string
+	Redundancy          string
+	RetainDataDisk      bool
+	RetainSwap          bool
+	Size                int
+	SMBPassword         string
+	SMBUsername         string
+	StaticIP            string
+	TCPSlotTableEntries int
+	Transport           string
+	TuningProfile       string
+}
+
+// renderVolumeHostname renders the configured hostname template for a volume. The rendered name
+// is inserted into the reserved hostname prefix so the driver keeps recognizing its servers while
+// operators can enforce their own naming conventions for the remainder of the hostname.
+func renderVolumeHostname(d *Driver, volumeName string, pvcNamespace string, pvcName string) (string, error) {
+	template := d.Configuration.HostnameTemplate
+
+	if template == "" {
+		template = "{{VOLUME_NAME}}"
+	}
+
+	name := strings.ReplaceAll(template, "{{CLUSTER_ID}}", d.Configuration.ClusterID)
+	name = strings.ReplaceAll(name, "{{PVC_NAME}}", pvcName)
+	name = strings.ReplaceAll(name, "{{PVC_NAMESPACE}}", pvcNamespace)
+	name = strings.ReplaceAll(name, "{{VOLUME_NAME}}", volumeName)
+	name = strings.Trim(strings.ToLower(name), "-")
+
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return "", fmt.Errorf("The hostname template renders the invalid name '%s'", name)
+		}
+	}
+
+	if name == "" || len(fmt.Sprintf(nsFormatHostname, name)) > 63 {
+		return "", fmt.Errorf("The hostname template renders the invalid name '%s'", name)
+	}
+
+	return name, nil
 }
 
-// createNetworkStorage creates new network storage of the given size.
-func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage, exists bool, err error) {
-	hostname := fmt.Sprintf(nsFormatHostname, name)
+// createNetworkStorage creates new network storage based on the given configuration.
+func createNetworkStorage(d *Driver, config *NetworkStorageConfig) (ns *NetworkStorage, exists bool, err error) {
+	hostname := fmt.Sprintf(nsFormatHostname, config.Name)
+
+	// Serialize operations targeting the same volume name to avoid interleaving the creation of a
+	// new server with the deletion of an old one.
+	lockVolume(hostname)
+	defer unlockVolume(hostname)
 
-	// Determine if the server already exists to avoid duplicates.
-	_, _, err = getServerByHostname(d.Configuration.ClientSettings, hostname)
+	// Determine if the server already exists to avoid duplicates. The metadata of the existing
+	// volume is recovered so that restarted provisioning can bind the persistent volume with the
+	// actual size and address instead of failing.
+	existingServer, _, err := getServerByHostname(d, hostname)
 
 	if err == nil {
-		return nil, true, fmt.Errorf("Server already exists (hostname: %s)", hostname)
+		ns, _, err := loadNetworkStorage(d, existingServer.Identifier)
+
+		if err != nil {
+			return nil, true, fmt.Errorf("Server already exists (hostname: %s)", hostname)
+		}
+
+		return ns, true, nil
 	}
 
-	// Create a new storage server of the given size.
+	// Create a new storage server of the given size while honoring a package or location override.
 	debugCloudAction(rtNetworkStorage, "Creating server (hostname: %s)", hostname)
 
+	packageID := *d.PackageID
+
+	if config.PackageID != "" {
+		packageID = config.PackageID
+	}
+
+	location := "dk1"
+
+	if config.Location != "" {
+		location = config.Location
+	}
+
+	// Record the owning workload in the server label so billing exports and the Cloud.dk panel
+	// show which claim each server belongs to, and record the retain-data-disk policy in the
+	// label so that DeleteVolume can honor it without access to the original storage class
+	// parameters.
+	label := hostname
+
+	if config.PVCName != "" {
+		label = fmt.Sprintf(nsFormatOwnerLabel, config.PVName, config.PVCNamespace, config.PVCName)
+	}
+
+	// The policy precedes the owner record so that both facts fit in the single label field.
+	if config.RetainDataDisk {
+		if config.PVCName != "" {
+			label = nsLabelRetainDataDisk + " " + label
+		} else {
+			label = nsLabelRetainDataDisk
+		}
+	}
+
 	rootPassword := "p" + getRandomPassword(63)
 	body := clouddk.ServerCreateBody{
 		Hostname:            hostname,
-		Label:               hostname,
+		Label:               label,
 		InitialRootPassword: rootPassword,
-		Package:             *d.PackageID,
+		Package:             packageID,
 		Template:            "ubuntu-18.04-x64",
-		Location:            "dk1",
+		Location:            location,
 	}
 
 	reqBody := new(bytes.Buffer)
@@ -247,7 +558,24 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 		return nil, false, err
 	}
 
-	res, err := clouddk.DoClientRequest(d.Configuration.ClientSettings, "POST", "cloudservers", reqBody, []int{200}, 1, 1)
+	// Log the creation request and skip the provisioning steps in case dry-run mode is enabled.
+	if d.Configuration.DryRun {
+		debugCloudAction(rtNetworkStorage, "DRY-RUN: Would perform API request - Method: POST - Path: cloudservers - Body: %s", reqBody.String())
+		debugCloudAction(rtNetworkStorage, "DRY-RUN: Would bootstrap server and create a data disk (hostname: %s - size: %d GB)", hostname, config.Size)
+
+		return &NetworkStorage{
+			driver:     d,
+			Filesystem: config.Filesystem,
+			Hostname:   hostname,
+			ID:         "dry-run",
+			NFSVersion: config.NFSVersion,
+			Redundancy: config.Redundancy,
+			Size:       config.Size,
+			Transport:  config.Transport,
+		}, false, nil
+	}
+
+	res, err := d.CloudClient.DoRequest("POST", "cloudservers", reqBody, []int{200}, 1, 1)
 
 	if err != nil {
 		debugCloudAction(rtNetworkStorage, "Failed to create server (hostname: %s)", hostname)
@@ -262,15 +590,49 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 		return nil, false, err
 	}
 
-	ns = &NetworkStorage{
-		driver: d,
-		ID:     server.Identifier,
-		Size:   size,
-	}
+	// Invalidate any cached lookups for the hostname now that a new server owns it.
+	d.serverCache.invalidate(server.Identifier, hostname)
+
+	emitEvent(d, config.Name, eventTypeNormal, "ServerCreated", "Created storage server '%s' (id: %s)", hostname, server.Identifier)
+	auditEvent("server-create", server.Identifier, "", "Created server '%s' for volume '%s'", hostname, config.Name)
 
-	// Ensure that the server has at least a single network interface.
+	ns = &NetworkStorage{
+		driver:     d,
+		Filesystem: config.Filesystem,
+		Hostname:   hostname,
+		ID:         server.Identifier,
+		NFSVersion: config.NFSVersion,
+		Redundancy: config.Redundancy,
+		Size:       config.Size,
+		Transport:  config.Transport,
+	}
+
+	// Ensure that the server has at least a single network interface. The interfaces are sometimes
+	// attached a few seconds after the creation request returns, so poll the server for a bounded
+	// time before declaring the failure and tearing the server down.
 	debugCloudAction(rtNetworkStorage, "Checking network interfaces (id: %s)", ns.ID)
 
+	timeDelay := int64(nsInterfaceWaitDelaySeconds)
+	timeMax := float64(nsInterfaceWaitSeconds)
+	timeStart := time.Now()
+	timeElapsed := timeStart.Sub(timeStart)
+
+	for len(server.NetworkInterfaces) == 0 && timeElapsed.Seconds() < timeMax {
+		time.Sleep(time.Duration(timeDelay) * time.Second)
+
+		d.serverCache.invalidate(server.Identifier, hostname)
+
+		updatedServer, _, err := getServerByID(d, ns.ID)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to poll for network interfaces (id: %s)", ns.ID)
+		} else {
+			server = *updatedServer
+		}
+
+		timeElapsed = time.Since(timeStart)
+	}
+
 	if len(server.NetworkInterfaces) == 0 {
 		debugCloudAction(rtNetworkStorage, "Failed to initialize server due to lack of network interfaces (id: %s)", ns.ID)
 
@@ -281,6 +643,13 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 
 	ns.IP = server.NetworkInterfaces[0].IPAddresses[0].Address
 
+	// Prefer the private network for storage traffic in case the server has a private interface.
+	if privateIP := getServerPrivateIP(&server); privateIP != "" {
+		debugCloudAction(rtNetworkStorage, "Using private network address '%s' (id: %s)", privateIP, ns.ID)
+
+		ns.IP = privateIP
+	}
+
 	// Wait for pending and running transactions to end.
 	err = ns.Wait()
 
@@ -303,10 +672,10 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}
 
-	timeDelay := int64(10)
-	timeMax := float64(300)
-	timeStart := time.Now()
-	timeElapsed := timeStart.Sub(timeStart)
+	timeDelay = int64(10)
+	timeMax = float64(300)
+	timeStart = time.Now()
+	timeElapsed = timeStart.Sub(timeStart)
 
 	err = nil
 
@@ -360,7 +729,22 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 		return nil, false, err
 	}
 
-	err = ns.CreateFile(sftpClient, nsPathBootstrapScript, bytes.NewBufferString(strings.ReplaceAll(nsBootstrapScript, "\r", "")))
+	// Enable idmapping for the NFSv4 protocol family and disable it for NFSv3.
+	needIDMapD := "yes"
+
+	if ns.NFSVersion == nsNFSVersion3 {
+		needIDMapD = ""
+	}
+
+	retainSwap := "false"
+
+	if config.RetainSwap {
+		retainSwap = "true"
+	}
+
+	bootstrapScript := strings.ReplaceAll(d.renderScript(nsBootstrapScript), "{{NFS_NEED_IDMAPD}}", needIDMapD)
+	bootstrapScript = strings.ReplaceAll(bootstrapScript, "{{RETAIN_SWAP}}", retainSwap)
+	err = ns.CreateFile(sftpClient, nsPathBootstrapScript, bytes.NewBufferString(bootstrapScript))
 
 	if err != nil {
 		debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathBootstrapScript, ns.ID)
@@ -370,7 +754,7 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 		return nil, false, err
 	}
 
-	err = ns.CreateFile(sftpClient, nsPathFirewallScript, bytes.NewBufferString(strings.ReplaceAll(nsFirewallScript, "\r", "")))
+	err = ns.CreateFile(sftpClient, nsPathFirewallScript, bytes.NewBufferString(d.renderScript(nsFirewallScript)))
 
 	if err != nil {
 		debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathFirewallScript, ns.ID)
@@ -390,7 +774,13 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 		return nil, false, err
 	}
 
-	err = ns.CreateFile(sftpClient, nsPathMountScript, bytes.NewBufferString(strings.ReplaceAll(nsMountScript, "\r", "")))
+	mountScript := nsMountScript
+
+	if ns.Filesystem == nsFilesystemZFS {
+		mountScript = nsMountScriptZFS
+	}
+
+	err = ns.CreateFile(sftpClient, nsPathMountScript, bytes.NewBufferString(strings.ReplaceAll(mountScript, "\r", "")))
 
 	if err != nil {
 		debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathMountScript, ns.ID)
@@ -400,6 +790,16 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 		return nil, false, err
 	}
 
+	err = ns.CreateFile(sftpClient, nsPathFreezeScript, bytes.NewBufferString(strings.ReplaceAll(nsFreezeScript, "\r", "")))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathFreezeScript, ns.ID)
+
+		ns.Delete()
+
+		return nil, false, err
+	}
+
 	err = ns.CreateFile(sftpClient, nsPathPublicKey, bytes.NewBufferString(strings.ReplaceAll(ns.driver.Configuration.PublicKey, "\r", "")))
 
 	if err != nil {
@@ -410,11 +810,65 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 		return nil, false, err
 	}
 
-	err = ns.CreateFile(sftpClient, nsPathSysctlConf, bytes.NewBufferString(strings.ReplaceAll(nsSysctlConf, "\r", "")))
+	// Select the sysctl profile for the server. The aggressive profile remains the default while
+	// the conservative profile trades peak throughput for memory headroom on small packages and
+	// the stock profile leaves the kernel settings untouched.
+	sysctlConf := nsSysctlConf
+
+	switch config.TuningProfile {
+	case nsTuningProfileConservative:
+		sysctlConf = nsSysctlConfConservative
+	case nsTuningProfileDefault:
+		sysctlConf = ""
+	}
+
+	if sysctlConf != "" {
+		err = ns.CreateFile(sftpClient, nsPathSysctlConf, bytes.NewBufferString(strings.ReplaceAll(sysctlConf, "\r", "")))
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathSysctlConf, ns.ID)
+
+			ns.Delete()
+
+			return nil, false, err
+		}
+	}
+
+	err = ns.CreateFile(sftpClient, nsPathProfileVersion, bytes.NewBufferString(fmt.Sprintf("%d\n", nsProfileVersion)))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathProfileVersion, ns.ID)
+
+		ns.Delete()
+
+		return nil, false, err
+	}
+
+	// Record the provisioning choices on the server so that a later rebuild can re-apply the
+	// configuration the volume was created with.
+	settings := fmt.Sprintf(
+		"FILESYSTEM=%s\nNFS_VERSION=%s\nRETAIN_SWAP=%s\nTUNING_PROFILE=%s\n",
+		ns.Filesystem,
+		ns.NFSVersion,
+		retainSwap,
+		config.TuningProfile,
+	)
+
+	err = ns.CreateFile(sftpClient, nsPathSettingsConf, bytes.NewBufferString(settings))
 
 	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathSysctlConf, ns.ID)
+		debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathSettingsConf, ns.ID)
+
+		ns.Delete()
+
+		return nil, false, err
+	}
+
+	// Upload the offline dependency bundle so the bootstrap script can skip the network
+	// operations, if configured.
+	err = ns.uploadOfflineBundle(sftpClient)
 
+	if err != nil {
 		ns.Delete()
 
 		return nil, false, err
@@ -435,157 +889,493 @@ func createNetworkStorage(d *Driver, name string, size int) (ns *NetworkStorage,
 
 	debugCloudAction(rtNetworkStorage, "Bootstrapping server (id: %s)", ns.ID)
 
+	emitEvent(d, config.Name, eventTypeNormal, "Bootstrapping", "Bootstrapping storage server '%s' - this usually takes a few minutes", ns.Hostname)
+
 	output, err := sshSession.CombinedOutput("/bin/sh " + nsPathBootstrapScript)
 
 	if err != nil {
 		debugCloudAction(rtNetworkStorage, "Failed to bootstrap server (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
 
+		storeDebugLog(d, "bootstrap-"+ns.ID, output)
+
 		ns.Delete()
 
-		return nil, false, err
+		return nil, false, fmt.Errorf("Failed to bootstrap server (id: %s) - Error: %s - Output: %s", ns.ID, err.Error(), outputTail(output, outputTailLines))
 	}
 
-	// Create the data disk.
-	err = ns.EnsureDisk(size)
+	// Confirm the key based access installed by the bootstrap script and lock the root password so
+	// the initial password cannot be used for the remaining life of the server.
+	output, err = ns.driver.RemoteExecutor.Execute(ns, "passwd -l root")
 
 	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to lock the root password (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
 		ns.Delete()
 
 		return nil, false, err
 	}
 
-	return ns, false, nil
-}
-
-// loadNetworkStorage initializes the network storage handler for the given volume.
-func loadNetworkStorage(d *Driver, id string) (ns *NetworkStorage, notFound bool, err error) {
-	res, err := clouddk.DoClientRequest(
-		d.Configuration.ClientSettings,
-		"GET",
-		fmt.Sprintf("cloudservers/%s", id),
-		new(bytes.Buffer),
-		[]int{200},
-		1,
-		1,
-	)
+	// Refuse to continue in case the firewall baseline failed to apply, since the NFS ports would
+	// otherwise be exposed to the internet without a warning.
+	err = ns.VerifyFirewall()
 
 	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to load server (id: %s)", id)
+		ns.Delete()
 
-		return nil, (res.StatusCode == 404), err
+		return nil, false, err
 	}
 
-	server := clouddk.ServerBody{}
-	err = json.NewDecoder(res.Body).Decode(&server)
+	// Refuse to hand out a volume whose NFS service did not survive the bootstrap, since its
+	// first mount attempt would hang instead of failing with a message.
+	err = ns.VerifyNFSService()
 
 	if err != nil {
+		ns.Delete()
+
 		return nil, false, err
 	}
 
-	if len(server.NetworkInterfaces) == 0 {
-		debugCloudAction(rtNetworkStorage, "Failed to load server due to lack of network interfaces (id: %s)", id)
-
-		return nil, false, fmt.Errorf("The server has no network interfaces (id: %s)", id)
-	}
+	// Configure the pre-reserved static address as the NFS endpoint, if requested. The address
+	// survives server rebuilds and migrations, so the existing mounts on the nodes keep working
+	// when the server behind the volume is replaced.
+	if config.StaticIP != "" {
+		err = ns.CreateFile(sftpClient, nsPathStaticIPScript, bytes.NewBufferString(fmt.Sprintf(nsStaticIPScript, config.StaticIP)))
 
-	ns = &NetworkStorage{
-		driver: d,
-		ID:     server.Identifier,
-		IP:     server.NetworkInterfaces[0].IPAddresses[0].Address,
-	}
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to initialize server because file '%s' could not be created (id: %s)", nsPathStaticIPScript, ns.ID)
 
-	for _, v := range server.Disks {
-		if v.Label == nsDiskLabel {
-			ns.Size = int(v.Size)
+			ns.Delete()
 
-			break
+			return nil, false, err
 		}
-	}
 
-	return ns, false, nil
-}
-
-// CreateFile creates a file on the server.
-func (ns *NetworkStorage) CreateFile(sftpClient *sftp.Client, filePath string, fileContents *bytes.Buffer) error {
-	debugCloudAction(rtNetworkStorage, "Creating file '%s' (id: %s)", filePath, ns.ID)
+		output, err = ns.driver.RemoteExecutor.Execute(ns, "chmod +x "+nsPathStaticIPScript+" && IFACE=eth0 "+nsPathStaticIPScript)
 
-	newSFTPClient := sftpClient
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to configure the static address (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
 
-	if newSFTPClient == nil {
-		sshClient, err := ns.CreateSSHClient()
+			ns.Delete()
 
-		if err != nil {
-			return err
+			return nil, false, err
 		}
 
-		defer sshClient.Close()
+		ns.IP = config.StaticIP
+	}
 
-		newSFTPClient, err = ns.CreateSFTPClient(sshClient)
+	// Set up the WireGuard tunnel in case network encryption is enabled.
+	if d.Configuration.NetworkEncryption {
+		err = ns.EnableWireGuard(sshClient, sftpClient)
 
 		if err != nil {
-			return err
-		}
+			ns.Delete()
 
-		defer newSFTPClient.Close()
+			return nil, false, err
+		}
 	}
 
-	dir := filepath.Dir(filePath)
-	err := newSFTPClient.MkdirAll(dir)
+	// Set up the TLS listener in case the TLS transport is requested.
+	if ns.Transport == nsTransportTLS {
+		err = ns.EnableStunnel(sshClient, sftpClient)
 
-	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to create directory '%s' (id: %s)", dir, ns.ID)
+		if err != nil {
+			ns.Delete()
 
-		return err
+			return nil, false, err
+		}
 	}
 
-	remoteFile, err := newSFTPClient.Create(filePath)
+	// Install the node exporter so the server can be scraped by Prometheus, if enabled.
+	if d.Configuration.NodeExporter {
+		err = ns.EnableNodeExporter(sshClient, sftpClient)
 
-	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to create file '%s' (id: %s)", filePath, ns.ID)
+		if err != nil {
+			ns.Delete()
 
-		return err
+			return nil, false, err
+		}
 	}
 
-	defer remoteFile.Close()
-
-	_, err = remoteFile.ReadFrom(fileContents)
+	// Create the data disk.
+	err = ns.EnsureDisk(config.Size)
 
 	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to write file '%s' (id: %s)", filePath, ns.ID)
+		ns.Delete()
 
-		return err
+		return nil, false, err
 	}
 
-	return nil
-}
-
-// CreateSFTPClient creates an SFTP client.
-func (ns *NetworkStorage) CreateSFTPClient(sshClient *ssh.Client) (*sftp.Client, error) {
-	debugCloudAction(rtNetworkStorage, "Creating SFTP client (id: %s)", ns.ID)
-
-	var err error
+	emitEvent(d, config.Name, eventTypeNormal, "DiskAttached", "Attached a data disk of %d GB to storage server '%s'", config.Size, ns.Hostname)
 
-	newSSHClient := sshClient
+	// Authorize the configured cluster networks and the extra volume networks once at creation
+	// time, if any.
+	networkCIDRs := append(append([]string{}, d.Configuration.ClusterCIDRs...), config.ExtraAllowedCIDRs...)
 
-	if newSSHClient == nil {
-		newSSHClient, err = ns.CreateSSHClient()
+	if len(networkCIDRs) > 0 {
+		err = ns.AuthorizeNetworks(sshClient, sftpClient, networkCIDRs)
 
 		if err != nil {
-			debugCloudAction(rtNetworkStorage, "Failed to create SFTP client due to SSH errors (id: %s)", ns.ID)
+			ns.Delete()
 
-			return nil, err
+			return nil, false, err
 		}
 	}
 
-	sftpClient, err := sftp.NewClient(newSSHClient)
+	// Apply the NFS daemon and I/O tuning in case any tuning parameters were provided.
+	if config.NFSThreads > 0 || config.TCPSlotTableEntries > 0 || config.DiskScheduler != "" {
+		err = ns.ApplyTuning(config.NFSThreads, config.TCPSlotTableEntries, config.DiskScheduler)
 
-	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to create SFTP client (id: %s)", ns.ID)
+		if err != nil {
+			ns.Delete()
 
-		return nil, err
+			return nil, false, err
+		}
 	}
 
-	return sftpClient, nil
+	// Export the data disk as an iSCSI LUN in case the iSCSI transport is requested. The NFS
+	// verification is skipped since the data disk no longer backs the exported directory.
+	if ns.Transport == nsTransportISCSI {
+		err = ns.EnableISCSI(sshClient, sftpClient)
+
+		if err != nil {
+			ns.Delete()
+
+			return nil, false, err
+		}
+
+		return ns, false, nil
+	}
+
+	// Verify that the NFS service actually serves data before marking the volume as ready.
+	err = ns.VerifyExports()
+
+	if err != nil {
+		ns.Delete()
+
+		return nil, false, err
+	}
+
+	emitEvent(d, config.Name, eventTypeNormal, "ExportReady", "Verified the exports of storage server '%s'", ns.Hostname)
+
+	// Configure the SMB service with the provided credentials in case the SMB protocol is requested.
+	if config.Protocol == nsProtocolSMB {
+		err = ns.EnableSMB(sshClient, sftpClient, config.SMBUsername, config.SMBPassword)
+
+		if err != nil {
+			ns.Delete()
+
+			return nil, false, err
+		}
+	}
+
+	// Provision a replica server and configure replication and failover for highly available volumes.
+	if config.HA {
+		replicaConfig := *config
+		replicaConfig.HA = false
+		replicaConfig.Name = config.Name + nsReplicaSuffix
+
+		replica, _, err := createNetworkStorage(d, &replicaConfig)
+
+		if err != nil {
+			ns.Delete()
+
+			return nil, false, err
+		}
+
+		err = ns.EnableHA(replica)
+
+		if err != nil {
+			replica.Delete()
+			ns.Delete()
+
+			return nil, false, err
+		}
+	}
+
+	return ns, false, nil
+}
+
+// loadNetworkStorage initializes the network storage handler for the given volume.
+func loadNetworkStorage(d *Driver, id string) (ns *NetworkStorage, notFound bool, err error) {
+	server := clouddk.ServerBody{}
+
+	// Retrieve the server from the cache or from the API in case the cached entry has expired.
+	if cachedServer := d.serverCache.getByID(id); cachedServer != nil {
+		server = *cachedServer
+	} else {
+		res, err := d.CloudClient.DoRequest(
+			"GET",
+			fmt.Sprintf("cloudservers/%s", id),
+			new(bytes.Buffer),
+			[]int{200},
+			1,
+			1,
+		)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to load server (id: %s)", id)
+
+			// The response is nil when the request failed before reaching the API.
+			return nil, (res != nil && res.StatusCode == 404), err
+		}
+
+		err = json.NewDecoder(res.Body).Decode(&server)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		d.serverCache.set(&server)
+	}
+
+	if len(server.NetworkInterfaces) == 0 {
+		debugCloudAction(rtNetworkStorage, "Failed to load server due to lack of network interfaces (id: %s)", id)
+
+		return nil, false, fmt.Errorf("The server has no network interfaces (id: %s)", id)
+	}
+
+	ns = &NetworkStorage{
+		driver:   d,
+		Hostname: server.Hostname,
+		ID:       server.Identifier,
+		IP:       server.NetworkInterfaces[0].IPAddresses[0].Address,
+	}
+
+	// Prefer the private network for storage traffic in case the server has a private interface.
+	if privateIP := getServerPrivateIP(&server); privateIP != "" {
+		ns.IP = privateIP
+	}
+
+	// Sum the data disk sizes since large volumes span multiple striped disks. Mirror disks
+	// carry a dedicated label and do not contribute to the capacity.
+	for _, v := range server.Disks {
+		if v.Label == nsDiskLabel {
+			ns.Size += int(v.Size)
+		}
+
+		if v.Label == nsDiskLabelMirror {
+			ns.Redundancy = nsRedundancyMirror
+		}
+	}
+
+	return ns, false, nil
+}
+
+// importNetworkStorage imports a retained data disk into a new volume by renaming the retained
+// server back into a storage server owned by the given volume name.
+func importNetworkStorage(d *Driver, config *NetworkStorageConfig, tag string) (ns *NetworkStorage, notFound bool, err error) {
+	hostname := fmt.Sprintf(nsFormatHostname, config.Name)
+	retainedHostname := fmt.Sprintf(nsFormatRetainedHostname, tag)
+
+	// Serialize operations targeting the same volume name to avoid interleaving the import of a
+	// retained disk with the deletion of an old server sharing the hostname.
+	lockVolume(hostname)
+	defer unlockVolume(hostname)
+
+	server, notFound, err := getServerByHostname(d, retainedHostname)
+
+	if err != nil {
+		return nil, notFound, err
+	}
+
+	debugCloudAction(rtNetworkStorage, "Importing retained data disk (id: %s - hostname: %s)", server.Identifier, hostname)
+
+	// Preserve the retain-data-disk policy in case the new volume also uses it, keeping the
+	// owner record behind the policy marker.
+	label := hostname
+
+	if config.PVCName != "" {
+		label = fmt.Sprintf(nsFormatOwnerLabel, config.PVName, config.PVCNamespace, config.PVCName)
+	}
+
+	if config.RetainDataDisk {
+		if config.PVCName != "" {
+			label = nsLabelRetainDataDisk + " " + label
+		} else {
+			label = nsLabelRetainDataDisk
+		}
+	}
+
+	body := clouddk.ServerUpdateBody{
+		Hostname: hostname,
+		Label:    label,
+	}
+
+	reqBody := new(bytes.Buffer)
+	err = json.NewEncoder(reqBody).Encode(body)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = d.CloudClient.DoRequest(
+		"PUT",
+		fmt.Sprintf("cloudservers/%s", server.Identifier),
+		reqBody,
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to import retained data disk (id: %s)", server.Identifier)
+
+		return nil, false, err
+	}
+
+	d.serverCache.invalidate(server.Identifier, retainedHostname)
+
+	ns, _, err = loadNetworkStorage(d, server.Identifier)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	ns.Filesystem = config.Filesystem
+	ns.NFSVersion = config.NFSVersion
+	ns.Transport = config.Transport
+
+	return ns, false, nil
+}
+
+// AuthorizeNetworks grants the given networks access to the network storage.
+// This makes per-node access control unnecessary since every node within the networks is authorized at once.
+func (ns *NetworkStorage) AuthorizeNetworks(sshClient *ssh.Client, sftpClient *sftp.Client, cidrs []string) (err error) {
+	debugCloudAction(rtNetworkStorage, "Authorizing networks '%s' (id: %s)", strings.Join(cidrs, ","), ns.ID)
+
+	script := "#!/bin/sh\n"
+	exportCommands := []string{}
+
+	for _, cidr := range cidrs {
+		script += "ipset add networks " + cidr + " -exist\n"
+		exportCommands = append(exportCommands, "echo '/mnt/data\t"+cidr+"(rw,sync,no_subtree_check)' >> /etc/exports")
+	}
+
+	err = ns.CreateFile(sftpClient, nsPathNetworksScript, bytes.NewBufferString(script))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to authorize cluster networks due to script creation errors (id: %s)", ns.ID)
+
+		return err
+	}
+
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to authorize cluster networks due to SSH session errors (id: %s)", ns.ID)
+
+		return err
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput(
+		"chmod +x " + nsPathNetworksScript +
+			"&& " + nsPathNetworksScript +
+			"&& " + strings.Join(exportCommands, " && ") +
+			"&& exportfs -ra",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to authorize cluster networks (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// renderScript substitutes the configured NFS port assignments into the given script or configuration file.
+func (d *Driver) renderScript(script string) string {
+	script = strings.ReplaceAll(script, "\r", "")
+	script = strings.ReplaceAll(script, "{{DATA_DEVICE}}", d.Configuration.DataDevice)
+	script = strings.ReplaceAll(script, "{{NFS_LOCKD_PORT}}", strconv.Itoa(d.Configuration.NFSLockdPort))
+	script = strings.ReplaceAll(script, "{{NFS_MOUNTD_PORT}}", strconv.Itoa(d.Configuration.NFSMountdPort))
+	script = strings.ReplaceAll(script, "{{NFS_STATD_PORT}}", strconv.Itoa(d.Configuration.NFSStatdPort))
+
+	return script
+}
+
+// CreateFile creates a file on the server.
+func (ns *NetworkStorage) CreateFile(sftpClient *sftp.Client, filePath string, fileContents *bytes.Buffer) error {
+	debugCloudAction(rtNetworkStorage, "Creating file '%s' (id: %s)", filePath, ns.ID)
+
+	newSFTPClient := sftpClient
+
+	if newSFTPClient == nil {
+		sshClient, err := ns.CreateSSHClient()
+
+		if err != nil {
+			return err
+		}
+
+		defer sshClient.Close()
+
+		newSFTPClient, err = ns.CreateSFTPClient(sshClient)
+
+		if err != nil {
+			return err
+		}
+
+		defer newSFTPClient.Close()
+	}
+
+	dir := filepath.Dir(filePath)
+	err := newSFTPClient.MkdirAll(dir)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to create directory '%s' (id: %s)", dir, ns.ID)
+
+		return err
+	}
+
+	remoteFile, err := newSFTPClient.Create(filePath)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to create file '%s' (id: %s)", filePath, ns.ID)
+
+		return err
+	}
+
+	defer remoteFile.Close()
+
+	_, err = remoteFile.ReadFrom(fileContents)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to write file '%s' (id: %s)", filePath, ns.ID)
+
+		return err
+	}
+
+	return nil
+}
+
+// CreateSFTPClient creates an SFTP client.
+func (ns *NetworkStorage) CreateSFTPClient(sshClient *ssh.Client) (*sftp.Client, error) {
+	debugCloudAction(rtNetworkStorage, "Creating SFTP client (id: %s)", ns.ID)
+
+	var err error
+
+	newSSHClient := sshClient
+
+	if newSSHClient == nil {
+		newSSHClient, err = ns.CreateSSHClient()
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to create SFTP client due to SSH errors (id: %s)", ns.ID)
+
+			return nil, err
+		}
+	}
+
+	sftpClient, err := sftp.NewClient(newSSHClient)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to create SFTP client (id: %s)", ns.ID)
+
+		return nil, err
+	}
+
+	return sftpClient, nil
 }
 
 // CreateSSHClient establishes a new SSH connection to the server.
@@ -607,6 +1397,10 @@ func (ns *NetworkStorage) CreateSSHClient() (*ssh.Client, error) {
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}
 
+	if ns.driver.Configuration.JumpHostAddress != "" {
+		return ns.createProxiedSSHClient(sshConfig)
+	}
+
 	sshClient, err := ssh.Dial("tcp", ns.IP+":22", sshConfig)
 
 	if err != nil {
@@ -618,6 +1412,84 @@ func (ns *NetworkStorage) CreateSSHClient() (*ssh.Client, error) {
 	return sshClient, nil
 }
 
+// createProxiedSSHClient establishes a new SSH connection to the server through the configured
+// jump host.
+func (ns *NetworkStorage) createProxiedSSHClient(sshConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	c := ns.driver.Configuration
+
+	debugCloudAction(rtNetworkStorage, "Creating SSH client through jump host '%s' (id: %s)", c.JumpHostAddress, ns.ID)
+
+	jumpHostAddress := c.JumpHostAddress
+
+	if !strings.Contains(jumpHostAddress, ":") {
+		jumpHostAddress = jumpHostAddress + ":22"
+	}
+
+	jumpHostPrivateKey := c.JumpHostPrivateKey
+
+	if jumpHostPrivateKey == "" {
+		jumpHostPrivateKey = c.PrivateKey
+	}
+
+	jumpHostPrivateKeySigner, err := ssh.ParsePrivateKey([]byte(jumpHostPrivateKey))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to create SSH client due to jump host private key errors (id: %s)", ns.ID)
+
+		return nil, err
+	}
+
+	jumpHostUser := c.JumpHostUser
+
+	if jumpHostUser == "" {
+		jumpHostUser = "root"
+	}
+
+	jumpHostConfig := &ssh.ClientConfig{
+		User:            jumpHostUser,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(jumpHostPrivateKeySigner)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+
+	jumpHostClient, err := ssh.Dial("tcp", jumpHostAddress, jumpHostConfig)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to create SSH client for the jump host (id: %s)", ns.ID)
+
+		return nil, err
+	}
+
+	conn, err := jumpHostClient.Dial("tcp", ns.IP+":22")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to create SSH client because the jump host cannot reach the server (id: %s)", ns.ID)
+
+		jumpHostClient.Close()
+
+		return nil, err
+	}
+
+	sshConn, sshChans, sshReqs, err := ssh.NewClientConn(conn, ns.IP+":22", sshConfig)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to create SSH client (id: %s)", ns.ID)
+
+		jumpHostClient.Close()
+
+		return nil, err
+	}
+
+	sshClient := ssh.NewClient(sshConn, sshChans, sshReqs)
+
+	// Close the connection to the jump host once the tunneled connection is closed.
+	go func() {
+		sshClient.Wait()
+		jumpHostClient.Close()
+	}()
+
+	return sshClient, nil
+}
+
 // CreateSSHSession creates an SSH session.
 func (ns *NetworkStorage) CreateSSHSession(sshClient *ssh.Client) (*ssh.Session, error) {
 	debugCloudAction(rtNetworkStorage, "Creating SSH session (id: %s)", ns.ID)
@@ -649,10 +1521,36 @@ func (ns *NetworkStorage) CreateSSHSession(sshClient *ssh.Client) (*ssh.Session,
 
 // Delete deletes the network storage.
 func (ns *NetworkStorage) Delete() (err error) {
+	// Refuse to delete servers which do not carry the storage server hostname prefix as a last
+	// line of defense against deleting an unrelated server.
+	if ns.Hostname != "" &&
+		!strings.HasPrefix(ns.Hostname, fmt.Sprintf(nsFormatHostname, "")) &&
+		!strings.HasPrefix(ns.Hostname, fmt.Sprintf(nsFormatPendingHostname, "")) {
+		return fmt.Errorf("Refusing to delete server '%s' because it is not a storage server (hostname: %s)", ns.ID, ns.Hostname)
+	}
+
+	// Delete the replica server of a highly available volume, if any.
+	if ns.Hostname != "" && !strings.HasSuffix(ns.Hostname, nsReplicaSuffix) {
+		replica, _, err := getServerByHostname(ns.driver, ns.Hostname+nsReplicaSuffix)
+
+		if err == nil {
+			replicaStorage := &NetworkStorage{
+				driver:   ns.driver,
+				Hostname: replica.Hostname,
+				ID:       replica.Identifier,
+			}
+
+			err = replicaStorage.Delete()
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	debugCloudAction(rtNetworkStorage, "Deleting server (id: %s)", ns.ID)
 
-	_, err = clouddk.DoClientRequest(
-		ns.driver.Configuration.ClientSettings,
+	_, err = ns.driver.CloudClient.DoRequest(
 		"DELETE",
 		fmt.Sprintf("cloudservers/%s", ns.ID),
 		new(bytes.Buffer),
@@ -661,68 +1559,371 @@ func (ns *NetworkStorage) Delete() (err error) {
 		10,
 	)
 
-	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to delete server (id: %s)", ns.ID)
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to delete server (id: %s)", ns.ID)
+
+		return err
+	}
+
+	ns.driver.serverCache.invalidate(ns.ID, ns.Hostname)
+
+	auditEvent("server-delete", ns.ID, "", "Deleted server '%s'", ns.Hostname)
+
+	return nil
+}
+
+// MarkPendingDeletion renames the server in order to schedule it for deletion once the given
+// grace period has passed. The rename frees the hostname for recreation while the deletion
+// deadline is recorded in the server label for the reaper to act on.
+func (ns *NetworkStorage) MarkPendingDeletion(gracePeriod int) (err error) {
+	hostname := fmt.Sprintf(nsFormatPendingHostname, strings.TrimPrefix(ns.Hostname, fmt.Sprintf(nsFormatHostname, "")))
+	deadline := time.Now().Unix() + int64(gracePeriod)
+
+	debugCloudAction(rtNetworkStorage, "Scheduling server for deletion (id: %s - deadline: %d)", ns.ID, deadline)
+
+	body := clouddk.ServerUpdateBody{
+		Hostname: hostname,
+		Label:    fmt.Sprintf(nsFormatPendingLabel, deadline),
+	}
+
+	reqBody := new(bytes.Buffer)
+	err = json.NewEncoder(reqBody).Encode(body)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = ns.driver.CloudClient.DoRequest(
+		"PUT",
+		fmt.Sprintf("cloudservers/%s", ns.ID),
+		reqBody,
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to schedule server for deletion (id: %s)", ns.ID)
+
+		return err
+	}
+
+	ns.driver.serverCache.invalidate(ns.ID, ns.Hostname)
+	ns.Hostname = hostname
+
+	auditEvent("server-schedule-deletion", ns.ID, "", "Scheduled server '%s' for deletion at %d", hostname, deadline)
+
+	return nil
+}
+
+// Retain renames the server in order to keep its data disk after the volume has been deleted.
+// The rename frees the hostname for recreation while the volume name remains part of the
+// retained hostname so that the disk can be imported into a new volume.
+func (ns *NetworkStorage) Retain() (err error) {
+	hostname := fmt.Sprintf(nsFormatRetainedHostname, strings.TrimPrefix(ns.Hostname, fmt.Sprintf(nsFormatHostname, "")))
+
+	debugCloudAction(rtNetworkStorage, "Retaining server (id: %s - hostname: %s)", ns.ID, hostname)
+
+	body := clouddk.ServerUpdateBody{
+		Hostname: hostname,
+		Label:    nsLabelRetainDataDisk,
+	}
+
+	reqBody := new(bytes.Buffer)
+	err = json.NewEncoder(reqBody).Encode(body)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = ns.driver.CloudClient.DoRequest(
+		"PUT",
+		fmt.Sprintf("cloudservers/%s", ns.ID),
+		reqBody,
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to retain server (id: %s)", ns.ID)
+
+		return err
+	}
+
+	ns.driver.serverCache.invalidate(ns.ID, ns.Hostname)
+	ns.Hostname = hostname
+
+	return nil
+}
+
+// EnsureDisk ensures that the server has a data disk of the specified size.
+func (ns *NetworkStorage) EnsureDisk(size int) (err error) {
+	debugCloudAction(rtNetworkStorage, "Ensuring disk (id: %s - size: %d GB)", ns.ID, size)
+
+	// Wait for all transactions to end before proceeding.
+	err = ns.Wait()
+
+	if err != nil {
+		return err
+	}
+
+	// Retrieve the list of disks attached to the server and determine if a data disk is present.
+	res, err := ns.driver.CloudClient.DoRequest(
+		"GET",
+		fmt.Sprintf("cloudservers/%s/disks", ns.ID),
+		new(bytes.Buffer),
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to retrieve list of disks (id: %s)", ns.ID)
+
+		return err
+	}
+
+	diskList := clouddk.DiskListBody{}
+	err = json.NewDecoder(res.Body).Decode(&diskList)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to decode list of disks (id: %s)", ns.ID)
+
+		return err
+	}
+
+	// Mirrored volumes use two disks of the full capacity assembled as a RAID1 array.
+	if ns.Redundancy == nsRedundancyMirror {
+		return ns.ensureMirroredDisks(size, diskList)
+	}
+
+	// Spread the capacity across multiple disks in case it exceeds what a single disk supports.
+	diskCount := (size + maximumDiskCapacityInGigabytes - 1) / maximumDiskCapacityInGigabytes
+	diskSize := (size + diskCount - 1) / diskCount
+
+	disksFound := 0
+	dataDisks := []clouddk.DiskBody{}
+
+	for _, v := range diskList {
+		if v.Label == nsDiskLabel {
+			disksFound++
+			dataDisks = append(dataDisks, v)
+		}
+	}
+
+	// Grow the existing data disks in case a previous attempt created smaller ones, which would
+	// otherwise leave the volume silently undersized. Shrinking is refused since the filesystem
+	// may already hold more data than the requested capacity.
+	disksGrown := []int{}
+
+	for i, v := range dataDisks {
+		if int(v.Size) > diskSize {
+			return fmt.Errorf(
+				"The existing data disk of %d GB exceeds the requested %d GB and cannot be shrunk (id: %s)",
+				int(v.Size),
+				diskSize,
+				ns.ID,
+			)
+		}
+
+		if int(v.Size) == diskSize {
+			continue
+		}
+
+		debugCloudAction(rtNetworkStorage, "Growing data disk (id: %s - current: %d GB - size: %d GB)", ns.ID, int(v.Size), diskSize)
+		auditEvent("disk-grow", ns.ID, "", "Grew a data disk from %d GB to %d GB", int(v.Size), diskSize)
+
+		updateBody := clouddk.DiskCreateBody{
+			Label: nsDiskLabel,
+			Size:  clouddk.CustomInt(diskSize),
+		}
+
+		reqBody := new(bytes.Buffer)
+		err = json.NewEncoder(reqBody).Encode(updateBody)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = ns.driver.CloudClient.DoRequest(
+			"PUT",
+			fmt.Sprintf("cloudservers/%s/disks/%s", ns.ID, v.Identifier),
+			reqBody,
+			[]int{200},
+			1,
+			1,
+		)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to grow data disk (id: %s)", ns.ID)
+
+			return err
+		}
+
+		err = ns.Wait()
+
+		if err != nil {
+			return err
+		}
+
+		disksGrown = append(disksGrown, i)
+	}
+
+	// Resize the filesystem to cover the grown disks in case one exists already. Freshly created
+	// disks are formatted later in the provisioning flow and are skipped by the guards.
+	if len(disksGrown) > 0 {
+		command := "if blkid /dev/vdb > /dev/null 2>&1 && ! vgs data > /dev/null 2>&1; then resize2fs /dev/vdb; fi"
+
+		if diskCount > 1 {
+			devices := make([]string, len(disksGrown))
+
+			for i, index := range disksGrown {
+				devices[i] = fmt.Sprintf("/dev/vd%c", 'b'+index)
+			}
+
+			command = fmt.Sprintf(
+				"if vgs data > /dev/null 2>&1; then pvresize %s && lvextend -l +100%%FREE /dev/data/volume && resize2fs /dev/data/volume; fi",
+				strings.Join(devices, " "),
+			)
+		}
+
+		output, err := ns.driver.RemoteExecutor.Execute(ns, command)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to resize the filesystem after growing the data disks (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+			return err
+		}
+	}
+
+	// Create the missing data disks and wait for them to become attached.
+	for i := disksFound; i < diskCount; i++ {
+		debugCloudAction(rtNetworkStorage, "Creating data disk (id: %s - size: %d GB)", ns.ID, diskSize)
+		auditEvent("disk-create", ns.ID, "", "Created a data disk of %d GB", diskSize)
+
+		createBody := clouddk.DiskCreateBody{
+			Label: nsDiskLabel,
+			Size:  clouddk.CustomInt(diskSize),
+		}
+
+		reqBody := new(bytes.Buffer)
+		err = json.NewEncoder(reqBody).Encode(createBody)
+
+		if err != nil {
+			return err
+		}
+
+		res, err = ns.driver.CloudClient.DoRequest(
+			"POST",
+			fmt.Sprintf("cloudservers/%s/disks", ns.ID),
+			reqBody,
+			[]int{200},
+			1,
+			1,
+		)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to create data disk (id: %s)", ns.ID)
+
+			return err
+		}
+
+		disk := clouddk.DiskBody{}
+		err = json.NewDecoder(res.Body).Decode(&disk)
+
+		if err != nil {
+			return err
+		}
 
-		return err
+		err = ns.Wait()
+
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
-}
+	// Assemble the data disks into a striped logical volume in case the capacity spans multiple
+	// disks, which also improves the aggregate throughput.
+	if diskCount > 1 {
+		devices := make([]string, diskCount)
 
-// EnsureDisk ensures that the server has a data disk of the specified size.
-func (ns *NetworkStorage) EnsureDisk(size int) (err error) {
-	debugCloudAction(rtNetworkStorage, "Ensuring disk (id: %s - size: %d GB)", ns.ID, size)
+		for i := 0; i < diskCount; i++ {
+			devices[i] = fmt.Sprintf("/dev/vd%c", 'b'+i)
+		}
 
-	// Wait for all transactions to end before proceeding.
-	err = ns.Wait()
+		deviceList := strings.Join(devices, " ")
+
+		debugCloudAction(rtNetworkStorage, "Assembling striped data disks (id: %s - disks: %d)", ns.ID, diskCount)
+
+		output, err := ns.driver.RemoteExecutor.Execute(
+			ns,
+			"export DEBIAN_FRONTEND=noninteractive"+
+				"&& (command -v pvcreate > /dev/null 2>&1 || (apt-get -qq update && apt-get -qq install -y lvm2))"+
+				"&& if ! vgs data > /dev/null 2>&1; then"+
+				" pvcreate "+deviceList+
+				" && vgcreate data "+deviceList+
+				" && lvcreate -y -i "+strconv.Itoa(diskCount)+" -l 100%FREE -n volume data"+
+				" && mkfs -t ext4 /dev/data/volume;"+
+				" fi"+
+				"&& if ! mountpoint -q /mnt/data; then"+
+				" mkdir -p /mnt/data"+
+				" && (grep -q '/dev/data/volume' /etc/fstab || echo '/dev/data/volume /mnt/data ext4 defaults,noatime,nodiratime,nofail 0 2' >> /etc/fstab)"+
+				" && mount /dev/data/volume /mnt/data"+
+				" && chown -R nobody:nogroup /mnt/data;"+
+				" fi",
+		)
 
-	if err != nil {
-		return err
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to assemble striped data disks (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+			return err
+		}
+
+		return nil
 	}
 
-	// Retrieve the list of disks attached to the server and determine if a data disk is present.
-	res, err := clouddk.DoClientRequest(
-		ns.driver.Configuration.ClientSettings,
-		"GET",
-		fmt.Sprintf("cloudservers/%s/disks", ns.ID),
-		new(bytes.Buffer),
-		[]int{200},
-		1,
-		1,
-	)
+	// Mount the data disk, if necessary.
+	debugCloudAction(rtNetworkStorage, "Mounting data disk (id: %s)", ns.ID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, "/bin/sh "+nsPathMountScript)
 
 	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to retrieve list of disks (id: %s)", ns.ID)
+		debugCloudAction(rtNetworkStorage, "Failed to mount data disk (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
 
 		return err
 	}
 
-	diskList := clouddk.DiskListBody{}
-	err = json.NewDecoder(res.Body).Decode(&diskList)
-
-	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to decode list of disks (id: %s)", ns.ID)
+	return nil
+}
 
-		return err
+// ensureMirroredDisks ensures that the server has two data disks assembled as a RAID1 array,
+// protecting the volume data against single-disk failure at the cost of capacity.
+func (ns *NetworkStorage) ensureMirroredDisks(size int, diskList clouddk.DiskListBody) (err error) {
+	if size > maximumDiskCapacityInGigabytes {
+		return fmt.Errorf("The capacity of a mirrored volume cannot exceed %d GB", maximumDiskCapacityInGigabytes)
 	}
 
-	diskFound := false
+	// Create the missing disks and wait for them to become attached.
+	for _, label := range []string{nsDiskLabel, nsDiskLabelMirror} {
+		found := false
 
-	for _, v := range diskList {
-		if v.Label == nsDiskLabel {
-			diskFound = true
+		for _, v := range diskList {
+			if v.Label == label {
+				found = true
 
-			break
+				break
+			}
+		}
+
+		if found {
+			continue
 		}
-	}
 
-	// Create a new data disk and wait for it to become attached.
-	if !diskFound {
-		debugCloudAction(rtNetworkStorage, "Creating data disk (id: %s - size: %d GB)", ns.ID, size)
+		debugCloudAction(rtNetworkStorage, "Creating data disk (id: %s - label: %s - size: %d GB)", ns.ID, label, size)
 
 		createBody := clouddk.DiskCreateBody{
-			Label: nsDiskLabel,
+			Label: label,
 			Size:  clouddk.CustomInt(size),
 		}
 
@@ -733,8 +1934,7 @@ func (ns *NetworkStorage) EnsureDisk(size int) (err error) {
 			return err
 		}
 
-		res, err = clouddk.DoClientRequest(
-			ns.driver.Configuration.ClientSettings,
+		res, err := ns.driver.CloudClient.DoRequest(
 			"POST",
 			fmt.Sprintf("cloudservers/%s/disks", ns.ID),
 			reqBody,
@@ -763,42 +1963,74 @@ func (ns *NetworkStorage) EnsureDisk(size int) (err error) {
 		}
 	}
 
-	// Mount the data disk, if necessary.
-	sshSession, err := ns.CreateSSHSession(nil)
+	// Assemble the RAID1 array and mount it, if necessary.
+	debugCloudAction(rtNetworkStorage, "Assembling mirrored data disks (id: %s)", ns.ID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"export DEBIAN_FRONTEND=noninteractive"+
+			"&& (command -v mdadm > /dev/null 2>&1 || (apt-get -qq update && apt-get -qq install -y mdadm))"+
+			"&& if [ ! -e /dev/md0 ]; then"+
+			" mdadm --create /dev/md0 --level=1 --raid-devices=2 --metadata=1.2 --run /dev/vdb /dev/vdc"+
+			" && mkfs -t ext4 /dev/md0;"+
+			" fi"+
+			"&& if ! mountpoint -q /mnt/data; then"+
+			" mkdir -p /mnt/data"+
+			" && (grep -q '/dev/md0' /etc/fstab || echo '/dev/md0 /mnt/data ext4 defaults,noatime,nodiratime,nofail 0 2' >> /etc/fstab)"+
+			" && mount /dev/md0 /mnt/data"+
+			" && chown -R nobody:nogroup /mnt/data;"+
+			" fi",
+	)
 
 	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to ensure disk due to SSH session errors (id: %s)", ns.ID)
+		debugCloudAction(rtNetworkStorage, "Failed to assemble mirrored data disks (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
 
 		return err
 	}
 
-	defer sshSession.Close()
-
-	debugCloudAction(rtNetworkStorage, "Mounting data disk (id: %s)", ns.ID)
+	return nil
+}
 
-	output, err := sshSession.CombinedOutput("/bin/sh " + nsPathMountScript)
+// CheckMirrorDegraded determines if the RAID1 array of a mirrored volume is degraded.
+func (ns *NetworkStorage) CheckMirrorDegraded() (degraded bool, err error) {
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"if [ -e /dev/md0 ]; then (mdadm --detail --test /dev/md0 > /dev/null 2>&1 && echo healthy) || echo degraded; else echo none; fi",
+	)
 
 	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to mount data disk (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+		debugCloudAction(rtNetworkStorage, "Failed to check the mirror health (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
 
-		return err
+		return false, err
 	}
 
-	return nil
+	return strings.Contains(string(output), "degraded"), nil
 }
 
 // Mount mounts the network storage at the specified path.
-func (ns *NetworkStorage) Mount(path string) (err error) {
+func (ns *NetworkStorage) Mount(path string, options ...string) (err error) {
 	err = os.MkdirAll(path, 0750)
 
 	if err != nil {
 		return err
 	}
 
-	cmd := "mount"
+	// Pin the mount to the configured NFS protocol version to avoid cross-node inconsistencies.
+	version := ns.NFSVersion
+
+	if version == "" {
+		version = nsNFSVersion41
+	}
+
+	filesystemType := "nfs4"
+
+	if version == nsNFSVersion3 {
+		filesystemType = "nfs"
+	}
+
 	args := []string{}
 	opts := []string{
-		"nfsvers=4.1",
+		"nfsvers=" + version,
 		"actimeo=2",
 		"hard",
 		"intr",
@@ -811,12 +2043,22 @@ func (ns *NetworkStorage) Mount(path string) (err error) {
 		"wsize=65536",
 	}
 
-	args = append(args, "-t", "nfs4")
+	opts = append(opts, options...)
+
+	// Mount the default data directory unless an explicit export path is set, e.g. for
+	// external NFS servers.
+	exportPath := ns.ExportPath
+
+	if exportPath == "" {
+		exportPath = "/mnt/data"
+	}
+
+	args = append(args, "-t", filesystemType)
 	args = append(args, "-o", strings.Join(opts, ","))
-	args = append(args, ns.IP+":/mnt/data")
+	args = append(args, ns.IP+":"+exportPath)
 	args = append(args, path)
 
-	_, err = exec.Command(cmd, args...).CombinedOutput()
+	err = runMount(args...)
 
 	if err != nil {
 		return err
@@ -826,91 +2068,148 @@ func (ns *NetworkStorage) Mount(path string) (err error) {
 }
 
 // Publish grants a node access to the network storage.
-func (ns *NetworkStorage) Publish(nodeID string) error {
-	server, _, err := getServerByHostname(ns.driver.Configuration.ClientSettings, nodeID)
+func (ns *NetworkStorage) Publish(nodeID string) (publishContext map[string]string, err error) {
+	// Pre-provisioned servers are managed by the administrator, including their exports and
+	// firewall rules, so skip the per-node access control steps.
+	if ns.Hostname != "" && !strings.HasPrefix(ns.Hostname, fmt.Sprintf(nsFormatHostname, "")) {
+		return map[string]string{
+			pcDriverVersion: DriverVersion,
+		}, nil
+	}
+
+	server, _, err := getServerByNodeID(ns.driver, nodeID)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	// Use the hostname as the canonical node identifier regardless of the provided format.
+	nodeID = server.Hostname
+
 	if len(server.NetworkInterfaces) == 0 {
-		return fmt.Errorf("Node '%s' has no network interfaces", nodeID)
+		return nil, fmt.Errorf("Node '%s' has no network interfaces", nodeID)
 	}
 
-	// Grant the node access to the network storage.
-	sshClient, err := ns.CreateSSHClient()
+	nodeIP := server.NetworkInterfaces[0].IPAddresses[0].Address
 
-	if err != nil {
-		return err
+	// Prefer the private network address of the node in case it has a private interface.
+	if privateIP := getServerPrivateIP(server); privateIP != "" {
+		nodeIP = privateIP
 	}
 
-	defer sshClient.Close()
+	accessIP := nodeIP
 
-	sftpClient, err := ns.CreateSFTPClient(sshClient)
+	// Embed the controller version in the publish context so that the node plugins can detect version skew.
+	publishContext = map[string]string{
+		pcDriverVersion: DriverVersion,
+	}
 
-	if err != nil {
-		return err
+	// Authorize the node on the WireGuard tunnel in case network encryption is enabled.
+	// The NFS traffic will flow over the tunnel which means that access must be granted to the tunnel address instead.
+	if ns.driver.Configuration.NetworkEncryption {
+		nodePrivateKey, serverPublicKey, err := ns.AddWireGuardPeer(nodeIP)
+
+		if err != nil {
+			return nil, err
+		}
+
+		accessIP = getWireGuardPeerAddress(nodeIP)
+
+		publishContext[pcWireGuardEndpoint] = fmt.Sprintf("%s:%d", ns.IP, nsWireGuardPort)
+		publishContext[pcWireGuardNodeAddress] = accessIP
+		publishContext[pcWireGuardNodePrivateKey] = nodePrivateKey
+		publishContext[pcWireGuardServerAddress] = nsWireGuardServerAddress
+		publishContext[pcWireGuardServerPublicKey] = serverPublicKey
 	}
 
-	defer sftpClient.Close()
+	// Per-node access control is unnecessary when the cluster networks were authorized at creation
+	// time and the traffic does not flow over a WireGuard tunnel.
+	if len(ns.driver.Configuration.ClusterCIDRs) > 0 && !ns.driver.Configuration.NetworkEncryption {
+		return publishContext, nil
+	}
+
+	// Validate the values before embedding them in file names and shell commands.
+	if !isSafeNodeID(nodeID) {
+		return nil, fmt.Errorf("Invalid node id '%s'", nodeID)
+	}
+
+	if net.ParseIP(accessIP) == nil {
+		return nil, fmt.Errorf("Invalid access address '%s' for node '%s'", accessIP, nodeID)
+	}
+
+	// Revoke the access which was granted for a previous address in case the node was recreated
+	// with the same hostname but a new address.
+	if previousIP := getAttachmentIP(ns.ID, nodeID); previousIP != "" && previousIP != accessIP {
+		debugCloudAction(rtNetworkStorage, "Revoking access for the previous address of node '%s' (id: %s - previous: %s - current: %s)", nodeID, ns.ID, previousIP, accessIP)
+
+		err = ns.revokeAccess(nodeID, previousIP)
+
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	// Grant the node access to the network storage.
 	nodeNetworkScriptPath := fmt.Sprintf(nsFormatNodeNetworkScriptPath, nodeID)
 
-	err = ns.CreateFile(sftpClient, nodeNetworkScriptPath, bytes.NewBufferString(
+	err = ns.driver.FileUploader.Upload(ns, nodeNetworkScriptPath, bytes.NewBufferString(
 		"#!/bin/sh\n"+
-			"ipset add nodes "+server.NetworkInterfaces[0].IPAddresses[0].Address+"\n",
+			"ipset add nodes "+accessIP+" -exist\n",
 	))
 
 	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to grant access from node '%s' due to script creation errors (id: %s)", ns.ID)
+		debugCloudAction(rtNetworkStorage, "Failed to grant access from node '%s' due to script creation errors (id: %s)", nodeID, ns.ID)
 
-		return err
+		return nil, err
 	}
 
-	sshSession, err := ns.CreateSSHSession(sshClient)
+	// Persist the export in a driver owned fragment keyed by the node id so that republishing
+	// overwrites the existing entry instead of accumulating duplicate lines in /etc/exports.
+	nodeExportPath := fmt.Sprintf(nsFormatNodeExportPath, nodeID)
+
+	err = ns.driver.FileUploader.Upload(ns, nodeExportPath, bytes.NewBufferString(
+		"/mnt/data\t"+accessIP+"(rw,sync,no_subtree_check)\n",
+	))
 
 	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to grant access from node '%s' due to SSH session errors (id: %s)", ns.ID)
+		debugCloudAction(rtNetworkStorage, "Failed to grant access from node '%s' due to export creation errors (id: %s)", nodeID, ns.ID)
 
-		return err
+		return nil, err
 	}
 
-	defer sshSession.Close()
-
-	output, err := sshSession.CombinedOutput(
-		"chmod +x " + nodeNetworkScriptPath +
-			"&& " + nodeNetworkScriptPath +
-			"&& echo '/mnt/data\t" + server.NetworkInterfaces[0].IPAddresses[0].Address + "(rw,sync,no_subtree_check)' >> /etc/exports" +
-			"&& exportfs -ra",
+	// Apply the export with a per-entry exportfs call so that the clients of the existing
+	// exports are never interrupted.
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"chmod +x "+nodeNetworkScriptPath+
+			"&& "+nodeNetworkScriptPath+
+			"&& exportfs -o rw,sync,no_subtree_check "+accessIP+":/mnt/data",
 	)
 
 	if err != nil {
 		debugCloudAction(
 			rtNetworkStorage,
 			"Failed to grant access from node '%s' due to script errors (id: %s) - Output: %s - Error: %s",
+			nodeID,
 			ns.ID,
 			string(output),
 			err.Error(),
 		)
 
-		return err
+		return nil, err
 	}
 
-	return nil
+	recordExport(ns.ID, accessIP)
+	recordAttachment(ns.ID, nodeID, accessIP)
+
+	auditEvent("export-grant", ns.ID, nodeID, "Granted access from address '%s'", accessIP)
+
+	return publishContext, nil
 }
 
 // Unmount unmounts the network storage from the specified path.
 func (ns *NetworkStorage) Unmount(path string) (err error) {
-	cmd := "umount"
-	args := []string{path}
-
-	_, err = exec.Command(cmd, args...).CombinedOutput()
-
-	if err != nil {
-		return err
-	}
-
-	err = os.RemoveAll(path)
+	err = safeUnmount(path)
 
 	if err != nil {
 		return err
@@ -921,47 +2220,104 @@ func (ns *NetworkStorage) Unmount(path string) (err error) {
 
 // Unpublish revokes a node's access to the network storage.
 func (ns *NetworkStorage) Unpublish(nodeID string) error {
-	server, _, err := getServerByHostname(ns.driver.Configuration.ClientSettings, nodeID)
+	// Pre-provisioned servers are managed by the administrator, so there is no per-node access
+	// to revoke.
+	if ns.Hostname != "" && !strings.HasPrefix(ns.Hostname, fmt.Sprintf(nsFormatHostname, "")) {
+		return nil
+	}
+
+	server, notFound, err := getServerByNodeID(ns.driver, nodeID)
 
 	if err != nil {
-		return err
+		if !notFound {
+			return err
+		}
+
+		// The node no longer exists which means that its addresses can no longer be resolved.
+		// Revoke the recorded access instead of failing since the CSI specification requires
+		// unpublishing to succeed in this case in order to unblock volume deletion.
+		nodeID = trimProviderID(nodeID)
+		accessIP := getAttachmentIP(ns.ID, nodeID)
+
+		if accessIP == "" {
+			debugCloudAction(rtNetworkStorage, "Node '%s' no longer exists and no access is recorded (id: %s)", nodeID, ns.ID)
+
+			removeAttachmentRecord(ns.ID, nodeID)
+
+			return nil
+		}
+
+		debugCloudAction(rtNetworkStorage, "Node '%s' no longer exists and the recorded access is revoked instead (id: %s)", nodeID, ns.ID)
+
+		return ns.revokeAccess(nodeID, accessIP)
 	}
 
+	// Use the hostname as the canonical node identifier regardless of the provided format.
+	nodeID = server.Hostname
+
 	if len(server.NetworkInterfaces) == 0 {
 		return fmt.Errorf("Node '%s' has no network interfaces", nodeID)
 	}
 
-	// Revoke the node's access to the network storage.
-	sshClient, err := ns.CreateSSHClient()
+	nodeIP := server.NetworkInterfaces[0].IPAddresses[0].Address
 
-	if err != nil {
-		return err
+	// Prefer the private network address of the node in case it has a private interface.
+	if privateIP := getServerPrivateIP(server); privateIP != "" {
+		nodeIP = privateIP
 	}
 
-	defer sshClient.Close()
+	accessIP := nodeIP
 
-	nodeNetworkScriptPath := fmt.Sprintf(nsFormatNodeNetworkScriptPath, nodeID)
-	sshSession, err := ns.CreateSSHSession(sshClient)
+	// Per-node access control is unnecessary when the cluster networks were authorized at creation
+	// time and the traffic does not flow over a WireGuard tunnel.
+	if len(ns.driver.Configuration.ClusterCIDRs) > 0 && !ns.driver.Configuration.NetworkEncryption {
+		return nil
+	}
 
-	if err != nil {
-		debugCloudAction(rtNetworkStorage, "Failed to revoke access from node '%s' due to SSH session errors (id: %s)", ns.ID)
+	// Revoke the node's access to the WireGuard tunnel in case network encryption is enabled.
+	if ns.driver.Configuration.NetworkEncryption {
+		err = ns.RemoveWireGuardPeer(nodeIP)
 
-		return err
+		if err != nil {
+			return err
+		}
+
+		accessIP = getWireGuardPeerAddress(nodeIP)
 	}
 
-	defer sshSession.Close()
+	return ns.revokeAccess(nodeID, accessIP)
+}
 
-	output, err := sshSession.CombinedOutput(
-		"rm -f " + nodeNetworkScriptPath +
-			"&& ipset del nodes " + server.NetworkInterfaces[0].IPAddresses[0].Address +
-			"&& sed -i '/" + server.NetworkInterfaces[0].IPAddresses[0].Address + "/d' /etc/exports" +
-			"&& exportfs -ra",
+// revokeAccess revokes a node's access to the network storage using the given address.
+func (ns *NetworkStorage) revokeAccess(nodeID string, accessIP string) error {
+	// Validate the values before embedding them in file names and shell commands.
+	if !isSafeNodeID(nodeID) {
+		return fmt.Errorf("Invalid node id '%s'", nodeID)
+	}
+
+	if net.ParseIP(accessIP) == nil {
+		return fmt.Errorf("Invalid access address '%s' for node '%s'", accessIP, nodeID)
+	}
+
+	// Revoke the node's access to the network storage by removing its export fragment.
+	// The sed pattern is anchored to the full export path and address in order to avoid
+	// removing unrelated entries with a matching substring.
+	nodeExportPath := fmt.Sprintf(nsFormatNodeExportPath, nodeID)
+	nodeNetworkScriptPath := fmt.Sprintf(nsFormatNodeNetworkScriptPath, nodeID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"rm -f "+nodeNetworkScriptPath+" "+nodeExportPath+
+			"&& ipset del nodes "+accessIP+" -exist"+
+			"&& sed -i '\\#^/mnt/data\\t"+accessIP+"(#d' /etc/exports"+
+			"&& (exportfs -u "+accessIP+":/mnt/data 2> /dev/null || true)",
 	)
 
 	if err != nil {
 		debugCloudAction(
 			rtNetworkStorage,
 			"Failed to revoke access from node '%s' due to script errors (id: %s) - Output: %s - Error: %s",
+			nodeID,
 			ns.ID,
 			string(output),
 			err.Error(),
@@ -970,73 +2326,189 @@ func (ns *NetworkStorage) Unpublish(nodeID string) error {
 		return err
 	}
 
+	removeExportRecord(ns.ID, accessIP)
+	removeAttachmentRecord(ns.ID, nodeID)
+
+	auditEvent("export-revoke", ns.ID, nodeID, "Revoked access from address '%s'", accessIP)
+
+	return nil
+}
+
+// VerifyExports performs a functional check of the NFS service by temporarily exporting the data
+// directory to the server itself, writing a probe file through a loopback mount and reading it back.
+// The temporary export is revoked again regardless of the outcome.
+func (ns *NetworkStorage) VerifyExports() (err error) {
+	debugCloudAction(rtNetworkStorage, "Verifying that the NFS service serves data (id: %s)", ns.ID)
+
+	version := ns.NFSVersion
+
+	if version == "" {
+		version = nsNFSVersion41
+	}
+
+	filesystemType := "nfs4"
+
+	if version == nsNFSVersion3 {
+		filesystemType = "nfs"
+	}
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"exportfs -o rw,sync,no_subtree_check 127.0.0.1:/mnt/data"+
+			"&& mkdir -p /mnt/probe"+
+			"&& mount -t "+filesystemType+" -o nfsvers="+version+" 127.0.0.1:/mnt/data /mnt/probe"+
+			"&& echo probe > /mnt/probe/.clouddk_probe"+
+			"&& grep -q probe /mnt/data/.clouddk_probe"+
+			"&& rm -f /mnt/probe/.clouddk_probe"+
+			"; RESULT=$?"+
+			"; umount /mnt/probe 2>/dev/null"+
+			"; exportfs -u 127.0.0.1:/mnt/data 2>/dev/null"+
+			"; exit $RESULT",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to verify the NFS service (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return fmt.Errorf("The NFS service does not serve data (id: %s)", ns.ID)
+	}
+
+	return nil
+}
+
+// VerifyIdentity verifies that the server still matches the volume before destructive actions are performed.
+func (ns *NetworkStorage) VerifyIdentity() (err error) {
+	res, err := ns.driver.CloudClient.DoRequest(
+		"GET",
+		fmt.Sprintf("cloudservers/%s", ns.ID),
+		new(bytes.Buffer),
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	server := clouddk.ServerBody{}
+	err = json.NewDecoder(res.Body).Decode(&server)
+
+	if err != nil {
+		return err
+	}
+
+	if server.Identifier != ns.ID {
+		return fmt.Errorf("The server identity has changed (expected id: %s - actual id: %s)", ns.ID, server.Identifier)
+	}
+
+	if !strings.HasPrefix(server.Hostname, fmt.Sprintf(nsFormatHostname, "")) {
+		return fmt.Errorf("The server is not a storage server (id: %s - hostname: %s)", ns.ID, server.Hostname)
+	}
+
+	if ns.Hostname != "" && server.Hostname != ns.Hostname {
+		return fmt.Errorf("The server identity has changed (expected hostname: %s - actual hostname: %s)", ns.Hostname, server.Hostname)
+	}
+
 	return nil
 }
 
-// Wait waits for any pending and running transactions to end.
+// Wait waits for any pending and running transactions to end. Transactions which have already
+// ended unsuccessfully when the wait begins are ignored, since a historical failure from an
+// earlier operation would otherwise abort every subsequent wait on the server.
 func (ns *NetworkStorage) Wait() (err error) {
 	debugCloudAction(rtNetworkStorage, "Waiting for transactions to end (id: %s)", ns.ID)
 
-	timeDelay := int64(10)
 	timeMax := float64(600)
 	timeStart := time.Now()
 	timeElapsed := timeStart.Sub(timeStart)
 
-	wait := true
+	interval := float64(nsWaitIntervalInitialSeconds)
 
-	for timeElapsed.Seconds() < timeMax {
-		if int64(timeElapsed.Seconds())%timeDelay == 0 {
-			res, err := clouddk.DoClientRequest(
-				ns.driver.Configuration.ClientSettings,
-				"GET",
-				fmt.Sprintf("cloudservers/%s/logs", ns.ID),
-				new(bytes.Buffer),
-				[]int{200},
-				1,
-				1,
-			)
+	previouslyFailed := map[int]bool{}
+	previouslyFailedKnown := false
 
-			if err != nil {
-				debugCloudAction(rtNetworkStorage, "Failed to retrieve list of transactions (id: %s)", ns.ID)
+	for timeElapsed.Seconds() < timeMax {
+		res, err := ns.driver.CloudClient.DoRequest(
+			"GET",
+			fmt.Sprintf("cloudservers/%s/logs", ns.ID),
+			new(bytes.Buffer),
+			[]int{200},
+			1,
+			1,
+		)
 
-				return err
-			}
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to retrieve list of transactions (id: %s)", ns.ID)
 
-			logsList := clouddk.LogsListBody{}
-			err = json.NewDecoder(res.Body).Decode(&logsList)
+			return err
+		}
 
-			if err != nil {
-				return err
-			}
+		logsList := clouddk.LogsListBody{}
+		err = json.NewDecoder(res.Body).Decode(&logsList)
 
-			wait = false
+		if err != nil {
+			return err
+		}
 
-			// Determine if there are any pending or running transactions.
+		// Snapshot the transactions which had already ended unsuccessfully before this wait so
+		// that only newer failures abort it.
+		if !previouslyFailedKnown {
 			for _, v := range logsList {
-				if v.Status == "pending" || v.Status == "running" {
-					wait = true
-
-					break
+				switch v.Status {
+				case "failed", "cancelled":
+					previouslyFailed[int(v.Identifier)] = true
 				}
 			}
 
-			if !wait {
-				break
+			previouslyFailedKnown = true
+		}
+
+		failed := []string{}
+		wait := false
+
+		// Determine if there are any pending or running transactions while aborting
+		// immediately in case a transaction has ended unsuccessfully.
+		for _, v := range logsList {
+			switch v.Status {
+			case "pending", "running":
+				wait = true
+			case "failed", "cancelled":
+				if previouslyFailed[int(v.Identifier)] {
+					continue
+				}
+
+				debugCloudAction(rtNetworkStorage, "Transaction '%s' has ended unsuccessfully (id: %s - status: %s)", v.Action, ns.ID, v.Status)
+
+				failed = append(failed, fmt.Sprintf("'%s' (status: %s - created: %s)", v.Action, v.Status, v.CreatedAt))
 			}
+		}
 
-			time.Sleep(1 * time.Second)
+		// Attach the failed log entries to the error so the reason for the failure is visible in
+		// the events and API responses instead of only in the debug log.
+		if len(failed) > 0 {
+			storeDebugLog(ns.driver, "transactions-"+ns.ID, []byte(strings.Join(failed, "\n")+"\n"))
+
+			return fmt.Errorf("Transactions have ended unsuccessfully (id: %s) - Transactions: %s", ns.ID, strings.Join(failed, ", "))
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		if !wait {
+			return nil
+		}
 
-		timeElapsed = time.Now().Sub(timeStart)
-	}
+		// Wait for an exponentially increasing amount of time with random jitter in order
+		// to avoid hammering the API while transactions are in progress.
+		time.Sleep(time.Duration((interval + rand.Float64()*interval*nsWaitIntervalJitterFactor) * float64(time.Second)))
+
+		interval = interval * nsWaitBackoffFactor
 
-	if wait {
-		debugCloudAction(rtNetworkStorage, "Timeout while waiting for transactions to end (id: %s)", ns.ID)
+		if interval > nsWaitIntervalMaxSeconds {
+			interval = nsWaitIntervalMaxSeconds
+		}
 
-		return errors.New("Timeout while waiting for transactions to end")
+		timeElapsed = time.Now().Sub(timeStart)
 	}
 
-	return nil
+	debugCloudAction(rtNetworkStorage, "Timeout while waiting for transactions to end (id: %s)", ns.ID)
+
+	return errors.New("Timeout while waiting for transactions to end")
 }
@@ -6,15 +6,23 @@ package driver
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"os"
-	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+const (
+	// statfsTimeoutSeconds specifies the number of seconds to wait for filesystem statistics before a volume is considered abnormal.
+	statfsTimeoutSeconds = 10
+)
+
 // NodeServer implements the csi.NodeServer interface.
 type NodeServer struct {
 	driver *Driver
@@ -42,22 +50,93 @@ func (ns *NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetC
 // NodeGetInfo returns the supported capabilities of the node server.
 // This is used so the CO knows where to place the workload.
 // The result of this function will be used by the CO in ControllerPublishVolume.
+// The canonical node id is the Cloud.dk hostname of the node, although the controller
+// also accepts plain server ids and clouddk:// provider ids.
 func (ns *NodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
 	return &csi.NodeGetInfoResponse{
-		NodeId: ns.driver.Configuration.NodeID,
+		NodeId:            ns.driver.Configuration.NodeID,
+		MaxVolumesPerNode: int64(ns.driver.Configuration.MaxVolumesPerNode),
 	}, nil
 }
 
 // NodeGetVolumeStats returns the volume capacity statistics available for the the given volume.
 func (ns *NodeServer) NodeGetVolumeStats(ctx context.Context, in *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if in.VolumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "The Volume ID must be provided")
+	} else if in.VolumePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "The Volume Path must be provided")
+	}
+
+	type statfsResult struct {
+		stats syscall.Statfs_t
+		err   error
+	}
+
+	// Retrieve the filesystem statistics in a separate goroutine as the call can hang indefinitely when the storage server is unreachable.
+	resultChannel := make(chan statfsResult, 1)
+
+	go func() {
+		result := statfsResult{}
+		result.err = syscall.Statfs(in.VolumePath, &result.stats)
+		resultChannel <- result
+	}()
+
+	result := statfsResult{}
+
+	select {
+	case result = <-resultChannel:
+	case <-time.After(statfsTimeoutSeconds * time.Second):
+		return &csi.NodeGetVolumeStatsResponse{
+			VolumeCondition: &csi.VolumeCondition{
+				Abnormal: true,
+				Message:  "The filesystem statistics request timed out",
+			},
+		}, nil
+	}
+
+	if result.err != nil {
+		// A stale file handle or an I/O error indicates that the connection to the storage server is broken.
+		if errno, ok := result.err.(syscall.Errno); ok && (errno == syscall.ESTALE || errno == syscall.EIO || errno == syscall.ENOTCONN) {
+			return &csi.NodeGetVolumeStatsResponse{
+				VolumeCondition: &csi.VolumeCondition{
+					Abnormal: true,
+					Message:  result.err.Error(),
+				},
+			}, nil
+		}
+
+		return nil, status.Error(codes.Internal, result.err.Error())
+	}
+
+	stats := result.stats
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: []*csi.VolumeUsage{
+			{
+				Available: int64(stats.Bavail) * stats.Bsize,
+				Total:     int64(stats.Blocks) * stats.Bsize,
+				Used:      int64(stats.Blocks-stats.Bfree) * stats.Bsize,
+				Unit:      csi.VolumeUsage_BYTES,
+			},
+			{
+				Available: int64(stats.Ffree),
+				Total:     int64(stats.Files),
+				Used:      int64(stats.Files - stats.Ffree),
+				Unit:      csi.VolumeUsage_INODES,
+			},
+		},
+		VolumeCondition: &csi.VolumeCondition{
+			Abnormal: false,
+			Message:  "The volume is operational",
+		},
+	}, nil
 }
 
 // NodePublishVolume mounts the volume mounted to the staging path to the target path.
 func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
 	if req.VolumeId == "" {
 		return nil, status.Error(codes.InvalidArgument, "The Volume ID must be provided")
-	} else if req.StagingTargetPath == "" {
+	} else if req.StagingTargetPath == "" && !ns.driver.Configuration.SkipStaging {
 		return nil, status.Error(codes.InvalidArgument, "The Staging Target Path must be provided")
 	} else if req.TargetPath == "" {
 		return nil, status.Error(codes.InvalidArgument, "The Target Path must be provided")
@@ -65,26 +144,58 @@ func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		return nil, status.Error(codes.InvalidArgument, "The Volume Capability must be provided")
 	}
 
-	// Bind mount.
+	// Mount the volume directly at the target path in case the staging phase is disabled.
+	if ns.driver.Configuration.SkipStaging {
+		_, err := ns.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{
+			PublishContext:    req.PublishContext,
+			Secrets:           req.Secrets,
+			StagingTargetPath: req.TargetPath,
+			VolumeCapability:  req.VolumeCapability,
+			VolumeContext:     req.VolumeContext,
+			VolumeId:          req.VolumeId,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+
 	err := os.MkdirAll(req.TargetPath, 0750)
 
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	cmd := "mount"
-	args := []string{
-		"--bind",
-		req.StagingTargetPath,
-		req.TargetPath,
+	// Skip the bind mount in case the target path is already mounted and healthy, e.g. when kubelet
+	// retries a completed request. Corrupted mounts are unmounted so they can be mounted again.
+	mounted, err := recoverMountPoint(req.TargetPath)
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	_, err = exec.Command(cmd, args...).CombinedOutput()
+	if mounted {
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+
+	// Bind mount.
+	err = runMount("--bind", req.StagingTargetPath, req.TargetPath)
 
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	// Remount the bind mount as read-only, if requested, as the read-only flag is ignored during the initial bind mount.
+	if req.Readonly {
+		err = runMount("-o", "remount,ro,bind", req.TargetPath)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
@@ -100,6 +211,17 @@ func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return nil, status.Error(codes.InvalidArgument, "The Volume Capability must be provided")
 	}
 
+	// Detect version skew between the controller and node plugins before staging the volume.
+	err := checkVersionSkew(req.PublishContext[pcDriverVersion])
+
+	if err != nil {
+		if ns.driver.Configuration.StrictVersionSkew {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+
+		log.Printf("WARNING: %s", err.Error())
+	}
+
 	// Separate the concatenated volume type and ID and attempt to revoke the node's access to the volume.
 	volumeInfo := strings.Split(req.VolumeId, "-")
 
@@ -110,23 +232,184 @@ func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 	switch volumeInfo[0] {
 	case volumePrefixBlockStorage:
 		return nil, status.Error(codes.Unimplemented, "Block storage is not supported")
+	case volumePrefixExternal:
+		if req.VolumeContext[parameterServer] == "" {
+			return nil, status.Error(codes.InvalidArgument, "The volume context must contain the external NFS server")
+		}
+
+		// Skip the mount in case the staging path is already mounted and healthy, e.g. when kubelet
+		// retries a completed request. Corrupted mounts are unmounted so they can be mounted again.
+		mounted, err := recoverMountPoint(req.StagingTargetPath)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if mounted {
+			return &csi.NodeStageVolumeResponse{}, nil
+		}
+
+		// Mount the external NFS endpoint directly from the volume context since no storage
+		// server was provisioned for the volume.
+		external := &NetworkStorage{
+			driver:     ns.driver,
+			ExportPath: req.VolumeContext[parameterPath],
+			IP:         req.VolumeContext[parameterServer],
+			NFSVersion: req.VolumeContext[parameterNFSVersion],
+		}
+
+		mountOptions := []string{}
+
+		// Mount the volume as read-only, if requested.
+		if req.PublishContext[pcReadonly] == "true" {
+			mountOptions = append(mountOptions, "ro")
+		}
+
+		err = external.Mount(req.StagingTargetPath, mountOptions...)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		recordStagedMount(req.StagingTargetPath, external, mountOptions)
+
+		return &csi.NodeStageVolumeResponse{}, nil
 	case volumePrefixNetworkStorage:
-		ns, notFound, err := loadNetworkStorage(ns.driver, volumeInfo[1])
+		// Skip the mount in case the staging path is already mounted and healthy, e.g. when kubelet
+		// retries a completed request. Corrupted mounts are unmounted so they can be mounted again.
+		mounted, err := recoverMountPoint(req.StagingTargetPath)
 
 		if err != nil {
-			if notFound {
-				return nil, status.Error(codes.NotFound, "The volume does not exist")
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if mounted {
+			return &csi.NodeStageVolumeResponse{}, nil
+		}
+
+		// Stage from the address persisted in the volume context in order to avoid a cloud API
+		// lookup, falling back to the API for volumes created before the address was persisted.
+		ns := &NetworkStorage{
+			driver:     ns.driver,
+			ExportPath: req.VolumeContext[vcExportPath],
+			ID:         volumeInfo[1],
+			IP:         req.VolumeContext[vcServerIP],
+		}
+
+		// Prefer the DNS name of the server when one was registered, so address changes during
+		// rebuilds and migrations do not require remounting the volume on every node.
+		if name := req.VolumeContext[vcServerName]; name != "" {
+			ns.IP = name
+		}
+
+		if ns.IP == "" {
+			loaded, notFound, err := loadNetworkStorage(ns.driver, volumeInfo[1])
+
+			if err != nil {
+				if notFound {
+					return nil, status.Error(codes.NotFound, "The volume does not exist")
+				}
+
+				return nil, status.Error(codes.Internal, err.Error())
 			}
 
-			return nil, status.Error(codes.Internal, err.Error())
+			ns = loaded
+		}
+
+		// Mount the network storage over a WireGuard tunnel in case network encryption is enabled.
+		if req.PublishContext[pcWireGuardEndpoint] != "" {
+			err = setupWireGuardTunnel(volumeInfo[1], req.PublishContext)
+
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			ns.IP = req.PublishContext[pcWireGuardServerAddress]
+		}
+
+		mountOptions := []string{}
+
+		// Mount the volume as read-only, if requested.
+		if req.PublishContext[pcReadonly] == "true" {
+			mountOptions = append(mountOptions, "ro")
+		}
+
+		// Mount the volume with CIFS using the provided credentials in case the SMB protocol is requested.
+		if req.VolumeContext[parameterProtocol] == nsProtocolSMB {
+			if req.Secrets[secretUsername] == "" || req.Secrets[secretPassword] == "" {
+				return nil, status.Error(codes.InvalidArgument, "The SMB protocol requires the username and password secrets")
+			}
+
+			err = mountSMBShare(ns.IP, req.StagingTargetPath, req.Secrets[secretUsername], req.Secrets[secretPassword], mountOptions...)
+
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			return &csi.NodeStageVolumeResponse{}, nil
+		}
+
+		// Log in to the iSCSI target and mount the LUN directly in case the iSCSI transport is requested.
+		if req.VolumeContext[parameterTransport] == nsTransportISCSI {
+			device, err := setupISCSIClient(volumeInfo[1], ns.IP)
+
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			err = mountISCSIDevice(device, req.StagingTargetPath, mountOptions...)
+
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			return &csi.NodeStageVolumeResponse{}, nil
+		}
+
+		// Merge the explicitly requested mount flags with the options of the selected profile,
+		// letting the explicit flags take precedence.
+		mountOptions = append(mountOptions, req.VolumeCapability.GetMount().GetMountFlags()...)
+
+		// Open multiple TCP connections per mount in case a connection count was selected.
+		if req.VolumeContext[parameterNconnect] != "" {
+			mountOptions = append(mountOptions, "nconnect="+req.VolumeContext[parameterNconnect])
+		}
+
+		if profile := req.VolumeContext[parameterProfile]; profile != "" {
+			mountOptions = applyMountProfile(profile, mountOptions)
 		}
 
-		err = ns.Mount(req.StagingTargetPath)
+		// Drop the options which the kernel of the node does not support.
+		mountOptions = filterMountOptions(mountOptions)
+
+		// Pin the mount to the NFS version selected when the volume was created.
+		ns.NFSVersion = req.VolumeContext[parameterNFSVersion]
+
+		// The NFSv3 mount protocol requires the port of the mount daemon.
+		if ns.NFSVersion == nsNFSVersion3 && req.VolumeContext[vcNFSMountdPort] != "" {
+			mountOptions = append(mountOptions, "mountport="+req.VolumeContext[vcNFSMountdPort])
+		}
+
+		// Mount the network storage through a TLS channel in case the TLS transport is requested.
+		if req.VolumeContext[parameterTransport] == nsTransportTLS {
+			port, err := setupStunnelClient(volumeInfo[1], ns.IP)
+
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			ns.IP = "127.0.0.1"
+			mountOptions = append(mountOptions, fmt.Sprintf("port=%d", port))
+		}
+
+		err = ns.Mount(req.StagingTargetPath, mountOptions...)
 
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 
+		recordStagedMount(req.StagingTargetPath, ns, mountOptions)
+
 		return &csi.NodeStageVolumeResponse{}, nil
 	default:
 		return nil, status.Error(codes.InvalidArgument, "Invalid volume type")
@@ -141,17 +424,24 @@ func (ns *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 		return nil, status.Error(codes.InvalidArgument, "The Target Path must be provided")
 	}
 
-	// Unbind mount.
-	cmd := "umount"
-	args := []string{req.TargetPath}
+	// Unmount the volume from the target path directly in case the staging phase is disabled,
+	// since the unstage request which normally tears down tunnels and sessions is never issued.
+	if ns.driver.Configuration.SkipStaging {
+		_, err := ns.NodeUnstageVolume(ctx, &csi.NodeUnstageVolumeRequest{
+			StagingTargetPath: req.TargetPath,
+			VolumeId:          req.VolumeId,
+		})
 
-	_, err := exec.Command(cmd, args...).CombinedOutput()
+		if err != nil {
+			return nil, err
+		}
 
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return &csi.NodeUnpublishVolumeResponse{}, nil
 	}
 
-	err = os.RemoveAll(req.TargetPath)
+	// Unbind mount. The target path may already be gone or unmounted, e.g. when kubelet retries a
+	// partially completed request, and is only removed once it is verified to be unmounted.
+	err := safeUnmount(req.TargetPath)
 
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -178,18 +468,49 @@ func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 	switch volumeInfo[0] {
 	case volumePrefixBlockStorage:
 		return nil, status.Error(codes.Unimplemented, "Block storage is not supported")
+	case volumePrefixExternal:
+		external := &NetworkStorage{driver: ns.driver}
+		err := external.Unmount(req.StagingTargetPath)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		removeStagedMount(req.StagingTargetPath)
+
+		return &csi.NodeUnstageVolumeResponse{}, nil
 	case volumePrefixNetworkStorage:
-		ns, notFound, err := loadNetworkStorage(ns.driver, volumeInfo[1])
+		// Unmounting only requires the staging path, so the volume is not looked up in order to
+		// keep unstaging operational while the cloud API is unreachable.
+		ns := &NetworkStorage{
+			driver: ns.driver,
+			ID:     volumeInfo[1],
+		}
+
+		err := ns.Unmount(req.StagingTargetPath)
 
 		if err != nil {
-			if notFound {
-				return nil, status.Error(codes.NotFound, "The volume does not exist")
-			}
+			return nil, status.Error(codes.Internal, err.Error())
+		}
 
+		removeStagedMount(req.StagingTargetPath)
+
+		// Remove the WireGuard tunnel in case one was created for the volume.
+		err = teardownWireGuardTunnel(volumeInfo[1])
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		// Remove the TLS channel in case one was created for the volume.
+		err = teardownStunnelClient(volumeInfo[1])
+
+		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 
-		err = ns.Unmount(req.StagingTargetPath)
+		// Log out from the iSCSI target in case the volume uses the iSCSI transport.
+		err = teardownISCSIClient(volumeInfo[1])
 
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
@@ -0,0 +1,138 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// nsNodeExporterPort defines the port for the node exporter on a storage server.
+	nsNodeExporterPort = 9100
+
+	// nsPathNodeExporterScript defines the path to the node exporter setup script on a storage server.
+	nsPathNodeExporterScript = "/etc/clouddk_network_storage_node_exporter.sh"
+)
+
+// nsNodeExporterScript installs the node exporter on a storage server and restricts access to the
+// authorized nodes and networks.
+var nsNodeExporterScript = heredoc.Doc(`
+	#!/bin/sh
+	# Specify the required environment variables.
+	export DEBIAN_FRONTEND=noninteractive
+
+	# The address the node exporter should listen on is passed as the first argument.
+	LISTEN_ADDRESS="$1"
+
+	# Install the node exporter in case it is missing.
+	if ! command -v prometheus-node-exporter >/dev/null 2>&1; then
+		apt-get -qq update
+		apt-get -qq install -y prometheus-node-exporter
+	fi
+
+	# Bind the node exporter to the storage interface.
+	cat > /etc/default/prometheus-node-exporter << EOF
+	ARGS="--web.listen-address=${LISTEN_ADDRESS}:9100"
+	EOF
+
+	# Restrict the access to the authorized nodes and networks.
+	if ! iptables -C INPUT -p tcp --dport 9100 -j DROP 2> /dev/null; then
+		iptables -I INPUT -p tcp --dport 9100 -j DROP
+		iptables -I INPUT -p tcp --dport 9100 -m set --match-set nodes src -j ACCEPT
+		iptables -I INPUT -p tcp --dport 9100 -m set --match-set networks src -j ACCEPT
+	fi
+
+	# Enable and restart the node exporter.
+	systemctl enable prometheus-node-exporter
+	systemctl restart prometheus-node-exporter
+`)
+
+// scrapeTarget describes an entry in the Prometheus HTTP service discovery format.
+type scrapeTarget struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// EnableNodeExporter installs the node exporter on the storage server so the NFS fleet can be
+// monitored by Prometheus.
+func (ns *NetworkStorage) EnableNodeExporter(sshClient *ssh.Client, sftpClient *sftp.Client) (err error) {
+	debugCloudAction(rtNetworkStorage, "Enabling the node exporter (id: %s)", ns.ID)
+
+	err = ns.CreateFile(sftpClient, nsPathNodeExporterScript, bytes.NewBufferString(strings.ReplaceAll(nsNodeExporterScript, "\r", "")))
+
+	if err != nil {
+		return err
+	}
+
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput(fmt.Sprintf("/bin/sh %s '%s'", nsPathNodeExporterScript, ns.IP))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to enable the node exporter (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// scrapeTargetsHandler serves the node exporter scrape targets for the storage servers in the
+// Prometheus HTTP service discovery format.
+func scrapeTargetsHandler(d *Driver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverList, err := listServers(d, "")
+
+		if err != nil {
+			http.Error(w, "Failed to retrieve the list of servers", http.StatusInternalServerError)
+
+			return
+		}
+
+		hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+		targets := []scrapeTarget{}
+
+		for _, v := range serverList {
+			if !strings.HasPrefix(v.Hostname, hostnamePrefix) {
+				continue
+			}
+
+			ip := getServerPrivateIP(&v)
+
+			if ip == "" && len(v.NetworkInterfaces) > 0 && len(v.NetworkInterfaces[0].IPAddresses) > 0 {
+				ip = v.NetworkInterfaces[0].IPAddresses[0].Address
+			}
+
+			if ip == "" {
+				continue
+			}
+
+			targets = append(targets, scrapeTarget{
+				Targets: []string{fmt.Sprintf("%s:%d", ip, nsNodeExporterPort)},
+				Labels: map[string]string{
+					"hostname": v.Hostname,
+					"id":       v.Identifier,
+				},
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(targets)
+	}
+}
@@ -0,0 +1,37 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"github.com/pkg/sftp"
+)
+
+// nsPathOfflineBundle defines the path to the offline dependency bundle on a storage server.
+const nsPathOfflineBundle = "/root/clouddk_offline_bundle.tar.gz"
+
+// uploadOfflineBundle uploads the configured offline dependency bundle to the storage server so
+// the bootstrap script can install the dependencies without network access.
+func (ns *NetworkStorage) uploadOfflineBundle(sftpClient *sftp.Client) (err error) {
+	bundlePath := ns.driver.Configuration.OfflineBundlePath
+
+	if bundlePath == "" {
+		return nil
+	}
+
+	debugCloudAction(rtNetworkStorage, "Uploading offline dependency bundle (id: %s)", ns.ID)
+
+	bundle, err := ioutil.ReadFile(bundlePath)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to read the offline dependency bundle '%s' - Error: %s", bundlePath, err.Error())
+
+		return err
+	}
+
+	return ns.CreateFile(sftpClient, nsPathOfflineBundle, bytes.NewBuffer(bundle))
+}
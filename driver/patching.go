@@ -0,0 +1,217 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// patchingRebootTimeoutSeconds defines the number of seconds to wait for a server to accept SSH
+	// connections again after a reboot.
+	patchingRebootTimeoutSeconds = 300
+
+	// patchingScanIntervalSeconds defines the number of seconds between checks of the maintenance window.
+	patchingScanIntervalSeconds = 600
+)
+
+// nsPatchCommand applies the pending security updates on a storage server.
+const nsPatchCommand = "export DEBIAN_FRONTEND=noninteractive" +
+	"&& apt-get -qq update" +
+	"&& apt-get -qq -y -o Dpkg::Options::=--force-confold upgrade"
+
+// patchedServers tracks the servers which have already been patched within the current day so a
+// long maintenance window does not patch the same server twice.
+var patchedServers = map[string]string{}
+
+// validMaintenanceWindow determines if the given maintenance window uses the "HH:MM-HH:MM" format.
+func validMaintenanceWindow(window string) bool {
+	parts := strings.Split(window, "-")
+
+	if len(parts) != 2 {
+		return false
+	}
+
+	for _, part := range parts {
+		_, err := time.Parse("15:04", part)
+
+		if err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inMaintenanceWindow determines if the given time falls within the configured maintenance window.
+// The window uses the "HH:MM-HH:MM" format in UTC and may span midnight.
+func inMaintenanceWindow(window string, now time.Time) bool {
+	parts := strings.Split(window, "-")
+
+	if len(parts) != 2 {
+		return false
+	}
+
+	start, err := time.Parse("15:04", parts[0])
+
+	if err != nil {
+		return false
+	}
+
+	end, err := time.Parse("15:04", parts[1])
+
+	if err != nil {
+		return false
+	}
+
+	minutes := now.UTC().Hour()*60 + now.UTC().Minute()
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+
+	if startMinutes <= endMinutes {
+		return minutes >= startMinutes && minutes < endMinutes
+	}
+
+	return minutes >= startMinutes || minutes < endMinutes
+}
+
+// ApplyPatches installs the pending security updates on the storage server and reboots it in case
+// a kernel update requires it. Writes are flushed before the reboot and the exports are verified
+// once the server is reachable again.
+func (ns *NetworkStorage) ApplyPatches() (err error) {
+	debugCloudAction(rtNetworkStorage, "Applying security updates (id: %s)", ns.ID)
+
+	// Serialize operations targeting the same volume.
+	lockVolume(ns.Hostname)
+	defer unlockVolume(ns.Hostname)
+
+	output, err := ns.driver.RemoteExecutor.Execute(ns, nsPatchCommand)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to apply security updates (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	// Determine if the updates require a reboot.
+	_, err = ns.driver.RemoteExecutor.Execute(ns, "test -f /var/run/reboot-required")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "No reboot is required (id: %s)", ns.ID)
+
+		return nil
+	}
+
+	debugCloudAction(rtNetworkStorage, "Rebooting server after security updates (id: %s)", ns.ID)
+
+	// Flush the pending writes and reboot. The reboot command is detached so the SSH session does
+	// not report a spurious error when the connection is closed.
+	output, err = ns.driver.RemoteExecutor.Execute(ns, "sync && (sleep 2 && shutdown -r now) > /dev/null 2>&1 &")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to reboot server (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	// Wait for the server to accept SSH connections again before verifying its health.
+	time.Sleep(30 * time.Second)
+
+	timeStart := time.Now()
+
+	for {
+		_, err = ns.driver.RemoteExecutor.Execute(ns, "true")
+
+		if err == nil {
+			break
+		}
+
+		if time.Since(timeStart).Seconds() > patchingRebootTimeoutSeconds {
+			debugCloudAction(rtNetworkStorage, "Failed to reach server after the reboot (id: %s)", ns.ID)
+
+			return fmt.Errorf("The server did not come back within %d seconds after the reboot (id: %s)", patchingRebootTimeoutSeconds, ns.ID)
+		}
+
+		time.Sleep(10 * time.Second)
+	}
+
+	err = ns.VerifyHealth()
+
+	if err != nil {
+		return err
+	}
+
+	debugCloudAction(rtNetworkStorage, "Successfully applied security updates (id: %s)", ns.ID)
+
+	return nil
+}
+
+// patchStorageServers applies the pending security updates to the storage servers one at a time
+// while the maintenance window remains open.
+func patchStorageServers(d *Driver) {
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to patch the storage servers due to API errors")
+
+		return
+	}
+
+	hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+	day := time.Now().UTC().Format("2006-01-02")
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, hostnamePrefix) {
+			continue
+		}
+
+		if patchedServers[v.Identifier] == day {
+			continue
+		}
+
+		// Stop patching as soon as the maintenance window closes.
+		if !inMaintenanceWindow(d.Configuration.MaintenanceWindow, time.Now()) {
+			return
+		}
+
+		ip := getServerPrivateIP(&v)
+
+		if ip == "" && len(v.NetworkInterfaces) > 0 && len(v.NetworkInterfaces[0].IPAddresses) > 0 {
+			ip = v.NetworkInterfaces[0].IPAddresses[0].Address
+		}
+
+		ns := &NetworkStorage{
+			driver:   d,
+			Hostname: v.Hostname,
+			ID:       v.Identifier,
+			IP:       ip,
+		}
+
+		err := ns.ApplyPatches()
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Aborting the patch run after a failure (id: %s) - Error: %s", ns.ID, err.Error())
+
+			notifyWebhook(d, "Failed to patch storage server '%s' (id: %s) - Reason: %s", ns.Hostname, ns.ID, err.Error())
+
+			return
+		}
+
+		patchedServers[v.Identifier] = day
+	}
+}
+
+// patchingLoop periodically patches the storage servers while the maintenance window is open.
+func patchingLoop(d *Driver) {
+	for {
+		time.Sleep(patchingScanIntervalSeconds * time.Second)
+
+		if inMaintenanceWindow(d.Configuration.MaintenanceWindow, time.Now()) {
+			patchStorageServers(d)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nsProbeCommand performs the functional checks on a storage server. The command succeeds when the
+// NFS service is running and the data disk is mounted, or when the data disk backs an iSCSI LUN
+// instead of an NFS share.
+const nsProbeCommand = "(pidof nfsd > /dev/null && mountpoint -q /mnt/data) || targetcli ls /backstores/block/data > /dev/null 2>&1"
+
+// nsProbeExportsCommand additionally requires a non-empty export list and is reserved for servers
+// with attachment or export records, since idle volumes legitimately export nothing.
+const nsProbeExportsCommand = "(pidof nfsd > /dev/null && mountpoint -q /mnt/data && [ -n \"$(exportfs)\" ]) || targetcli ls /backstores/block/data > /dev/null 2>&1"
+
+// serverHealth describes the result of the most recent health probe for a storage server.
+type serverHealth struct {
+	Checked time.Time
+	Healthy bool
+	Message string
+}
+
+var (
+	// serverHealthMap contains the most recent health probe results keyed by server ID.
+	serverHealthMap   = map[string]serverHealth{}
+	serverHealthMutex = sync.Mutex{}
+)
+
+// setServerHealth records the result of a health probe for a storage server.
+func setServerHealth(id string, healthy bool, message string) {
+	serverHealthMutex.Lock()
+	defer serverHealthMutex.Unlock()
+
+	serverHealthMap[id] = serverHealth{
+		Checked: time.Now(),
+		Healthy: healthy,
+		Message: message,
+	}
+}
+
+// getServerHealth retrieves the result of the most recent health probe for a storage server.
+func getServerHealth(id string) (health serverHealth, ok bool) {
+	serverHealthMutex.Lock()
+	defer serverHealthMutex.Unlock()
+
+	health, ok = serverHealthMap[id]
+
+	return health, ok
+}
+
+// probeStorageServers checks the health of each managed storage server and records the results
+// for the volume conditions and the metrics endpoint.
+func probeStorageServers(d *Driver) {
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to probe the storage servers due to API errors")
+
+		return
+	}
+
+	hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, hostnamePrefix) {
+			continue
+		}
+
+		if !bool(v.Booted) {
+			setServerHealth(v.Identifier, false, "The server is not booted")
+
+			continue
+		}
+
+		ip := getServerPrivateIP(&v)
+
+		if ip == "" && len(v.NetworkInterfaces) > 0 && len(v.NetworkInterfaces[0].IPAddresses) > 0 {
+			ip = v.NetworkInterfaces[0].IPAddresses[0].Address
+		}
+
+		if ip == "" {
+			setServerHealth(v.Identifier, false, "The server has no network addresses")
+
+			continue
+		}
+
+		ns := &NetworkStorage{
+			driver:   d,
+			Hostname: v.Hostname,
+			ID:       v.Identifier,
+			IP:       ip,
+		}
+
+		// Only require a non-empty export list when the volume is known to be published, since
+		// idle volumes legitimately export nothing.
+		command := nsProbeCommand
+
+		if len(getPublishedNodeIDs(v.Identifier)) > 0 || hasExportRecords(v.Identifier) {
+			command = nsProbeExportsCommand
+		}
+
+		output, err := d.RemoteExecutor.Execute(ns, command)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Detected an unhealthy server (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+			setServerHealth(v.Identifier, false, "The storage services failed the health probe")
+			recordProbeFailure(d, ns)
+
+			continue
+		}
+
+		setServerHealth(v.Identifier, true, "The volume is operational")
+		resetProbeFailures(v.Identifier)
+	}
+}
+
+// healthProbeLoop periodically probes the health of the storage servers.
+func healthProbeLoop(d *Driver) {
+	interval := time.Duration(d.Configuration.HealthProbeInterval) * time.Second
+
+	for {
+		time.Sleep(interval)
+		probeStorageServers(d)
+	}
+}
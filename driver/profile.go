@@ -0,0 +1,317 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// nsProfileVersion defines the version of the bootstrap/tuning profile which is shipped with this driver.
+	nsProfileVersion = 1
+
+	// nsPathProfileVersion defines the path to the file containing the profile version on a storage server.
+	nsPathProfileVersion = "/etc/clouddk_profile_version"
+)
+
+// nsProfileFiles maps the configuration files which are managed by the tuning profile to their
+// contents. The sysctl configuration is handled separately since it depends on the tuning profile
+// each server was provisioned with.
+var nsProfileFiles = map[string]*string{
+	nsPathFirewallScript: &nsFirewallScript,
+	nsPathLimitsConf:     &nsLimitsConf,
+}
+
+// GetProfileVersion retrieves the profile version from the storage server.
+func (ns *NetworkStorage) GetProfileVersion() (version int, err error) {
+	output, err := ns.driver.RemoteExecutor.Execute(ns, "cat "+nsPathProfileVersion+" 2>/dev/null || echo 0")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to retrieve the profile version (id: %s)", ns.ID)
+
+		return 0, err
+	}
+
+	version, err = strconv.Atoi(strings.TrimSpace(string(output)))
+
+	if err != nil {
+		return 0, fmt.Errorf("Invalid profile version '%s' (id: %s)", strings.TrimSpace(string(output)), ns.ID)
+	}
+
+	return version, nil
+}
+
+// UpgradeProfile applies the current tuning profile to the storage server.
+// The existing configuration files are backed up before the new ones are applied and restored again in case the server fails its health verification.
+func (ns *NetworkStorage) UpgradeProfile() (err error) {
+	debugCloudAction(rtNetworkStorage, "Upgrading profile to version %d (id: %s)", nsProfileVersion, ns.ID)
+
+	sshClient, err := ns.CreateSSHClient()
+
+	if err != nil {
+		return err
+	}
+
+	defer sshClient.Close()
+
+	sftpClient, err := ns.CreateSFTPClient(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	defer sftpClient.Close()
+
+	// Apply the sysctl configuration matching the tuning profile the server was provisioned
+	// with instead of forcing the aggressive defaults onto conservative or stock servers.
+	files := map[string]*string{}
+
+	for filePath, fileContents := range nsProfileFiles {
+		files[filePath] = fileContents
+	}
+
+	settings := ns.loadServerSettings()
+
+	switch settings["TUNING_PROFILE"] {
+	case nsTuningProfileConservative:
+		files[nsPathSysctlConf] = &nsSysctlConfConservative
+	case nsTuningProfileDefault:
+	default:
+		files[nsPathSysctlConf] = &nsSysctlConf
+	}
+
+	// Back up the configuration files before applying the new profile. Files which are absent
+	// on the server (e.g. the sysctl configuration of a stock profile) are skipped.
+	backupCommands := []string{}
+	restoreCommands := []string{}
+
+	for filePath := range files {
+		backupCommands = append(backupCommands, "{ [ ! -f "+filePath+" ] || cp -p "+filePath+" "+filePath+".bak; }")
+		restoreCommands = append(restoreCommands, "{ [ ! -f "+filePath+".bak ] || mv "+filePath+".bak "+filePath+"; }")
+	}
+
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	output, err := sshSession.CombinedOutput(strings.Join(backupCommands, " && "))
+
+	sshSession.Close()
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to back up the profile (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	// Upload the new configuration files and apply them.
+	for filePath, fileContents := range files {
+		err = ns.CreateFile(sftpClient, filePath, bytes.NewBufferString(ns.driver.renderScript(*fileContents)))
+
+		if err != nil {
+			ns.rollbackProfile(sshClient, restoreCommands)
+
+			return err
+		}
+	}
+
+	err = ns.CreateFile(sftpClient, nsPathProfileVersion, bytes.NewBufferString(fmt.Sprintf("%d\n", nsProfileVersion)))
+
+	if err != nil {
+		ns.rollbackProfile(sshClient, restoreCommands)
+
+		return err
+	}
+
+	err = ns.applyProfile(sshClient)
+
+	if err != nil {
+		ns.rollbackProfile(sshClient, restoreCommands)
+
+		return err
+	}
+
+	// Verify the health of the server and roll back the profile in case the verification fails.
+	err = ns.VerifyHealth()
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Rolling back profile due to failed health verification (id: %s)", ns.ID)
+
+		ns.rollbackProfile(sshClient, restoreCommands)
+
+		return err
+	}
+
+	debugCloudAction(rtNetworkStorage, "Successfully upgraded profile to version %d (id: %s)", nsProfileVersion, ns.ID)
+
+	return nil
+}
+
+// VerifyHealth verifies that the storage server is healthy and serving NFS requests.
+func (ns *NetworkStorage) VerifyHealth() (err error) {
+	debugCloudAction(rtNetworkStorage, "Verifying server health (id: %s)", ns.ID)
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"systemctl is-active nfs-kernel-server"+
+			"&& exportfs -s"+
+			"&& mountpoint -q /mnt/data",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to verify server health (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// VerifyNFSService determines if the NFS service is active, listening on the expected ports and
+// able to load its export list. The verification catches servers whose NFS service is broken
+// right after bootstrapping, since a volume backed by such a server hangs on the first mount
+// attempt.
+func (ns *NetworkStorage) VerifyNFSService() (err error) {
+	debugCloudAction(rtNetworkStorage, "Verifying the NFS service (id: %s)", ns.ID)
+
+	c := ns.driver.Configuration
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"systemctl is-active nfs-kernel-server"+
+			"&& ss -tln | grep -q ':2049 '"+
+			fmt.Sprintf("&& ss -tln | grep -q ':%d '", c.NFSMountdPort)+
+			fmt.Sprintf("&& ss -tln | grep -q ':%d '", c.NFSStatdPort)+
+			"&& exportfs -s > /dev/null",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to verify the NFS service (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return fmt.Errorf("The NFS service is not serving requests after bootstrapping (id: %s) - Output: %s", ns.ID, outputTail(output, outputTailLines))
+	}
+
+	return nil
+}
+
+// applyProfile loads the uploaded configuration files on the storage server.
+func (ns *NetworkStorage) applyProfile(sshClient *ssh.Client) (err error) {
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput(
+		"chmod +x /etc/network/if-up.d/*" +
+			"&& sysctl --system" +
+			"&& IFACE=eth0 " + nsPathFirewallScript,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to apply the profile (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// rollbackProfile restores the backed up configuration files on the storage server.
+func (ns *NetworkStorage) rollbackProfile(sshClient *ssh.Client, restoreCommands []string) {
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to roll back the profile due to SSH session errors (id: %s)", ns.ID)
+
+		return
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput(strings.Join(restoreCommands, " && ") + " && sysctl --system")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to roll back the profile (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+	}
+}
+
+// rolloutProfileUpgrades upgrades the profile on a percentage of the outdated storage servers.
+func rolloutProfileUpgrades(d *Driver, percentage int) (err error) {
+	debugCloudAction(rtNetworkStorage, "Rolling out profile upgrades to %d%% of the outdated servers", percentage)
+
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to retrieve the list of servers")
+
+		return err
+	}
+
+	// Determine which storage servers are running an outdated profile.
+	outdated := []*NetworkStorage{}
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, fmt.Sprintf(nsFormatHostname, "")) {
+			continue
+		}
+
+		if len(v.NetworkInterfaces) == 0 {
+			continue
+		}
+
+		ns := &NetworkStorage{
+			driver: d,
+			ID:     v.Identifier,
+			IP:     v.NetworkInterfaces[0].IPAddresses[0].Address,
+		}
+
+		version, err := ns.GetProfileVersion()
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Skipping server due to profile version errors (id: %s)", ns.ID)
+
+			continue
+		}
+
+		if version < nsProfileVersion {
+			outdated = append(outdated, ns)
+		}
+	}
+
+	if len(outdated) == 0 {
+		debugCloudAction(rtNetworkStorage, "All storage servers are running profile version %d", nsProfileVersion)
+
+		return nil
+	}
+
+	// Upgrade no more than the given percentage of the outdated servers and abort the rollout as soon as an upgrade fails.
+	batchSize := len(outdated) * percentage / 100
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	for i := 0; i < batchSize; i++ {
+		err = outdated[i].UpgradeProfile()
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Aborting profile rollout due to a failed upgrade (id: %s)", outdated[i].ID)
+
+			return err
+		}
+	}
+
+	return nil
+}
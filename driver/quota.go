@@ -0,0 +1,78 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"sync"
+)
+
+// quotaRecord describes the namespace and size of a provisioned volume.
+type quotaRecord struct {
+	Namespace string
+	Size      int
+}
+
+var (
+	// quotaRecords tracks the namespace and size of the volumes provisioned by this driver
+	// instance, keyed by the server identifier.
+	quotaRecords      = map[string]quotaRecord{}
+	quotaRecordsMutex = sync.Mutex{}
+)
+
+// checkNamespaceQuota verifies that provisioning the given capacity does not exceed the configured
+// quota for the namespace. The usage is tracked in memory from the volumes provisioned by this
+// driver instance as the cloud API has no notion of namespaces.
+func checkNamespaceQuota(d *Driver, namespace string, size int) error {
+	if d.Configuration.NamespaceQuota <= 0 || namespace == "" {
+		return nil
+	}
+
+	quotaRecordsMutex.Lock()
+	defer quotaRecordsMutex.Unlock()
+
+	usage := 0
+
+	for _, record := range quotaRecords {
+		if record.Namespace == namespace {
+			usage += record.Size
+		}
+	}
+
+	if usage+size > d.Configuration.NamespaceQuota {
+		return fmt.Errorf(
+			"The quota of %d GB for namespace '%s' would be exceeded (current: %d GB - requested: %d GB)",
+			d.Configuration.NamespaceQuota,
+			namespace,
+			usage,
+			size,
+		)
+	}
+
+	return nil
+}
+
+// recordNamespaceUsage adds a provisioned volume to the namespace usage tracking.
+func recordNamespaceUsage(id string, namespace string, size int) {
+	if namespace == "" {
+		return
+	}
+
+	quotaRecordsMutex.Lock()
+	defer quotaRecordsMutex.Unlock()
+
+	quotaRecords[id] = quotaRecord{
+		Namespace: namespace,
+		Size:      size,
+	}
+}
+
+// releaseNamespaceUsage removes a deleted volume from the namespace usage tracking.
+func releaseNamespaceUsage(id string) {
+	quotaRecordsMutex.Lock()
+	defer quotaRecordsMutex.Unlock()
+
+	delete(quotaRecords, id)
+}
@@ -0,0 +1,70 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// reaperIntervalSeconds defines the interval between passes of the deletion reaper.
+	reaperIntervalSeconds = 60
+)
+
+// reapPendingDeletions removes the servers whose deletion grace period has expired.
+func reapPendingDeletions(d *Driver) {
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to retrieve the list of servers pending deletion due to API errors")
+
+		return
+	}
+
+	labelPrefix := strings.TrimSuffix(fmt.Sprintf(nsFormatPendingLabel, 0), "0")
+	now := time.Now().Unix()
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, fmt.Sprintf(nsFormatPendingHostname, "")) {
+			continue
+		}
+
+		deadline, err := strconv.ParseInt(strings.TrimPrefix(v.Label, labelPrefix), 10, 64)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Skipping server pending deletion due to an unparsable deadline (id: %s - label: %s)", v.Identifier, v.Label)
+
+			continue
+		}
+
+		if now < deadline {
+			continue
+		}
+
+		ns := &NetworkStorage{
+			driver:   d,
+			ID:       v.Identifier,
+			Hostname: v.Hostname,
+		}
+
+		err = ns.Delete()
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to reap server pending deletion (id: %s)", ns.ID)
+		}
+	}
+}
+
+// deletionReaperLoop periodically removes the servers whose deletion grace period has expired.
+func deletionReaperLoop(d *Driver) {
+	for {
+		time.Sleep(reaperIntervalSeconds * time.Second)
+
+		reapPendingDeletions(d)
+	}
+}
@@ -0,0 +1,282 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// sanityServerID specifies the identifier of the storage server known to the fake Cloud.dk API.
+	sanityServerID = "fake01"
+
+	// sanityVolumeID specifies the volume id corresponding to the storage server known to the fake Cloud.dk API.
+	sanityVolumeID = "ns-fake01"
+)
+
+// newSanityAPIServer creates a fake Cloud.dk API which serves a single storage server.
+func newSanityAPIServer() *httptest.Server {
+	server := clouddk.ServerBody{
+		Identifier: sanityServerID,
+		Hostname:   "k8s-network-storage-sanity",
+		Disks: clouddk.DiskListBody{
+			{
+				Identifier: "disk01",
+				Label:      nsDiskLabel,
+				Size:       clouddk.CustomInt(16),
+				Primary:    clouddk.CustomBool(false),
+			},
+		},
+		NetworkInterfaces: clouddk.NetworkInterfaceListBody{
+			{
+				Identifier: "nic01",
+				Primary:    clouddk.CustomBool(true),
+				IPAddresses: clouddk.IPAddressListBody{
+					{
+						Address: "127.0.0.1",
+					},
+				},
+			},
+		},
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case req.URL.Path == "/cloudservers/"+sanityServerID:
+			json.NewEncoder(res).Encode(server)
+		case strings.HasPrefix(req.URL.Path, "/cloudservers") && req.Method == "GET":
+			if req.URL.Path == "/cloudservers" {
+				json.NewEncoder(res).Encode(clouddk.ServerListBody{server})
+
+				return
+			}
+
+			res.WriteHeader(404)
+			json.NewEncoder(res).Encode(clouddk.ErrorBody{Message: "Not Found", Status: clouddk.CustomInt(404)})
+		default:
+			res.WriteHeader(404)
+			json.NewEncoder(res).Encode(clouddk.ErrorBody{Message: "Not Found", Status: clouddk.CustomInt(404)})
+		}
+	}))
+}
+
+// newSanityDriver creates a driver which is backed by the fake Cloud.dk API.
+func newSanityDriver(t *testing.T, endpoint string) *Driver {
+	d, err := NewDriver(&Configuration{
+		ClientSettings: &clouddk.ClientSettings{
+			Endpoint: endpoint,
+			Key:      "sanity",
+		},
+		Endpoint:         "unix:///tmp/csi-sanity.sock",
+		NodeID:           "k8s-sanity-node",
+		PrivateKey:       "sanity",
+		PublicKey:        "sanity",
+		ServerMemory:     4096,
+		ServerProcessors: 2,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to initialize the driver - Error: %s", err.Error())
+	}
+
+	return d
+}
+
+// expectCode verifies that the given error carries the expected gRPC status code.
+func expectCode(t *testing.T, name string, err error, code codes.Code) {
+	if status.Code(err) != code {
+		t.Errorf("Unexpected status code for %s (expected: %s - actual: %s - error: %v)", name, code, status.Code(err), err)
+	}
+}
+
+// TestIdentityServer verifies that the identity service reports the driver metadata and capabilities.
+func TestIdentityServer(t *testing.T) {
+	api := newSanityAPIServer()
+	defer api.Close()
+
+	is := newIdentityServer(newSanityDriver(t, api.URL))
+
+	infoRes, err := is.GetPluginInfo(context.Background(), &csi.GetPluginInfoRequest{})
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve the plugin information - Error: %s", err.Error())
+	}
+
+	if infoRes.Name != DriverName || infoRes.VendorVersion != DriverVersion {
+		t.Errorf("Unexpected plugin information (name: %s - version: %s)", infoRes.Name, infoRes.VendorVersion)
+	}
+
+	probeRes, err := is.Probe(context.Background(), &csi.ProbeRequest{})
+
+	if err != nil || !probeRes.Ready.Value {
+		t.Errorf("The plugin is expected to always be ready (error: %v)", err)
+	}
+
+	capsRes, err := is.GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+
+	if err != nil || len(capsRes.Capabilities) == 0 {
+		t.Errorf("The plugin is expected to report its capabilities (error: %v)", err)
+	}
+}
+
+// TestControllerServerArguments verifies that the controller service rejects malformed requests with the status codes mandated by the CSI specification.
+func TestControllerServerArguments(t *testing.T) {
+	api := newSanityAPIServer()
+	defer api.Close()
+
+	cs := newControllerServer(newSanityDriver(t, api.URL))
+	ctx := context.Background()
+
+	volumeCapabilities := []*csi.VolumeCapability{
+		{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			},
+		},
+	}
+
+	_, err := cs.CreateVolume(ctx, &csi.CreateVolumeRequest{})
+	expectCode(t, "CreateVolume without a name", err, codes.InvalidArgument)
+
+	_, err = cs.CreateVolume(ctx, &csi.CreateVolumeRequest{Name: "sanity"})
+	expectCode(t, "CreateVolume without capabilities", err, codes.InvalidArgument)
+
+	_, err = cs.DeleteVolume(ctx, &csi.DeleteVolumeRequest{})
+	expectCode(t, "DeleteVolume without a volume id", err, codes.InvalidArgument)
+
+	_, err = cs.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "malformed"})
+	expectCode(t, "DeleteVolume with a malformed volume id", err, codes.InvalidArgument)
+
+	_, err = cs.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{VolumeId: sanityVolumeID})
+	expectCode(t, "ControllerPublishVolume without a node id", err, codes.InvalidArgument)
+
+	_, err = cs.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{NodeId: "k8s-sanity-node"})
+	expectCode(t, "ControllerPublishVolume without a volume id", err, codes.InvalidArgument)
+
+	_, err = cs.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{NodeId: "k8s-sanity-node"})
+	expectCode(t, "ControllerUnpublishVolume without a volume id", err, codes.InvalidArgument)
+
+	_, err = cs.ValidateVolumeCapabilities(ctx, &csi.ValidateVolumeCapabilitiesRequest{VolumeCapabilities: volumeCapabilities})
+	expectCode(t, "ValidateVolumeCapabilities without a volume id", err, codes.InvalidArgument)
+
+	_, err = cs.ValidateVolumeCapabilities(ctx, &csi.ValidateVolumeCapabilitiesRequest{VolumeId: sanityVolumeID})
+	expectCode(t, "ValidateVolumeCapabilities without capabilities", err, codes.InvalidArgument)
+
+	_, err = cs.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{})
+	expectCode(t, "CreateSnapshot", err, codes.Unimplemented)
+
+	_, err = cs.GetCapacity(ctx, &csi.GetCapacityRequest{})
+	expectCode(t, "GetCapacity", err, codes.Unimplemented)
+
+	_, err = cs.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: "malformed"})
+	expectCode(t, "ListVolumes with a malformed starting token", err, codes.Aborted)
+
+	_, err = cs.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{})
+	expectCode(t, "ControllerGetVolume without a volume id", err, codes.InvalidArgument)
+}
+
+// TestControllerServerFakeBackend verifies the controller behavior against the fake Cloud.dk API.
+func TestControllerServerFakeBackend(t *testing.T) {
+	api := newSanityAPIServer()
+	defer api.Close()
+
+	cs := newControllerServer(newSanityDriver(t, api.URL))
+	ctx := context.Background()
+
+	// Deleting a volume which no longer exists must succeed in order for the operation to be idempotent.
+	_, err := cs.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "ns-missing"})
+
+	if err != nil {
+		t.Errorf("DeleteVolume is expected to succeed for missing volumes (error: %v)", err)
+	}
+
+	// The capabilities of an existing volume must be confirmed.
+	validateRes, err := cs.ValidateVolumeCapabilities(ctx, &csi.ValidateVolumeCapabilitiesRequest{
+		VolumeId: sanityVolumeID,
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to validate the volume capabilities - Error: %s", err.Error())
+	}
+
+	if validateRes.Confirmed == nil || len(validateRes.Confirmed.VolumeCapabilities) != 1 {
+		t.Errorf("The volume capabilities are expected to be confirmed")
+	}
+
+	// The storage servers must be listed as volumes.
+	listRes, err := cs.ListVolumes(ctx, &csi.ListVolumesRequest{})
+
+	if err != nil {
+		t.Fatalf("Failed to list the volumes - Error: %s", err.Error())
+	}
+
+	if len(listRes.Entries) != 1 || listRes.Entries[0].Volume.VolumeId != sanityVolumeID {
+		t.Errorf("Unexpected volume listing (entries: %v)", listRes.Entries)
+	}
+
+	// An existing volume must be returned along with its attachment status.
+	getRes, err := cs.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: sanityVolumeID})
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve the volume - Error: %s", err.Error())
+	}
+
+	if getRes.Volume.VolumeId != sanityVolumeID || len(getRes.Status.PublishedNodeIds) != 0 {
+		t.Errorf("Unexpected volume information (id: %s - published nodes: %v)", getRes.Volume.VolumeId, getRes.Status.PublishedNodeIds)
+	}
+}
+
+// TestNodeServerArguments verifies that the node service rejects malformed requests with the status codes mandated by the CSI specification.
+func TestNodeServerArguments(t *testing.T) {
+	api := newSanityAPIServer()
+	defer api.Close()
+
+	nodeServer := newNodeServer(newSanityDriver(t, api.URL))
+	ctx := context.Background()
+
+	_, err := nodeServer.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{})
+	expectCode(t, "NodeStageVolume without a volume id", err, codes.InvalidArgument)
+
+	_, err = nodeServer.NodeUnstageVolume(ctx, &csi.NodeUnstageVolumeRequest{})
+	expectCode(t, "NodeUnstageVolume without a volume id", err, codes.InvalidArgument)
+
+	_, err = nodeServer.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{})
+	expectCode(t, "NodePublishVolume without a volume id", err, codes.InvalidArgument)
+
+	_, err = nodeServer.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{})
+	expectCode(t, "NodeUnpublishVolume without a volume id", err, codes.InvalidArgument)
+
+	_, err = nodeServer.NodeGetVolumeStats(ctx, &csi.NodeGetVolumeStatsRequest{})
+	expectCode(t, "NodeGetVolumeStats without a volume id", err, codes.InvalidArgument)
+
+	infoRes, err := nodeServer.NodeGetInfo(ctx, &csi.NodeGetInfoRequest{})
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve the node information - Error: %s", err.Error())
+	}
+
+	if infoRes.NodeId != "k8s-sanity-node" || infoRes.MaxVolumesPerNode != defaultMaxVolumesPerNode {
+		t.Errorf("Unexpected node information (id: %s - max volumes: %d)", infoRes.NodeId, infoRes.MaxVolumesPerNode)
+	}
+}
@@ -0,0 +1,209 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+const (
+	// idleScanIntervalSeconds defines the number of seconds between scans for idle storage servers.
+	idleScanIntervalSeconds = 3600
+
+	// nsLabelScaledDown defines the label marking a storage server which was downsized while idle.
+	nsLabelScaledDown = "scaled-down"
+)
+
+var (
+	// idleSince tracks when a storage server was first observed without published nodes.
+	idleSince      = map[string]time.Time{}
+	idleSinceMutex = sync.Mutex{}
+)
+
+// detectIdleServers downsizes the storage servers which have had no published nodes for the
+// configured number of days, reducing the cost of rarely used volumes. The servers are restored
+// to a suitable package the next time the volume is published.
+func detectIdleServers(d *Driver) {
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to scan for idle servers due to API errors")
+
+		return
+	}
+
+	hostnamePrefix := fmt.Sprintf(nsFormatHostname, "")
+	threshold := time.Duration(d.Configuration.IdleScaleDownDays) * 24 * time.Hour
+	now := time.Now()
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, hostnamePrefix) || strings.HasSuffix(v.Hostname, nsReplicaSuffix) {
+			continue
+		}
+
+		// Skip the servers which are published to at least one node or already downsized.
+		if len(getPublishedNodeIDs(v.Identifier)) > 0 {
+			idleSinceMutex.Lock()
+			delete(idleSince, v.Identifier)
+			idleSinceMutex.Unlock()
+
+			continue
+		}
+
+		// Skip the servers which are already downsized and the servers with a retain-data-disk
+		// policy, since DeleteVolume relies on the label to honor the policy.
+		if strings.HasPrefix(v.Label, nsLabelScaledDown) || strings.HasPrefix(v.Label, nsLabelRetainDataDisk) {
+			continue
+		}
+
+		ns, _, err := loadNetworkStorage(d, v.Identifier)
+
+		if err != nil {
+			continue
+		}
+
+		// The attachment records do not survive a controller restart, so a server without
+		// records is additionally probed for established NFS connections before it is
+		// considered idle. This prevents downsizing servers which are actively serving
+		// workloads published by a previous controller instance.
+		output, err := d.RemoteExecutor.Execute(ns, "ss -Htn state established '( sport = :2049 )' | wc -l")
+
+		if err != nil || strings.TrimSpace(string(output)) != "0" {
+			idleSinceMutex.Lock()
+			delete(idleSince, v.Identifier)
+			idleSinceMutex.Unlock()
+
+			continue
+		}
+
+		idleSinceMutex.Lock()
+		since, ok := idleSince[v.Identifier]
+
+		if !ok {
+			since = now
+			idleSince[v.Identifier] = since
+		}
+
+		idleSinceMutex.Unlock()
+
+		if now.Sub(since) < threshold {
+			continue
+		}
+
+		err = ns.ScaleDown()
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to scale down idle server (id: %s) - Error: %s", ns.ID, err.Error())
+
+			continue
+		}
+
+		idleSinceMutex.Lock()
+		delete(idleSince, v.Identifier)
+		idleSinceMutex.Unlock()
+	}
+}
+
+// idleScaleDownLoop periodically scans for idle storage servers.
+func idleScaleDownLoop(d *Driver) {
+	for {
+		time.Sleep(idleScanIntervalSeconds * time.Second)
+		detectIdleServers(d)
+	}
+}
+
+// ScaleDown moves the storage server to the smallest package and marks it as downsized. The
+// previous label is preserved behind the marker so that an owner record survives the
+// scale-down and can be restored along with the package.
+func (ns *NetworkStorage) ScaleDown() (err error) {
+	debugCloudAction(rtNetworkStorage, "Scaling down idle server (id: %s - hostname: %s)", ns.ID, ns.Hostname)
+
+	server, _, err := getServerByID(ns.driver, ns.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = ns.UpgradePackage(serverPackageIDs[0])
+
+	if err != nil {
+		return err
+	}
+
+	return ns.setLabel(strings.TrimSpace(nsLabelScaledDown + " " + server.Label))
+}
+
+// RestorePackage moves a downsized storage server back to a package suited for its capacity so
+// the volume can be served with reasonable performance again.
+func (ns *NetworkStorage) RestorePackage() (err error) {
+	debugCloudAction(rtNetworkStorage, "Restoring downsized server (id: %s - hostname: %s)", ns.ID, ns.Hostname)
+
+	server, _, err := getServerByID(ns.driver, ns.ID)
+
+	if err != nil {
+		return err
+	}
+
+	label := strings.TrimSpace(strings.TrimPrefix(server.Label, nsLabelScaledDown))
+
+	if label == "" {
+		label = ns.Hostname
+	}
+
+	packageID, err := getPackageID(expansionMemoryRequirement(ns.Size), ns.driver.Configuration.ServerProcessors)
+
+	if err != nil {
+		return err
+	}
+
+	err = ns.UpgradePackage(*packageID)
+
+	if err != nil {
+		return err
+	}
+
+	return ns.setLabel(label)
+}
+
+// setLabel updates the label of the storage server while keeping its hostname.
+func (ns *NetworkStorage) setLabel(label string) (err error) {
+	body := clouddk.ServerUpdateBody{
+		Hostname: ns.Hostname,
+		Label:    label,
+	}
+
+	reqBody := new(bytes.Buffer)
+	err = json.NewEncoder(reqBody).Encode(body)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = ns.driver.CloudClient.DoRequest(
+		"PUT",
+		fmt.Sprintf("cloudservers/%s", ns.ID),
+		reqBody,
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to update server label (id: %s)", ns.ID)
+
+		return err
+	}
+
+	ns.driver.serverCache.invalidate(ns.ID, ns.Hostname)
+
+	return nil
+}
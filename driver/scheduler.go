@@ -0,0 +1,204 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// snapshotScheduleCheckInterval defines the number of seconds between checks for due snapshot schedules.
+	snapshotScheduleCheckInterval = 60
+
+	// snapshotSchedulePrefix defines the file name prefix of the scheduled snapshot archives.
+	snapshotSchedulePrefix = "scheduled"
+)
+
+// snapshotSchedule describes the periodic snapshot configuration for a single volume.
+type snapshotSchedule struct {
+	// Interval contains the duration between two scheduled snapshots.
+	Interval time.Duration
+
+	// LastSnapshot contains the time the most recent scheduled snapshot was taken.
+	LastSnapshot time.Time
+
+	// Retention contains the number of scheduled snapshots to keep.
+	Retention int
+
+	// VolumeID contains the id of the volume the schedule applies to.
+	VolumeID string
+}
+
+var (
+	// snapshotSchedules contains the snapshot schedules keyed by the id of the storage server.
+	snapshotSchedules      = map[string]*snapshotSchedule{}
+	snapshotSchedulesMutex = sync.Mutex{}
+	snapshotSchedulerOnce  = sync.Once{}
+)
+
+// scheduleSnapshots registers a periodic snapshot schedule for the given volume and starts the
+// scheduler in case this is the first schedule.
+func scheduleSnapshots(d *Driver, serverID string, volumeID string, interval time.Duration, retention int) {
+	debugCloudAction(rtNetworkStorage, "Scheduling snapshots every %s with a retention of %d (id: %s)", interval, retention, serverID)
+
+	snapshotSchedulesMutex.Lock()
+	defer snapshotSchedulesMutex.Unlock()
+
+	snapshotSchedules[serverID] = &snapshotSchedule{
+		Interval: interval,
+		// Let the interval pass once before the first scheduled snapshot is taken since the volume
+		// was just provisioned and is still empty.
+		LastSnapshot: time.Now(),
+		Retention:    retention,
+		VolumeID:     volumeID,
+	}
+
+	snapshotSchedulerOnce.Do(func() {
+		go snapshotSchedulerLoop(d)
+	})
+}
+
+// unscheduleSnapshots removes the snapshot schedule for the given storage server, if any.
+func unscheduleSnapshots(serverID string) {
+	snapshotSchedulesMutex.Lock()
+	defer snapshotSchedulesMutex.Unlock()
+
+	delete(snapshotSchedules, serverID)
+}
+
+// snapshotSchedulerLoop periodically takes a snapshot of the volumes whose schedule is due and
+// prunes the archives which fall outside the retention.
+func snapshotSchedulerLoop(d *Driver) {
+	for {
+		time.Sleep(snapshotScheduleCheckInterval * time.Second)
+
+		snapshotSchedulesMutex.Lock()
+		due := map[string]*snapshotSchedule{}
+
+		for serverID, schedule := range snapshotSchedules {
+			if time.Since(schedule.LastSnapshot) >= schedule.Interval {
+				schedule.LastSnapshot = time.Now()
+				due[serverID] = schedule
+			}
+		}
+
+		snapshotSchedulesMutex.Unlock()
+
+		for serverID, schedule := range due {
+			err := takeScheduledSnapshot(d, schedule)
+
+			if err != nil {
+				debugCloudAction(rtNetworkStorage, "Failed to take a scheduled snapshot (id: %s) - Error: %s", serverID, err.Error())
+			}
+		}
+	}
+}
+
+// takeScheduledSnapshot takes a crash-consistent snapshot of the volume covered by the given
+// schedule and prunes the oldest scheduled archives beyond the retention.
+func takeScheduledSnapshot(d *Driver, schedule *snapshotSchedule) error {
+	ns, err := d.loadNetworkStorageByVolumeID(schedule.VolumeID)
+
+	if err != nil {
+		return err
+	}
+
+	// Serialize operations targeting the same volume.
+	lockVolume(ns.Hostname)
+	defer unlockVolume(ns.Hostname)
+
+	name := fmt.Sprintf("%s-%s", snapshotSchedulePrefix, time.Now().UTC().Format("20060102-150405"))
+
+	err = ns.Freeze(gsFreezeWindowSeconds)
+
+	if err != nil {
+		return err
+	}
+
+	// Keep the volume frozen while the archive is being taken by rescheduling the auto-thaw
+	// watchdog, which would otherwise expire in the middle of archiving a non-trivial volume.
+	refreshDone := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(gsFreezeWindowSeconds / 2 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-refreshDone:
+				return
+			case <-ticker.C:
+				refreshErr := ns.RefreshFreeze(gsFreezeWindowSeconds)
+
+				if refreshErr != nil {
+					debugCloudAction(rtNetworkStorage, "Failed to refresh the freeze window (id: %s) - Error: %s", ns.ID, refreshErr.Error())
+				}
+			}
+		}
+	}()
+
+	err = ns.CreateSnapshotArchive(name)
+	close(refreshDone)
+	thawErr := ns.Thaw()
+
+	if err != nil {
+		return err
+	}
+
+	if thawErr != nil {
+		return thawErr
+	}
+
+	auditEvent("snapshot-create", ns.ID, "", "Created scheduled snapshot '%s'", name)
+
+	return ns.pruneScheduledSnapshots(schedule.Retention)
+}
+
+// pruneScheduledSnapshots removes the oldest scheduled snapshot archives beyond the given
+// retention. The archives sort chronologically since their names embed the creation timestamp.
+func (ns *NetworkStorage) pruneScheduledSnapshots(retention int) error {
+	output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf(
+		"ls -1 %s/%s-*.tar.gz 2> /dev/null || true",
+		nsPathSnapshotDirectory,
+		snapshotSchedulePrefix,
+	))
+
+	if err != nil {
+		return err
+	}
+
+	archives := []string{}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+
+		// Only accept paths inside the snapshot directory before embedding them in shell commands.
+		if strings.HasPrefix(line, nsPathSnapshotDirectory+"/") && !strings.ContainsAny(line, "'\"$`\\ ") {
+			archives = append(archives, line)
+		}
+	}
+
+	sort.Strings(archives)
+
+	for i := 0; i < len(archives)-retention; i++ {
+		debugCloudAction(rtNetworkStorage, "Pruning scheduled snapshot archive '%s' (id: %s)", archives[i], ns.ID)
+
+		output, err := ns.driver.RemoteExecutor.Execute(ns, fmt.Sprintf("rm -f %s", archives[i]))
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to prune a scheduled snapshot archive (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+			return err
+		}
+
+		auditEvent("snapshot-delete", ns.ID, "", "Pruned scheduled snapshot archive '%s'", archives[i])
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+const (
+	// secretKeyAPIEndpoint specifies the key for an API endpoint override in a CSI secret.
+	secretKeyAPIEndpoint = "apiEndpoint"
+
+	// secretKeyAPIKey specifies the key for an API key override in a CSI secret.
+	secretKeyAPIKey = "apiKey"
+)
+
+// withSecrets returns a controller server whose driver performs its API requests with the
+// credentials from the given CSI secrets. The secrets are populated from the
+// csi.storage.k8s.io/provisioner-secret-name parameters of the storage class, which allows
+// different storage classes to use different Cloud.dk accounts. The original controller server is
+// returned unmodified when the secrets do not contain any credentials.
+func (cs *ControllerServer) withSecrets(secrets map[string]string) *ControllerServer {
+	apiKey := secrets[secretKeyAPIKey]
+
+	if apiKey == "" {
+		return cs
+	}
+
+	apiEndpoint := secrets[secretKeyAPIEndpoint]
+
+	if apiEndpoint == "" {
+		apiEndpoint = cs.driver.Configuration.ClientSettings.Endpoint
+	}
+
+	clientSettings := &clouddk.ClientSettings{
+		Endpoint: apiEndpoint,
+		Key:      apiKey,
+	}
+
+	newConfiguration := *cs.driver.Configuration
+	newConfiguration.ClientSettings = clientSettings
+
+	newDriver := *cs.driver
+	newDriver.CloudClient = &cloudAPIClient{settings: clientSettings}
+	newDriver.Configuration = &newConfiguration
+
+	return &ControllerServer{driver: &newDriver}
+}
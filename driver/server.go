@@ -0,0 +1,294 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+)
+
+const (
+	// serverKeepaliveMinTimeSeconds defines the minimum interval in seconds between client keepalive pings.
+	serverKeepaliveMinTimeSeconds = 30
+
+	// serverKeepaliveTimeSeconds defines the interval in seconds between server keepalive pings on idle connections.
+	serverKeepaliveTimeSeconds = 300
+
+	// serverKeepaliveTimeoutSeconds defines the number of seconds to wait for a keepalive acknowledgement before closing a connection.
+	serverKeepaliveTimeoutSeconds = 30
+
+	// serverMaxMessageSize defines the maximum size in bytes of gRPC messages sent and received by the server.
+	serverMaxMessageSize = 16 * 1024 * 1024
+)
+
+// GRPCServer exposes the CSI services on a single endpoint without blocking the caller.
+type GRPCServer struct {
+	// SocketGID contains the group id to assign to a unix socket (-1 leaves the group unchanged).
+	SocketGID int
+
+	// SocketMode contains the file mode to assign to a unix socket (0 leaves the mode unchanged).
+	SocketMode os.FileMode
+
+	// SocketUID contains the user id to assign to a unix socket (-1 leaves the owner unchanged).
+	SocketUID int
+
+	reflection bool
+	server     *grpc.Server
+	wg         sync.WaitGroup
+}
+
+// newGRPCServer creates a new non-blocking gRPC server with the given transport security and unary
+// interceptors. The keepalive parameters, message size limit and per call timeout can be tuned
+// through the configuration and fall back to the server defaults when left at zero.
+func newGRPCServer(c *Configuration, tlsConfig *tls.Config, interceptors ...grpc.UnaryServerInterceptor) *GRPCServer {
+	keepaliveTime := serverKeepaliveTimeSeconds
+
+	if c.GRPCKeepaliveInterval > 0 {
+		keepaliveTime = c.GRPCKeepaliveInterval
+	}
+
+	keepaliveTimeout := serverKeepaliveTimeoutSeconds
+
+	if c.GRPCKeepaliveTimeout > 0 {
+		keepaliveTimeout = c.GRPCKeepaliveTimeout
+	}
+
+	maxMessageSize := serverMaxMessageSize
+
+	if c.GRPCMaxMessageSize > 0 {
+		maxMessageSize = c.GRPCMaxMessageSize
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             serverKeepaliveMinTimeSeconds * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    time.Duration(keepaliveTime) * time.Second,
+			Timeout: time.Duration(keepaliveTimeout) * time.Second,
+		}),
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.MaxSendMsgSize(maxMessageSize),
+	}
+
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	// Abort calls which exceed the configured per call timeout, if any.
+	if c.GRPCTimeout > 0 {
+		interceptors = append([]grpc.UnaryServerInterceptor{timeoutInterceptor(c.GRPCTimeout)}, interceptors...)
+	}
+
+	// The gRPC server only accepts a single unary interceptor, so multiple interceptors are
+	// combined into a chain.
+	if len(interceptors) == 1 {
+		opts = append(opts, grpc.UnaryInterceptor(interceptors[0]))
+	} else if len(interceptors) > 1 {
+		opts = append(opts, grpc.UnaryInterceptor(chainUnaryInterceptors(interceptors)))
+	}
+
+	return &GRPCServer{
+		SocketGID:  -1,
+		SocketUID:  -1,
+		reflection: c.GRPCReflection,
+		server:     grpc.NewServer(opts...),
+	}
+}
+
+// chainUnaryInterceptors combines multiple unary interceptors into a single interceptor which
+// invokes them in order.
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chained := handler
+
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor := interceptors[i]
+			next := chained
+
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+
+		return chained(ctx, req)
+	}
+}
+
+// timeoutInterceptor aborts the calls which exceed the given timeout in seconds.
+func timeoutInterceptor(seconds int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
+
+// newTLSConfig creates a TLS configuration based on the given certificate, key and certificate authority files.
+// Client certificate authentication is enabled in case a certificate authority file is provided.
+func newTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the TLS certificate - Reason: %s", err.Error())
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		caCert, err := ioutil.ReadFile(caFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the certificate authority file - Reason: %s", err.Error())
+		}
+
+		caCertPool := x509.NewCertPool()
+
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("Failed to parse the certificates in file '%s'", caFile)
+		}
+
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConfig.ClientCAs = caCertPool
+	}
+
+	return tlsConfig, nil
+}
+
+// parseEndpoint splits a CSI endpoint into a network protocol and an address.
+func parseEndpoint(endpoint string) (proto string, addr string, err error) {
+	if strings.HasPrefix(strings.ToLower(endpoint), "unix://") || strings.HasPrefix(strings.ToLower(endpoint), "tcp://") {
+		s := strings.SplitN(endpoint, "://", 2)
+
+		if s[1] != "" {
+			return s[0], s[1], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("Invalid endpoint '%s'", endpoint)
+}
+
+// Start exposes the given CSI services on the endpoint and returns once the server is listening.
+func (s *GRPCServer) Start(endpoint string, ids csi.IdentityServer, cs csi.ControllerServer, gcs csi.GroupControllerServer, ns csi.NodeServer) error {
+	proto, addr, err := parseEndpoint(endpoint)
+
+	if err != nil {
+		return err
+	}
+
+	if proto == "unix" {
+		// Verify that the plugin directory exists before creating a socket inside it.
+		fi, err := os.Stat(filepath.Dir(addr))
+
+		if err != nil || !fi.IsDir() {
+			return fmt.Errorf("The plugin directory '%s' does not exist", filepath.Dir(addr))
+		}
+
+		// Remove a stale socket left behind by a previous driver instance, if any.
+		err = os.Remove(addr)
+
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Failed to remove the stale socket '%s' - Reason: %s", addr, err.Error())
+		}
+	}
+
+	listener, err := net.Listen(proto, addr)
+
+	if err != nil {
+		return err
+	}
+
+	// Adjust the permissions and ownership of a unix socket, if requested.
+	if proto == "unix" {
+		if s.SocketMode != 0 {
+			err = os.Chmod(addr, s.SocketMode)
+
+			if err != nil {
+				return fmt.Errorf("Failed to change the mode of the socket '%s' - Reason: %s", addr, err.Error())
+			}
+		}
+
+		if s.SocketUID >= 0 || s.SocketGID >= 0 {
+			err = os.Chown(addr, s.SocketUID, s.SocketGID)
+
+			if err != nil {
+				return fmt.Errorf("Failed to change the ownership of the socket '%s' - Reason: %s", addr, err.Error())
+			}
+		}
+	}
+
+	if ids != nil {
+		csi.RegisterIdentityServer(s.server, ids)
+	}
+
+	if cs != nil {
+		csi.RegisterControllerServer(s.server, cs)
+	}
+
+	if gcs != nil {
+		csi.RegisterGroupControllerServer(s.server, gcs)
+	}
+
+	if ns != nil {
+		csi.RegisterNodeServer(s.server, ns)
+	}
+
+	// Expose the service descriptors for tools like grpcurl, if enabled.
+	if s.reflection {
+		reflection.Register(s.server)
+	}
+
+	log.Printf("Listening for connections on address %s", listener.Addr())
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		err := s.server.Serve(listener)
+
+		if err != nil {
+			log.Printf("The gRPC server stopped serving - Reason: %s", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the server gracefully by draining the active calls.
+func (s *GRPCServer) Stop() {
+	s.server.GracefulStop()
+}
+
+// ForceStop stops the server forcefully by closing the active connections.
+func (s *GRPCServer) ForceStop() {
+	s.server.Stop()
+}
+
+// Wait waits for the server to stop serving.
+func (s *GRPCServer) Wait() {
+	s.wg.Wait()
+}
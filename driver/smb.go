@@ -0,0 +1,154 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// nsPathSMBScript defines the path to the SMB setup script on a storage server.
+	nsPathSMBScript = "/etc/clouddk_network_storage_smb.sh"
+
+	// nsPathSMBSecret defines the path to the temporary file holding the SMB password on a storage server.
+	nsPathSMBSecret = "/etc/clouddk_network_storage_smb_secret"
+
+	// nsProtocolNFS identifies the default protocol where the volume is exported as an NFS share.
+	nsProtocolNFS = "nfs"
+
+	// nsProtocolSMB identifies the protocol where the volume is exported as an SMB share.
+	nsProtocolSMB = "smb"
+
+	// nsSMBShareName defines the name of the SMB share exposing the data directory on a storage server.
+	nsSMBShareName = "data"
+)
+
+// nsSMBScript installs Samba on a storage server and configures the share for the data directory.
+// The password is read from a temporary file in order to keep it out of the process list.
+var nsSMBScript = heredoc.Doc(`
+	#!/bin/sh
+	# Specify the required environment variables.
+	export DEBIAN_FRONTEND=noninteractive
+
+	# The name of the SMB user is passed as the first argument.
+	SMB_USERNAME="$1"
+
+	# Install Samba in case it is missing.
+	if ! command -v smbd >/dev/null 2>&1; then
+		apt-get -qq update
+		apt-get -qq install -y samba
+	fi
+
+	# Create a system user without shell access for the SMB credentials.
+	if ! id "$SMB_USERNAME" >/dev/null 2>&1; then
+		useradd -M -s /usr/sbin/nologin "$SMB_USERNAME"
+	fi
+
+	# Register the user with the password from the temporary secret file.
+	SMB_PASSWORD="$(cat /etc/clouddk_network_storage_smb_secret)"
+	rm -f /etc/clouddk_network_storage_smb_secret
+	(echo "$SMB_PASSWORD"; echo "$SMB_PASSWORD") | smbpasswd -s -a "$SMB_USERNAME"
+
+	# Configure the share for the data directory.
+	(
+		echo '[global]'
+		echo 'map to guest = never'
+		echo 'server min protocol = SMB2'
+		echo ''
+		echo '[data]'
+		echo 'path = /mnt/data'
+		echo 'read only = no'
+		echo 'writable = yes'
+		echo 'force user = nobody'
+		echo 'force group = nogroup'
+		echo "valid users = $SMB_USERNAME"
+	) > /etc/samba/smb.conf
+
+	# Restrict the SMB service to the authorized nodes and cluster networks.
+	if ! iptables -C INPUT -p tcp --dport 445 -j DROP 2> /dev/null; then
+		iptables -I INPUT -p tcp --dport 445 -j DROP
+		iptables -I INPUT -p tcp --dport 445 -m set --match-set nodes src -j ACCEPT
+		iptables -I INPUT -p tcp --dport 445 -m set --match-set networks src -j ACCEPT
+	fi
+
+	# Enable and restart the Samba service.
+	systemctl enable smbd
+	systemctl restart smbd
+`)
+
+// EnableSMB installs and configures the SMB service on the storage server.
+func (ns *NetworkStorage) EnableSMB(sshClient *ssh.Client, sftpClient *sftp.Client, username string, password string) (err error) {
+	debugCloudAction(rtNetworkStorage, "Enabling the SMB service (id: %s)", ns.ID)
+
+	// The username is embedded in shell commands and the Samba configuration and must not be
+	// able to escape either context.
+	if !isSafeNodeID(username) {
+		return fmt.Errorf("The SMB username contains unsupported characters")
+	}
+
+	err = ns.CreateFile(sftpClient, nsPathSMBSecret, bytes.NewBufferString(password))
+
+	if err != nil {
+		return err
+	}
+
+	err = ns.CreateFile(sftpClient, nsPathSMBScript, bytes.NewBufferString(strings.ReplaceAll(nsSMBScript, "\r", "")))
+
+	if err != nil {
+		return err
+	}
+
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput(fmt.Sprintf("/bin/sh %s '%s'", nsPathSMBScript, username))
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to enable the SMB service (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// mountSMBShare mounts the SMB share of a storage server at the given path using the provided credentials.
+func mountSMBShare(serverIP string, path string, username string, password string, options ...string) (err error) {
+	err = os.MkdirAll(path, 0750)
+
+	if err != nil {
+		return err
+	}
+
+	mountOptions := []string{
+		fmt.Sprintf("username=%s", username),
+		fmt.Sprintf("password=%s", password),
+		"vers=3.0",
+	}
+
+	mountOptions = append(mountOptions, options...)
+	source := fmt.Sprintf("//%s/%s", serverIP, nsSMBShareName)
+
+	output, err := exec.Command("mount", "-t", "cifs", "-o", strings.Join(mountOptions, ","), source, path).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("Failed to mount the SMB share '%s' - Output: %s - Error: %s", source, string(output), err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// sshKeyFilePrivate specifies the file name for a generated private SSH key.
+	sshKeyFilePrivate = "id_ed25519"
+
+	// sshKeyFilePublic specifies the file name for a generated public SSH key.
+	sshKeyFilePublic = "id_ed25519.pub"
+)
+
+// LoadOrGenerateSSHKeyPair loads the SSH key pair persisted in the given directory or generates and
+// persists a new ed25519 key pair when the directory does not contain one. This removes the need to
+// generate a key pair out of band and guarantees that the private and public keys always match.
+func LoadOrGenerateSSHKeyPair(dir string) (privateKey string, publicKey string, err error) {
+	privateKeyPath := filepath.Join(dir, sshKeyFilePrivate)
+	publicKeyPath := filepath.Join(dir, sshKeyFilePublic)
+
+	privateKeyContents, privateKeyErr := ioutil.ReadFile(privateKeyPath)
+	publicKeyContents, publicKeyErr := ioutil.ReadFile(publicKeyPath)
+
+	if privateKeyErr == nil && publicKeyErr == nil {
+		signer, err := ssh.ParsePrivateKey(privateKeyContents)
+
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to parse the persisted private SSH key '%s' - Reason: %s", privateKeyPath, err.Error())
+		}
+
+		authorizedKey, _, _, _, err := ssh.ParseAuthorizedKey(publicKeyContents)
+
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to parse the persisted public SSH key '%s' - Reason: %s", publicKeyPath, err.Error())
+		}
+
+		if !bytes.Equal(signer.PublicKey().Marshal(), authorizedKey.Marshal()) {
+			return "", "", fmt.Errorf("The persisted SSH keys in '%s' do not form a matching pair", dir)
+		}
+
+		return string(privateKeyContents), string(publicKeyContents), nil
+	}
+
+	if privateKeyErr != nil && !os.IsNotExist(privateKeyErr) {
+		return "", "", privateKeyErr
+	}
+
+	if publicKeyErr != nil && !os.IsNotExist(publicKeyErr) {
+		return "", "", publicKeyErr
+	}
+
+	// Refuse to overwrite half a key pair as the existing key may still grant access to the
+	// storage servers.
+	if (privateKeyErr == nil) != (publicKeyErr == nil) {
+		return "", "", fmt.Errorf("The directory '%s' contains an incomplete SSH key pair", dir)
+	}
+
+	privateKey, publicKey, err = generateSSHKeyPair()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	err = ioutil.WriteFile(privateKeyPath, []byte(privateKey), 0600)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	err = ioutil.WriteFile(publicKeyPath, []byte(publicKey), 0644)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return privateKey, publicKey, nil
+}
+
+// generateSSHKeyPair generates a new ed25519 SSH key pair. The private key is serialized in the
+// OpenSSH format as the PEM formats predate the ed25519 algorithm.
+func generateSSHKeyPair() (privateKey string, publicKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	sshPublicKey, err := ssh.NewPublicKey(pub)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	check := make([]byte, 4)
+	_, err = rand.Read(check)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	checkInt := binary.BigEndian.Uint32(check)
+
+	privateKeyBlock := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+	}{checkInt, checkInt, ssh.KeyAlgoED25519, pub, priv, ""})
+
+	// Pad the private key block to the block size of the (unused) cipher.
+	for i := 1; len(privateKeyBlock)%8 != 0; i++ {
+		privateKeyBlock = append(privateKeyBlock, byte(i))
+	}
+
+	blob := append([]byte("openssh-key-v1\x00"), ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, sshPublicKey.Marshal(), privateKeyBlock})...)
+
+	privateKey = string(pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: blob}))
+	publicKey = string(ssh.MarshalAuthorizedKey(sshPublicKey))
+
+	return privateKey, publicKey, nil
+}
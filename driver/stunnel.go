@@ -0,0 +1,159 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// nsPathStunnelScript defines the path to the stunnel setup script on a storage server.
+	nsPathStunnelScript = "/etc/clouddk_network_storage_stunnel.sh"
+
+	// nsStunnelPort defines the port for the TLS listener in front of the NFS service on a storage server.
+	nsStunnelPort = 2057
+
+	// nsTransportPlain identifies the default transport where NFS traffic flows directly over TCP.
+	nsTransportPlain = "plain"
+
+	// nsTransportTLS identifies the transport where NFS traffic flows through a stunnel TLS channel.
+	nsTransportTLS = "tls"
+)
+
+// nsStunnelScript installs stunnel on a storage server and configures the TLS listener for the NFS service.
+var nsStunnelScript = heredoc.Doc(`
+	#!/bin/sh
+	# Specify the required environment variables.
+	export DEBIAN_FRONTEND=noninteractive
+
+	# Install stunnel in case it is missing.
+	if ! command -v stunnel4 >/dev/null 2>&1; then
+		apt-get -qq update
+		apt-get -qq install -y stunnel4
+	fi
+
+	# Generate a self-signed certificate, if missing.
+	if [ ! -f /etc/stunnel/stunnel.pem ]; then
+		openssl req -new -x509 -days 3650 -nodes -subj '/CN=clouddk-csi-driver' \
+			-out /etc/stunnel/stunnel.pem -keyout /etc/stunnel/stunnel.pem
+		chmod 600 /etc/stunnel/stunnel.pem
+	fi
+
+	# Configure the TLS listener for the NFS service.
+	(
+		echo 'cert = /etc/stunnel/stunnel.pem'
+		echo ''
+		echo '[nfs]'
+		echo 'accept = 2057'
+		echo 'connect = 127.0.0.1:2049'
+	) > /etc/stunnel/stunnel.conf
+
+	# Enable and restart the stunnel service.
+	sed -i 's/ENABLED=0/ENABLED=1/' /etc/default/stunnel4
+	systemctl enable stunnel4
+	systemctl restart stunnel4
+`)
+
+// EnableStunnel installs and configures the TLS listener on the storage server.
+func (ns *NetworkStorage) EnableStunnel(sshClient *ssh.Client, sftpClient *sftp.Client) (err error) {
+	debugCloudAction(rtNetworkStorage, "Enabling stunnel (id: %s)", ns.ID)
+
+	err = ns.CreateFile(sftpClient, nsPathStunnelScript, bytes.NewBufferString(strings.ReplaceAll(nsStunnelScript, "\r", "")))
+
+	if err != nil {
+		return err
+	}
+
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput("/bin/sh " + nsPathStunnelScript)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to enable stunnel (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// getStunnelClientPort returns the local port for the TLS channel to the storage server hosting a volume.
+func getStunnelClientPort(id string) int {
+	return 10000 + int(crc32.ChecksumIEEE([]byte(id))%20000)
+}
+
+// setupStunnelClient creates a local TLS channel to the storage server and returns the local port.
+func setupStunnelClient(id string, serverIP string) (port int, err error) {
+	port = getStunnelClientPort(id)
+	configPath := fmt.Sprintf("/etc/stunnel/clouddk-%s.conf", id)
+	pidPath := fmt.Sprintf("/var/run/stunnel-clouddk-%s.pid", id)
+
+	config := "client = yes\n" +
+		"pid = " + pidPath + "\n" +
+		"\n" +
+		"[nfs]\n" +
+		fmt.Sprintf("accept = 127.0.0.1:%d\n", port) +
+		fmt.Sprintf("connect = %s:%d\n", serverIP, nsStunnelPort)
+
+	err = os.MkdirAll("/etc/stunnel", 0750)
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = ioutil.WriteFile(configPath, []byte(config), 0600)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Terminate a previous channel before creating a new one to ensure that the latest configuration is applied.
+	teardownStunnelClient(id)
+
+	output, err := exec.Command("stunnel", configPath).CombinedOutput()
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create TLS channel for volume '%s' - Output: %s - Error: %s", id, string(output), err.Error())
+	}
+
+	return port, nil
+}
+
+// teardownStunnelClient removes the local TLS channel to the storage server.
+func teardownStunnelClient(id string) (err error) {
+	configPath := fmt.Sprintf("/etc/stunnel/clouddk-%s.conf", id)
+	pidPath := fmt.Sprintf("/var/run/stunnel-clouddk-%s.pid", id)
+
+	pid, err := ioutil.ReadFile(pidPath)
+
+	if err == nil {
+		exec.Command("kill", strings.TrimSpace(string(pid))).CombinedOutput()
+		os.Remove(pidPath)
+	}
+
+	_, err = os.Stat(configPath)
+
+	if err != nil {
+		return nil
+	}
+
+	return os.Remove(configPath)
+}
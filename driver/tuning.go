@@ -0,0 +1,77 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+)
+
+const (
+	// nsPathTuningScript defines the path to the tuning script on a storage server.
+	nsPathTuningScript = "/etc/clouddk_network_storage_tuning.sh"
+)
+
+// nsTuningScript applies the NFS daemon and I/O tuning on a storage server. Every setting is
+// applied idempotently so the script can be re-run at any time.
+var nsTuningScript = heredoc.Doc(`
+	#!/bin/sh
+	# The settings are passed as arguments where 0 or an empty string leaves a setting unchanged.
+	NFS_THREADS="$1"
+	TCP_SLOT_TABLE_ENTRIES="$2"
+	DISK_SCHEDULER="$3"
+
+	# Apply the NFS daemon thread count.
+	if [ "$NFS_THREADS" -gt 0 ]; then
+		sed -i "s/^RPCNFSDCOUNT=.*/RPCNFSDCOUNT=$NFS_THREADS/" /etc/default/nfs-kernel-server
+		rpc.nfsd "$NFS_THREADS"
+	fi
+
+	# Apply the TCP slot table entries for the RPC transport.
+	if [ "$TCP_SLOT_TABLE_ENTRIES" -gt 0 ]; then
+		(
+			echo "options sunrpc tcp_slot_table_entries=$TCP_SLOT_TABLE_ENTRIES"
+			echo "options sunrpc tcp_max_slot_table_entries=$TCP_SLOT_TABLE_ENTRIES"
+		) > /etc/modprobe.d/sunrpc-tuning.conf
+
+		if [ -e /proc/sys/sunrpc/tcp_slot_table_entries ]; then
+			echo "$TCP_SLOT_TABLE_ENTRIES" > /proc/sys/sunrpc/tcp_slot_table_entries
+		fi
+	fi
+
+	# Apply the I/O scheduler and queue settings for the data disk.
+	if [ -n "$DISK_SCHEDULER" ] && [ -e /sys/block/vdb/queue/scheduler ]; then
+		echo "$DISK_SCHEDULER" > /sys/block/vdb/queue/scheduler
+		echo 1024 > /sys/block/vdb/queue/nr_requests
+	fi
+`)
+
+// ApplyTuning applies the NFS daemon and I/O tuning on the storage server. The routine can be
+// invoked again at any time to adjust the settings of an existing volume.
+func (ns *NetworkStorage) ApplyTuning(threads int, slotTableEntries int, scheduler string) (err error) {
+	debugCloudAction(rtNetworkStorage, "Applying tuning (id: %s - threads: %d - slots: %d - scheduler: %s)", ns.ID, threads, slotTableEntries, scheduler)
+
+	err = ns.driver.FileUploader.Upload(ns, nsPathTuningScript, bytes.NewBufferString(strings.ReplaceAll(nsTuningScript, "\r", "")))
+
+	if err != nil {
+		return err
+	}
+
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		fmt.Sprintf("/bin/sh %s %d %d '%s'", nsPathTuningScript, threads, slotTableEntries, scheduler),
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to apply tuning (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
@@ -12,23 +12,38 @@ import (
 	"log"
 	"math"
 	"math/rand"
+	"net"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/danitso/terraform-provider-clouddk/clouddk"
 )
 
 const (
-	defaultVolumeCapacityInBytes = 17179869184
-	maximumVolumeCapacityInBytes = 8796093022208
-	minimumVolumeCapacityInBytes = 1073741824
-	rtCommon                     = "COMMON"
-	rtNetworkStorage             = "NS"
-	rtVolumes                    = "VOLUMES"
+	defaultVolumeCapacityInBytes   = 17179869184
+	maximumDiskCapacityInGigabytes = 8192
+	maximumVolumeCapacityInBytes   = 35184372088832
+	minimumVolumeCapacityInBytes   = 1073741824
+	rtCommon                       = "COMMON"
+	rtNetworkStorage               = "NS"
+	rtVolumes                      = "VOLUMES"
+	serverListPageSize             = 100
 )
 
 var (
+	// privateNetworks contains the address ranges which are reserved for private networking.
+	privateNetworks = []net.IPNet{
+		{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+		{IP: net.IPv4(172, 16, 0, 0), Mask: net.CIDRMask(12, 32)},
+		{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(16, 32)},
+	}
+
+	// volumeLocks contains the mutexes which serialize operations targeting the same volume.
+	volumeLocks      = map[string]*sync.Mutex{}
+	volumeLocksMutex = sync.Mutex{}
+
 	serverPackageIDs = []string{
 		"ac949a1cb4731d",
 		"89833c1dfa7010",
@@ -43,8 +58,38 @@ var (
 	}
 )
 
-// debugCloudAction writes a debug message to the log.
+// checkVersionSkew determines if the given controller version is compatible with this node plugin.
+// Versions are considered compatible when the major and minor components match.
+func checkVersionSkew(controllerVersion string) (err error) {
+	if controllerVersion == "" || controllerVersion == DriverVersion {
+		return nil
+	}
+
+	controllerComponents := strings.Split(controllerVersion, ".")
+	nodeComponents := strings.Split(DriverVersion, ".")
+
+	if len(controllerComponents) < 2 || len(nodeComponents) < 2 ||
+		controllerComponents[0] != nodeComponents[0] ||
+		controllerComponents[1] != nodeComponents[1] {
+		return fmt.Errorf(
+			"The controller plugin version %s is incompatible with the node plugin version %s",
+			controllerVersion,
+			DriverVersion,
+		)
+	}
+
+	return nil
+}
+
+// debugCloudAction writes a debug message to the log, including the correlation id of the CSI
+// call being handled, if any.
 func debugCloudAction(resourceType string, format string, v ...interface{}) {
+	if id := getCorrelationID(); id != "" {
+		log.Printf(fmt.Sprintf("[%s] (%s) ", resourceType, id)+format, v...)
+
+		return
+	}
+
 	log.Printf(fmt.Sprintf("[%s] ", resourceType)+format, v...)
 }
 
@@ -107,6 +152,50 @@ func getPackageID(memory, processors int) (id *string, err error) {
 	return &serverPackageIDs[packageIndex], nil
 }
 
+// isSafeNodeID determines if a node id can be safely embedded in file names and shell commands.
+func isSafeNodeID(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' && r != '.' && r != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lockVolume acquires the lock which serializes operations targeting the given volume.
+// Rapid delete and recreate cycles for the same volume name can otherwise interleave the creation
+// of a new server with the deletion of an old one sharing the hostname.
+func lockVolume(name string) {
+	volumeLocksMutex.Lock()
+
+	mutex, ok := volumeLocks[name]
+
+	if !ok {
+		mutex = &sync.Mutex{}
+		volumeLocks[name] = mutex
+	}
+
+	volumeLocksMutex.Unlock()
+
+	mutex.Lock()
+}
+
+// unlockVolume releases the lock which serializes operations targeting the given volume.
+func unlockVolume(name string) {
+	volumeLocksMutex.Lock()
+	mutex := volumeLocks[name]
+	volumeLocksMutex.Unlock()
+
+	if mutex != nil {
+		mutex.Unlock()
+	}
+}
+
 // getRandomPassword generates a random password of a fixed length.
 func getRandomPassword(length int) string {
 	var b strings.Builder
@@ -120,12 +209,56 @@ func getRandomPassword(length int) string {
 	return b.String()
 }
 
-// getServerByHostname retrieves information about a server.
-func getServerByHostname(s *clouddk.ClientSettings, hostname string) (server *clouddk.ServerBody, notFound bool, err error) {
-	res, err := clouddk.DoClientRequest(
-		s,
+// getServerPrivateIP returns the first private network address assigned to a server, if any.
+func getServerPrivateIP(server *clouddk.ServerBody) string {
+	for _, networkInterface := range server.NetworkInterfaces {
+		for _, address := range networkInterface.IPAddresses {
+			ip := net.ParseIP(address.Address)
+
+			if ip == nil {
+				continue
+			}
+
+			for _, network := range privateNetworks {
+				if network.Contains(ip) {
+					return address.Address
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// getNodeAccessIP returns the address a node must be granted access for, which is the private
+// network address when available and the WireGuard tunnel address when encryption is enabled.
+func getNodeAccessIP(d *Driver, server *clouddk.ServerBody) (string, error) {
+	if len(server.NetworkInterfaces) == 0 {
+		return "", fmt.Errorf("Node '%s' has no network interfaces", server.Hostname)
+	}
+
+	nodeIP := server.NetworkInterfaces[0].IPAddresses[0].Address
+
+	if privateIP := getServerPrivateIP(server); privateIP != "" {
+		nodeIP = privateIP
+	}
+
+	if d.Configuration.NetworkEncryption {
+		return getWireGuardPeerAddress(nodeIP), nil
+	}
+
+	return nodeIP, nil
+}
+
+// getServerByID retrieves information about a server using its identifier.
+func getServerByID(d *Driver, id string) (server *clouddk.ServerBody, notFound bool, err error) {
+	if server := d.serverCache.getByID(id); server != nil {
+		return server, false, nil
+	}
+
+	res, err := d.CloudClient.DoRequest(
 		"GET",
-		fmt.Sprintf("cloudservers?hostname=%s", url.QueryEscape(hostname)),
+		fmt.Sprintf("cloudservers/%s", id),
 		new(bytes.Buffer),
 		[]int{200},
 		1,
@@ -133,20 +266,57 @@ func getServerByHostname(s *clouddk.ClientSettings, hostname string) (server *cl
 	)
 
 	if err != nil {
-		debugCloudAction(rtCommon, "Failed to retrieve information about server '%s' due to API errors", hostname)
+		debugCloudAction(rtCommon, "Failed to retrieve information about server '%s' due to API errors", id)
 
+		// The response is nil when the request failed before reaching the API.
+		return nil, (res != nil && res.StatusCode == 404), err
+	}
+
+	body := clouddk.ServerBody{}
+	err = json.NewDecoder(res.Body).Decode(&body)
+
+	if err != nil {
 		return nil, false, err
 	}
 
-	serverList := clouddk.ServerListBody{}
-	err = json.NewDecoder(res.Body).Decode(&serverList)
+	d.serverCache.set(&body)
+
+	return &body, false, nil
+}
+
+// getServerByNodeID resolves a node identifier to a server. The identifier may be the canonical
+// hostname reported by NodeGetInfo, a clouddk:// provider id or a plain server id since the
+// orchestrator and its sidecars do not agree on a single format.
+func getServerByNodeID(d *Driver, nodeID string) (server *clouddk.ServerBody, notFound bool, err error) {
+	nodeID = trimProviderID(nodeID)
+
+	server, notFound, err = getServerByHostname(d, nodeID)
+
+	if err == nil || !notFound {
+		return server, notFound, err
+	}
+
+	return getServerByID(d, nodeID)
+}
+
+// getServerByHostname retrieves information about a server.
+func getServerByHostname(d *Driver, hostname string) (server *clouddk.ServerBody, notFound bool, err error) {
+	if server := d.serverCache.getByHostname(hostname); server != nil {
+		return server, false, nil
+	}
+
+	serverList, err := listServers(d, fmt.Sprintf("hostname=%s", url.QueryEscape(hostname)))
 
 	if err != nil {
+		debugCloudAction(rtCommon, "Failed to retrieve information about server '%s' due to API errors", hostname)
+
 		return nil, false, err
 	}
 
 	for _, v := range serverList {
 		if v.Hostname == hostname {
+			d.serverCache.set(&v)
+
 			return &v, false, nil
 		}
 	}
@@ -156,6 +326,50 @@ func getServerByHostname(s *clouddk.ClientSettings, hostname string) (server *cl
 	return nil, true, fmt.Errorf("No matching servers for hostname '%s'", hostname)
 }
 
+// listServers retrieves all servers matching the given query filter by following the pagination
+// of the list endpoint. Accounts with more servers than a single page holds would otherwise miss
+// matches on the later pages.
+func listServers(d *Driver, filter string) (clouddk.ServerListBody, error) {
+	servers := clouddk.ServerListBody{}
+
+	for page := 1; ; page++ {
+		path := "cloudservers?"
+
+		if filter != "" {
+			path += filter + "&"
+		}
+
+		path += fmt.Sprintf("page=%d&per-page=%d", page, serverListPageSize)
+
+		res, err := d.CloudClient.DoRequest(
+			"GET",
+			path,
+			new(bytes.Buffer),
+			[]int{200},
+			1,
+			1,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pageList := clouddk.ServerListBody{}
+		err = json.NewDecoder(res.Body).Decode(&pageList)
+
+		if err != nil {
+			return nil, err
+		}
+
+		servers = append(servers, pageList...)
+
+		// The last page is not completely filled, which includes an empty first page.
+		if len(pageList) < serverListPageSize {
+			return servers, nil
+		}
+	}
+}
+
 // parseCapacity parses a capacity range and returns the capacity in gigabytes.
 func parseCapacity(cr *csi.CapacityRange) (capacity int, err error) {
 	capacityLimit := cr.GetLimitBytes()
@@ -0,0 +1,67 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Validate performs a pre-flight validation of the driver configuration in order to fail
+// fast with a clear message instead of failing when the first volume is created.
+func (d *Driver) Validate() error {
+	c := d.Configuration
+
+	if c.NodeID == "" {
+		return errors.New("The node id must be specified")
+	}
+
+	if d.PackageID == nil || *d.PackageID == "" {
+		return errors.New("The memory and processor requirements do not map to a server package")
+	}
+
+	// Verify that the private SSH key parses and matches the public key.
+	signer, err := ssh.ParsePrivateKey([]byte(c.PrivateKey))
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse the private SSH key - Reason: %s", err.Error())
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(c.PublicKey))
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse the public SSH key - Reason: %s", err.Error())
+	}
+
+	if !bytes.Equal(signer.PublicKey().Marshal(), publicKey.Marshal()) {
+		return errors.New("The public SSH key does not match the private SSH key")
+	}
+
+	// Verify that the maintenance window parses in case one is configured.
+	if c.MaintenanceWindow != "" && !validMaintenanceWindow(c.MaintenanceWindow) {
+		return errors.New("The maintenance window must use the 'HH:MM-HH:MM' format in UTC")
+	}
+
+	// Verify the API key with a lightweight API call.
+	res, err := d.CloudClient.DoRequest(
+		"GET",
+		"cloudservers?per-page=1",
+		new(bytes.Buffer),
+		[]int{200},
+		1,
+		1,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Failed to verify the API key - Reason: %s", err.Error())
+	}
+
+	res.Body.Close()
+
+	return nil
+}
@@ -0,0 +1,238 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danitso/terraform-provider-clouddk/clouddk"
+)
+
+// listManagedServers retrieves all servers provisioned by the driver, including servers which are
+// scheduled for deletion or retained.
+func (d *Driver) listManagedServers() ([]clouddk.ServerBody, error) {
+	serverList, err := listServers(d, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	servers := []clouddk.ServerBody{}
+
+	for _, v := range serverList {
+		if !strings.HasPrefix(v.Hostname, fmt.Sprintf(nsFormatHostname, "")) &&
+			!strings.HasPrefix(v.Hostname, fmt.Sprintf(nsFormatPendingHostname, "")) &&
+			!strings.HasPrefix(v.Hostname, fmt.Sprintf(nsFormatRetainedHostname, "")) {
+			continue
+		}
+
+		servers = append(servers, v)
+	}
+
+	return servers, nil
+}
+
+// parseOwnerLabel extracts the PV name and the PVC namespace and name from a server label
+// recorded at creation time.
+func parseOwnerLabel(label string) (pvName string, pvcNamespace string, pvcName string, ok bool) {
+	// The owner record may sit behind the retain-data-disk policy marker.
+	label = strings.TrimSpace(strings.TrimPrefix(label, nsLabelRetainDataDisk))
+
+	if !strings.HasPrefix(label, "pv:") {
+		return "", "", "", false
+	}
+
+	fields := strings.Fields(label)
+
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "pvc:") {
+		return "", "", "", false
+	}
+
+	claim := strings.Split(strings.TrimPrefix(fields[1], "pvc:"), "/")
+
+	if len(claim) != 2 {
+		return "", "", "", false
+	}
+
+	return strings.TrimPrefix(fields[0], "pv:"), claim[0], claim[1], true
+}
+
+// volumeServerState derives the operator facing state of a storage server from its hostname and
+// label.
+func volumeServerState(server *clouddk.ServerBody) string {
+	switch {
+	case strings.HasPrefix(server.Hostname, fmt.Sprintf(nsFormatPendingHostname, "")):
+		return "pending-deletion"
+	case strings.HasPrefix(server.Hostname, fmt.Sprintf(nsFormatRetainedHostname, "")):
+		return "retained"
+	case strings.HasPrefix(server.Label, nsLabelScaledDown):
+		return "scaled-down"
+	case strings.HasSuffix(server.Hostname, nsReplicaSuffix):
+		return "replica"
+	default:
+		return "active"
+	}
+}
+
+// volumeServerSize sums the sizes of the data disks attached to a storage server.
+func volumeServerSize(server *clouddk.ServerBody) int {
+	size := 0
+
+	for _, disk := range server.Disks {
+		if disk.Label == nsDiskLabel || disk.Label == nsDiskLabelMirror {
+			size += int(disk.Size)
+		}
+	}
+
+	return size
+}
+
+// ListVolumeServers prints an overview of all storage servers provisioned by the driver.
+func (d *Driver) ListVolumeServers() error {
+	servers, err := d.listManagedServers()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-12s %-48s %-18s %-10s %-8s %s\n", "ID", "HOSTNAME", "STATE", "SIZE (GB)", "BOOTED", "OWNER")
+
+	for _, server := range servers {
+		owner := "-"
+
+		if _, pvcNamespace, pvcName, ok := parseOwnerLabel(server.Label); ok {
+			owner = fmt.Sprintf("%s/%s", pvcNamespace, pvcName)
+		}
+
+		fmt.Printf(
+			"%-12s %-48s %-18s %-10d %-8t %s\n",
+			server.Identifier,
+			server.Hostname,
+			volumeServerState(&server),
+			volumeServerSize(&server),
+			bool(server.Booted),
+			owner,
+		)
+	}
+
+	return nil
+}
+
+// InspectVolume prints the details and live state of the storage server backing the given volume.
+// The argument may be a volume ID or a raw server identifier.
+func (d *Driver) InspectVolume(id string) error {
+	volumeInfo := strings.Split(id, "-")
+
+	if len(volumeInfo) == 2 && volumeInfo[0] == volumePrefixNetworkStorage {
+		id = volumeInfo[1]
+	}
+
+	ns, notFound, err := loadNetworkStorage(d, id)
+
+	if err != nil {
+		if notFound {
+			return fmt.Errorf("The server '%s' does not exist", id)
+		}
+
+		return err
+	}
+
+	server, _, err := getServerByID(d, ns.ID)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ID:       %s\n", server.Identifier)
+	fmt.Printf("Hostname: %s\n", server.Hostname)
+	fmt.Printf("State:    %s\n", volumeServerState(server))
+	fmt.Printf("Package:  %s\n", server.Package.Name)
+	fmt.Printf("Booted:   %t\n", bool(server.Booted))
+	fmt.Printf("Address:  %s\n", ns.IP)
+
+	for _, disk := range server.Disks {
+		fmt.Printf("Disk:     %s (label: %s - size: %d GB)\n", disk.Identifier, disk.Label, int(disk.Size))
+	}
+
+	if !bool(server.Booted) {
+		fmt.Println("Health:   unhealthy (the server is not booted)")
+
+		return nil
+	}
+
+	output, err := d.RemoteExecutor.Execute(ns, nsProbeCommand)
+
+	if err != nil {
+		fmt.Printf("Health:   unhealthy (%s)\n", strings.TrimSpace(string(output)))
+
+		return nil
+	}
+
+	fmt.Println("Health:   healthy")
+
+	output, err = d.RemoteExecutor.Execute(ns, "exportfs")
+
+	if err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			if line != "" {
+				fmt.Printf("Export:   %s\n", strings.Join(strings.Fields(line), " "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// CleanupOrphanVolumes deletes the storage servers which do not carry a data disk. Such servers
+// are leftovers from provisioning operations which failed before the disk was attached.
+func (d *Driver) CleanupOrphanVolumes() error {
+	servers, err := d.listManagedServers()
+
+	if err != nil {
+		return err
+	}
+
+	deleted := 0
+	failed := []string{}
+
+	for _, server := range servers {
+		// Retained servers hold a data disk by definition and servers scheduled for deletion are
+		// removed by the deletion reaper once their grace period expires.
+		if !strings.HasPrefix(server.Hostname, fmt.Sprintf(nsFormatHostname, "")) {
+			continue
+		}
+
+		if volumeServerSize(&server) > 0 {
+			continue
+		}
+
+		ns := &NetworkStorage{
+			driver:   d,
+			ID:       server.Identifier,
+			Hostname: server.Hostname,
+		}
+
+		fmt.Printf("Deleting orphaned server '%s' (hostname: %s)\n", server.Identifier, server.Hostname)
+
+		err = ns.Delete()
+
+		if err != nil {
+			failed = append(failed, server.Hostname)
+
+			continue
+		}
+
+		deleted++
+	}
+
+	fmt.Printf("Deleted %d orphaned storage servers\n", deleted)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to delete %d orphaned storage servers (%s)", len(failed), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
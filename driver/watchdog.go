@@ -0,0 +1,119 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// stagedMount records how a staged volume was mounted so the watchdog can remount it.
+type stagedMount struct {
+	mountOptions []string
+	volume       *NetworkStorage
+}
+
+var (
+	// stagedMounts tracks the staged volume mounts on this node by their staging path.
+	stagedMounts      = map[string]*stagedMount{}
+	stagedMountsMutex = sync.Mutex{}
+)
+
+// recordStagedMount registers a staged volume mount with the watchdog.
+func recordStagedMount(path string, volume *NetworkStorage, mountOptions []string) {
+	stagedMountsMutex.Lock()
+	defer stagedMountsMutex.Unlock()
+
+	stagedMounts[path] = &stagedMount{
+		mountOptions: mountOptions,
+		volume:       volume,
+	}
+}
+
+// removeStagedMount removes a staged volume mount from the watchdog.
+func removeStagedMount(path string) {
+	stagedMountsMutex.Lock()
+	defer stagedMountsMutex.Unlock()
+
+	delete(stagedMounts, path)
+}
+
+// checkStagedMounts detects hung or stale staged mounts, e.g. after a storage server reboot, and
+// performs a lazy unmount followed by a remount instead of leaving the workloads stuck forever.
+func checkStagedMounts(d *Driver) {
+	stagedMountsMutex.Lock()
+	mounts := make(map[string]*stagedMount, len(stagedMounts))
+
+	for path, mount := range stagedMounts {
+		mounts[path] = mount
+	}
+
+	stagedMountsMutex.Unlock()
+
+	for path, mount := range mounts {
+		if isMountHealthy(path) {
+			continue
+		}
+
+		debugCloudAction(rtNetworkStorage, "Detected a stale mount (id: %s - path: %s)", mount.volume.ID, path)
+
+		// A lazy unmount detaches the mount immediately while the stale handles are cleaned up in
+		// the background, since a regular unmount would block on the unreachable server.
+		output, err := exec.Command("umount", "-l", path).CombinedOutput()
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to unmount the stale mount (id: %s - path: %s) - Output: %s - Error: %s", mount.volume.ID, path, string(output), err.Error())
+
+			continue
+		}
+
+		err = mount.volume.Mount(path, mount.mountOptions...)
+
+		if err != nil {
+			debugCloudAction(rtNetworkStorage, "Failed to remount the volume (id: %s - path: %s) - Error: %s", mount.volume.ID, path, err.Error())
+
+			continue
+		}
+
+		debugCloudAction(rtNetworkStorage, "Remounted the volume (id: %s - path: %s)", mount.volume.ID, path)
+	}
+}
+
+// isMountHealthy determines if the filesystem behind the given path still responds.
+func isMountHealthy(path string) bool {
+	type statfsResult struct {
+		err error
+	}
+
+	// Retrieve the filesystem statistics in a separate goroutine as the call can hang indefinitely
+	// when the storage server is unreachable.
+	resultChannel := make(chan statfsResult, 1)
+
+	go func() {
+		stats := syscall.Statfs_t{}
+		resultChannel <- statfsResult{err: syscall.Statfs(path, &stats)}
+	}()
+
+	select {
+	case result := <-resultChannel:
+		if errno, ok := result.err.(syscall.Errno); ok && (errno == syscall.ESTALE || errno == syscall.EIO || errno == syscall.ENOTCONN) {
+			return false
+		}
+
+		return true
+	case <-time.After(statfsTimeoutSeconds * time.Second):
+		return false
+	}
+}
+
+// mountWatchdogLoop periodically checks the staged mounts on this node.
+func mountWatchdogLoop(d *Driver) {
+	for {
+		time.Sleep(time.Duration(d.Configuration.MountCheckInterval) * time.Second)
+		checkStagedMounts(d)
+	}
+}
@@ -0,0 +1,60 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookBody describes a Slack compatible webhook payload.
+type webhookBody struct {
+	Text string `json:"text"`
+}
+
+// webhookClient contains the HTTP client for the webhook deliveries.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// notifyWebhook posts a message to the configured webhook. The deliveries happen asynchronously
+// on a best effort basis and never fail the invoking operation.
+func notifyWebhook(d *Driver, format string, v ...interface{}) {
+	if d.Configuration.WebhookURL == "" {
+		return
+	}
+
+	message := fmt.Sprintf(format, v...)
+
+	go func() {
+		body := webhookBody{
+			Text: message,
+		}
+
+		reqBody := new(bytes.Buffer)
+		err := json.NewEncoder(reqBody).Encode(body)
+
+		if err != nil {
+			return
+		}
+
+		res, err := webhookClient.Post(d.Configuration.WebhookURL, "application/json", reqBody)
+
+		if err != nil {
+			debugCloudAction(rtCommon, "Failed to deliver webhook notification - Error: %s", err.Error())
+
+			return
+		}
+
+		defer res.Body.Close()
+
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			debugCloudAction(rtCommon, "Failed to deliver webhook notification - Status: %d", res.StatusCode)
+		}
+	}()
+}
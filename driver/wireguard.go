@@ -0,0 +1,241 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// nsPathWireGuardScript defines the path to the WireGuard setup script on a storage server.
+	nsPathWireGuardScript = "/etc/clouddk_network_storage_wireguard.sh"
+
+	// nsWireGuardInterface defines the name of the WireGuard interface on a storage server.
+	nsWireGuardInterface = "wg0"
+
+	// nsWireGuardPort defines the listen port for WireGuard traffic on a storage server.
+	nsWireGuardPort = 51820
+
+	// nsWireGuardServerAddress defines the tunnel address of a storage server.
+	nsWireGuardServerAddress = "172.31.0.1"
+
+	// pcWireGuardEndpoint specifies the publish context key containing the WireGuard endpoint of a storage server.
+	pcWireGuardEndpoint = "wireguardEndpoint"
+
+	// pcWireGuardNodeAddress specifies the publish context key containing the tunnel address assigned to a node.
+	pcWireGuardNodeAddress = "wireguardNodeAddress"
+
+	// pcWireGuardNodePrivateKey specifies the publish context key containing the private key assigned to a node.
+	pcWireGuardNodePrivateKey = "wireguardNodePrivateKey"
+
+	// pcWireGuardServerAddress specifies the publish context key containing the tunnel address of a storage server.
+	pcWireGuardServerAddress = "wireguardServerAddress"
+
+	// pcWireGuardServerPublicKey specifies the publish context key containing the public key of a storage server.
+	pcWireGuardServerPublicKey = "wireguardServerPublicKey"
+)
+
+// nsWireGuardScript installs WireGuard on a storage server and creates the tunnel interface.
+var nsWireGuardScript = heredoc.Doc(`
+	#!/bin/sh
+	# Specify the required environment variables.
+	export DEBIAN_FRONTEND=noninteractive
+
+	# Install WireGuard in case it is missing.
+	if ! command -v wg >/dev/null 2>&1; then
+		apt-get -qq update
+		apt-get -qq install -y wireguard
+	fi
+
+	# Generate the server key pair, if missing.
+	if [ ! -f /etc/wireguard/privatekey ]; then
+		umask 077
+		wg genkey | tee /etc/wireguard/privatekey | wg pubkey > /etc/wireguard/publickey
+	fi
+
+	# Create the tunnel configuration, if missing.
+	if [ ! -f /etc/wireguard/wg0.conf ]; then
+		(
+			echo '[Interface]'
+			echo 'Address = 172.31.0.1/16'
+			echo 'ListenPort = 51820'
+			echo "PrivateKey = $(cat /etc/wireguard/privatekey)"
+		) > /etc/wireguard/wg0.conf
+	fi
+
+	# Enable and start the tunnel.
+	systemctl enable wg-quick@wg0
+	systemctl restart wg-quick@wg0
+`)
+
+// AddWireGuardPeer authorizes a node on the WireGuard tunnel of the storage server.
+func (ns *NetworkStorage) AddWireGuardPeer(nodeIP string) (nodePrivateKey string, serverPublicKey string, err error) {
+	debugCloudAction(rtNetworkStorage, "Adding WireGuard peer for node '%s' (id: %s)", nodeIP, ns.ID)
+
+	peerAddress := getWireGuardPeerAddress(nodeIP)
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"NODE_PRIVATE_KEY=\"$(wg genkey)\""+
+			"&& wg set "+nsWireGuardInterface+" peer \"$(echo \"$NODE_PRIVATE_KEY\" | wg pubkey)\" allowed-ips "+peerAddress+"/32"+
+			"&& wg-quick save "+nsWireGuardInterface+
+			"&& echo \"$NODE_PRIVATE_KEY\""+
+			"&& cat /etc/wireguard/publickey",
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to add WireGuard peer for node '%s' (id: %s) - Output: %s - Error: %s", nodeIP, ns.ID, string(output), err.Error())
+
+		return "", "", err
+	}
+
+	lines := strings.Fields(strings.TrimSpace(string(output)))
+
+	if len(lines) < 2 {
+		return "", "", fmt.Errorf("Unexpected output while adding WireGuard peer (id: %s)", ns.ID)
+	}
+
+	return lines[len(lines)-2], lines[len(lines)-1], nil
+}
+
+// EnableWireGuard installs and configures WireGuard on the storage server.
+func (ns *NetworkStorage) EnableWireGuard(sshClient *ssh.Client, sftpClient *sftp.Client) (err error) {
+	debugCloudAction(rtNetworkStorage, "Enabling WireGuard (id: %s)", ns.ID)
+
+	err = ns.CreateFile(sftpClient, nsPathWireGuardScript, bytes.NewBufferString(strings.ReplaceAll(nsWireGuardScript, "\r", "")))
+
+	if err != nil {
+		return err
+	}
+
+	sshSession, err := ns.CreateSSHSession(sshClient)
+
+	if err != nil {
+		return err
+	}
+
+	defer sshSession.Close()
+
+	output, err := sshSession.CombinedOutput("/bin/sh " + nsPathWireGuardScript)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to enable WireGuard (id: %s) - Output: %s - Error: %s", ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// RemoveWireGuardPeer revokes a node's access to the WireGuard tunnel of the storage server.
+func (ns *NetworkStorage) RemoveWireGuardPeer(nodeIP string) (err error) {
+	debugCloudAction(rtNetworkStorage, "Removing WireGuard peer for node '%s' (id: %s)", nodeIP, ns.ID)
+
+	peerAddress := getWireGuardPeerAddress(nodeIP)
+	output, err := ns.driver.RemoteExecutor.Execute(
+		ns,
+		"for k in $(wg show "+nsWireGuardInterface+" allowed-ips | grep '"+peerAddress+"/32' | cut -f1); do"+
+			" wg set "+nsWireGuardInterface+" peer \"$k\" remove;"+
+			" done"+
+			"&& wg-quick save "+nsWireGuardInterface,
+	)
+
+	if err != nil {
+		debugCloudAction(rtNetworkStorage, "Failed to remove WireGuard peer for node '%s' (id: %s) - Output: %s - Error: %s", nodeIP, ns.ID, string(output), err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// getWireGuardPeerAddress derives the tunnel address for a node from its public IP address.
+func getWireGuardPeerAddress(nodeIP string) string {
+	octets := strings.Split(nodeIP, ".")
+
+	if len(octets) != 4 {
+		return ""
+	}
+
+	return fmt.Sprintf("172.31.%s.%s", octets[2], octets[3])
+}
+
+// getWireGuardTunnelName returns the name of the local tunnel interface for a volume.
+func getWireGuardTunnelName(id string) string {
+	name := "ck" + id
+
+	if len(name) > 15 {
+		name = name[:15]
+	}
+
+	return name
+}
+
+// setupWireGuardTunnel creates a local WireGuard tunnel to the storage server based on the publish context.
+func setupWireGuardTunnel(id string, publishContext map[string]string) (err error) {
+	tunnelName := getWireGuardTunnelName(id)
+	configPath := fmt.Sprintf("/etc/wireguard/%s.conf", tunnelName)
+
+	config := "[Interface]\n" +
+		"Address = " + publishContext[pcWireGuardNodeAddress] + "/16\n" +
+		"PrivateKey = " + publishContext[pcWireGuardNodePrivateKey] + "\n" +
+		"\n" +
+		"[Peer]\n" +
+		"PublicKey = " + publishContext[pcWireGuardServerPublicKey] + "\n" +
+		"AllowedIPs = " + publishContext[pcWireGuardServerAddress] + "/32\n" +
+		"Endpoint = " + publishContext[pcWireGuardEndpoint] + "\n" +
+		"PersistentKeepalive = 25\n"
+
+	err = os.MkdirAll("/etc/wireguard", 0700)
+
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(configPath, []byte(config), 0600)
+
+	if err != nil {
+		return err
+	}
+
+	// Recreate the tunnel to ensure that the latest configuration is applied.
+	exec.Command("wg-quick", "down", tunnelName).CombinedOutput()
+
+	output, err := exec.Command("wg-quick", "up", tunnelName).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("Failed to create WireGuard tunnel '%s' - Output: %s - Error: %s", tunnelName, string(output), err.Error())
+	}
+
+	return nil
+}
+
+// teardownWireGuardTunnel removes the local WireGuard tunnel to the storage server.
+func teardownWireGuardTunnel(id string) (err error) {
+	tunnelName := getWireGuardTunnelName(id)
+	configPath := fmt.Sprintf("/etc/wireguard/%s.conf", tunnelName)
+
+	_, err = os.Stat(configPath)
+
+	if err != nil {
+		return nil
+	}
+
+	output, err := exec.Command("wg-quick", "down", tunnelName).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("Failed to remove WireGuard tunnel '%s' - Output: %s - Error: %s", tunnelName, string(output), err.Error())
+	}
+
+	return os.Remove(configPath)
+}
@@ -3,25 +3,22 @@ module github.com/danitso/clouddk-csi-driver
 go 1.12
 
 require (
-	cloud.google.com/go v0.44.3 // indirect
 	github.com/MakeNowJust/heredoc v1.0.0
-	github.com/container-storage-interface/spec v1.1.0
+	github.com/container-storage-interface/spec v1.9.0
 	github.com/danitso/terraform-provider-clouddk v0.0.0-20190814213601-762512ccd5a9
-	github.com/golang/protobuf v1.3.2
-	github.com/google/go-cmp v0.3.1 // indirect
-	github.com/google/pprof v0.0.0-20190723021845-34ac40c74b70 // indirect
-	github.com/hashicorp/golang-lru v0.5.3 // indirect
-	github.com/kr/fs v0.1.0 // indirect
-	github.com/kr/pty v1.1.8 // indirect
-	github.com/kubernetes-csi/csi-lib-utils v0.6.1 // indirect
-	github.com/kubernetes-csi/drivers v1.0.2
-	github.com/pkg/sftp v1.10.0
-	github.com/stretchr/objx v0.2.0 // indirect
-	golang.org/x/crypto v0.0.0-20190701094942-4def268fd1a4
-	golang.org/x/mobile v0.0.0-20190814143026-e8b3e6111d02 // indirect
-	golang.org/x/net v0.0.0-20190813141303-74dc4d7220e7 // indirect
-	golang.org/x/sys v0.0.0-20190813064441-fde4db37ae7a // indirect
-	golang.org/x/tools v0.0.0-20190814171936-5b18234b3ae0 // indirect
-	google.golang.org/grpc v1.23.0
-	honnef.co/go/tools v0.0.1-2019.2.2 // indirect
+	github.com/golang/protobuf v1.5.3
+	github.com/kubernetes-csi/csi-lib-utils v0.6.1
+	github.com/pkg/sftp v1.13.1
+	golang.org/x/crypto v0.9.0
+	google.golang.org/grpc v1.57.0
+)
+
+// The CSI specification module declares requirements far beyond what its generated code needs.
+// Pin the shared dependencies to the versions the rest of the tree is built against.
+replace (
+	golang.org/x/crypto => golang.org/x/crypto v0.0.0-20190701094942-4def268fd1a4
+	golang.org/x/net => golang.org/x/net v0.0.0-20190813141303-74dc4d7220e7
+	golang.org/x/sys => golang.org/x/sys v0.0.0-20190813064441-fde4db37ae7a
+	golang.org/x/text => golang.org/x/text v0.3.2
+	google.golang.org/grpc => google.golang.org/grpc v1.23.0
 )
@@ -8,76 +8,533 @@ import (
 	"bytes"
 	"encoding/base64"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/url"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 
 	"github.com/danitso/clouddk-csi-driver/driver"
 	"github.com/danitso/terraform-provider-clouddk/clouddk"
+
+	"golang.org/x/crypto/ssh"
 )
 
 const (
+	// envAdminPort specifies the name of the environment variable containing the port for the localhost admin endpoint.
+	envAdminPort = "CLOUDDK_ADMIN_PORT"
+
+	// envAPICACertFile specifies the name of the environment variable containing the path to the CA bundle for the Cloud.dk API.
+	envAPICACertFile = "CLOUDDK_API_CA_CERT_FILE"
+
 	// envAPIEndpoint specifies the name of the environment variable containing the Cloud.dk API endpoint.
 	envAPIEndpoint = "CLOUDDK_API_ENDPOINT"
 
+	// envAPIInsecureSkipVerify specifies the name of the environment variable containing the TLS verification setting for the Cloud.dk API.
+	envAPIInsecureSkipVerify = "CLOUDDK_API_INSECURE_SKIP_VERIFY"
+
 	// envAPIKey specifies the name of the environment variable containing the Cloud.dk API key.
 	envAPIKey = "CLOUDDK_API_KEY"
 
+	// envAPIKeyFile specifies the name of the environment variable containing the path to a file containing the Cloud.dk API key.
+	envAPIKeyFile = "CLOUDDK_API_KEY_FILE"
+
+	// envAPIProxy specifies the name of the environment variable containing the HTTPS proxy for the Cloud.dk API.
+	envAPIProxy = "CLOUDDK_API_PROXY"
+
+	// envClusterCIDRs specifies the name of the environment variable containing the comma separated cluster networks.
+	envClusterCIDRs = "CLOUDDK_CLUSTER_CIDRS"
+
+	// envClusterID specifies the name of the environment variable containing the cluster identifier for the hostname template.
+	envClusterID = "CLOUDDK_CLUSTER_ID"
+
 	// envCSIEndpointKey specifies the name of the environment variable containing the CSI endpoint.
 	envCSIEndpointKey = "CLOUDDK_CSI_ENDPOINT"
 
+	// envDataDevice specifies the name of the environment variable containing the data disk device path.
+	envDataDevice = "CLOUDDK_DATA_DEVICE"
+
+	// envDebugLogDirectory specifies the name of the environment variable containing the path to the debug log directory.
+	envDebugLogDirectory = "CLOUDDK_DEBUG_LOG_DIRECTORY"
+
+	// envDeletionGracePeriod specifies the name of the environment variable containing the deletion grace period in seconds.
+	envDeletionGracePeriod = "CLOUDDK_DELETION_GRACE_PERIOD"
+
+	// envDNSZone specifies the name of the environment variable containing the DNS zone for the storage servers.
+	envDNSZone = "CLOUDDK_DNS_ZONE"
+
+	// envDriftDetectionInterval specifies the name of the environment variable containing the drift detection interval in seconds.
+	envDriftDetectionInterval = "CLOUDDK_DRIFT_DETECTION_INTERVAL"
+
+	// envDriftRepair specifies the name of the environment variable containing the drift repair setting.
+	envDriftRepair = "CLOUDDK_DRIFT_REPAIR"
+
+	// envDryRun specifies the name of the environment variable containing the dry-run setting.
+	envDryRun = "CLOUDDK_DRY_RUN"
+
+	// envExpandRebootWindow specifies the name of the environment variable containing the expansion reboot window in seconds.
+	envExpandRebootWindow = "CLOUDDK_EXPAND_REBOOT_WINDOW"
+
+	// envIdleScaleDownDays specifies the name of the environment variable containing the number of idle days before a storage server is downsized.
+	envIdleScaleDownDays = "CLOUDDK_IDLE_SCALE_DOWN_DAYS"
+
+	// envJumpHostAddress specifies the name of the environment variable containing the address of the SSH jump host.
+	envJumpHostAddress = "CLOUDDK_JUMP_HOST_ADDRESS"
+
+	// envJumpHostPrivateKey specifies the name of the environment variable containing the Base64 encoded private key for the SSH jump host.
+	envJumpHostPrivateKey = "CLOUDDK_JUMP_HOST_PRIVATE_KEY"
+
+	// envJumpHostUser specifies the name of the environment variable containing the user for the SSH jump host.
+	envJumpHostUser = "CLOUDDK_JUMP_HOST_USER"
+
+	// envMaxConcurrentProvisions specifies the name of the environment variable containing the maximum number of concurrent provisioning operations.
+	envMaxConcurrentProvisions = "CLOUDDK_MAX_CONCURRENT_PROVISIONS"
+
+	// envAutoHeal specifies the name of the environment variable containing the automatic healing setting.
+	envAutoHeal = "CLOUDDK_AUTO_HEAL"
+
+	// envAuditLog specifies the name of the environment variable containing the path to the audit log file.
+	envAuditLog = "CLOUDDK_AUDIT_LOG"
+
+	// envGRPCKeepaliveInterval specifies the name of the environment variable containing the gRPC keepalive interval.
+	envGRPCKeepaliveInterval = "CLOUDDK_GRPC_KEEPALIVE_INTERVAL"
+
+	// envGRPCKeepaliveTimeout specifies the name of the environment variable containing the gRPC keepalive timeout.
+	envGRPCKeepaliveTimeout = "CLOUDDK_GRPC_KEEPALIVE_TIMEOUT"
+
+	// envGRPCMaxMessageSize specifies the name of the environment variable containing the maximum gRPC message size.
+	envGRPCMaxMessageSize = "CLOUDDK_GRPC_MAX_MESSAGE_SIZE"
+
+	// envGRPCReflection specifies the name of the environment variable containing the gRPC reflection setting.
+	envGRPCReflection = "CLOUDDK_GRPC_REFLECTION"
+
+	// envGRPCTimeout specifies the name of the environment variable containing the gRPC call timeout.
+	envGRPCTimeout = "CLOUDDK_GRPC_TIMEOUT"
+
+	// envHealthProbeInterval specifies the name of the environment variable containing the health probe interval.
+	envHealthProbeInterval = "CLOUDDK_HEALTH_PROBE_INTERVAL"
+
+	// envHostnameTemplate specifies the name of the environment variable containing the hostname template.
+	envHostnameTemplate = "CLOUDDK_HOSTNAME_TEMPLATE"
+
+	// envKubernetesEvents specifies the name of the environment variable containing the Kubernetes events setting.
+	envKubernetesEvents = "CLOUDDK_KUBERNETES_EVENTS"
+
+	// envMaintenanceWindow specifies the name of the environment variable containing the maintenance window.
+	envMaintenanceWindow = "CLOUDDK_MAINTENANCE_WINDOW"
+
+	// envMaxStorageServers specifies the name of the environment variable containing the maximum number of storage servers.
+	envMaxStorageServers = "CLOUDDK_MAX_STORAGE_SERVERS"
+
+	// envMaxTotalCapacity specifies the name of the environment variable containing the maximum total provisioned capacity in gigabytes.
+	envMaxTotalCapacity = "CLOUDDK_MAX_TOTAL_CAPACITY"
+
+	// envMetricsPort specifies the name of the environment variable containing the port for the metrics endpoint.
+	envMetricsPort = "CLOUDDK_METRICS_PORT"
+
+	// envMaxVolumesPerNode specifies the name of the environment variable containing the maximum number of volumes per node.
+	envMaxVolumesPerNode = "CLOUDDK_MAX_VOLUMES_PER_NODE"
+
+	// envMountCheckInterval specifies the name of the environment variable containing the mount check interval in seconds.
+	envMountCheckInterval = "CLOUDDK_MOUNT_CHECK_INTERVAL"
+
+	// envNamespaceQuota specifies the name of the environment variable containing the per namespace capacity quota in gigabytes.
+	envNamespaceQuota = "CLOUDDK_NAMESPACE_QUOTA"
+
+	// envNetworkEncryption specifies the name of the environment variable containing the network encryption setting.
+	envNetworkEncryption = "CLOUDDK_NETWORK_ENCRYPTION"
+
+	// envNFSLockdPort specifies the name of the environment variable containing the port for the NFS lock daemon.
+	envNFSLockdPort = "CLOUDDK_NFS_LOCKD_PORT"
+
+	// envNFSMountdPort specifies the name of the environment variable containing the port for the NFS mount daemon.
+	envNFSMountdPort = "CLOUDDK_NFS_MOUNTD_PORT"
+
+	// envNFSStatdPort specifies the name of the environment variable containing the port for the NFS status daemon.
+	envNFSStatdPort = "CLOUDDK_NFS_STATD_PORT"
+
 	// envNodeID specifies the name of the environment variable containing the node identifier.
 	envNodeID = "CLOUDDK_NODE_ID"
 
+	// envProfileRolloutPercentage specifies the name of the environment variable containing the percentage of outdated storage servers to upgrade at a time.
+	envProfileRolloutPercentage = "CLOUDDK_PROFILE_ROLLOUT_PERCENTAGE"
+
 	// envServerMemory specifies the name of the environment variable containing the amount of memory per storage server.
 	envServerMemory = "CLOUDDK_SERVER_MEMORY"
 
+	// envServerPackageID specifies the name of the environment variable containing the exact package id for storage servers.
+	envServerPackageID = "CLOUDDK_SERVER_PACKAGE_ID"
+
 	// envServerProcessors specifies the name of the environment variable containing the number of processors per storage server.
 	envServerProcessors = "CLOUDDK_SERVER_PROCESSORS"
 
+	// envNodeExporter specifies the name of the environment variable containing the node exporter setting.
+	envNodeExporter = "CLOUDDK_NODE_EXPORTER"
+
+	// envOfflineBundle specifies the name of the environment variable containing the path to the offline dependency bundle.
+	envOfflineBundle = "CLOUDDK_OFFLINE_BUNDLE"
+
+	// envSkipStaging specifies the name of the environment variable containing the skip staging setting.
+	envSkipStaging = "CLOUDDK_SKIP_STAGING"
+
+	// envSocketGID specifies the name of the environment variable containing the group id to assign to the CSI socket.
+	envSocketGID = "CLOUDDK_SOCKET_GID"
+
+	// envSocketMode specifies the name of the environment variable containing the octal file mode to assign to the CSI socket.
+	envSocketMode = "CLOUDDK_SOCKET_MODE"
+
+	// envSocketUID specifies the name of the environment variable containing the user id to assign to the CSI socket.
+	envSocketUID = "CLOUDDK_SOCKET_UID"
+
+	// envSSHKeyDir specifies the name of the environment variable containing the path to a directory for an automatically generated SSH key pair.
+	envSSHKeyDir = "CLOUDDK_SSH_KEY_DIR"
+
 	// envSSHPrivateKey specifies the name of the environment variable containing the Base64 encoded private key for SSH connections.
 	envSSHPrivateKey = "CLOUDDK_SSH_PRIVATE_KEY"
 
+	// envSSHPrivateKeyFile specifies the name of the environment variable containing the path to a file containing the private key for SSH connections.
+	envSSHPrivateKeyFile = "CLOUDDK_SSH_PRIVATE_KEY_FILE"
+
+	// envStrictVersionSkew specifies the name of the environment variable containing the version skew policy.
+	envStrictVersionSkew = "CLOUDDK_STRICT_VERSION_SKEW"
+
+	// envTLSCACertFile specifies the name of the environment variable containing the path to the certificate authority file for client authentication.
+	envTLSCACertFile = "CLOUDDK_TLS_CA_CERT_FILE"
+
+	// envTLSCertFile specifies the name of the environment variable containing the path to the TLS certificate file for TCP endpoints.
+	envTLSCertFile = "CLOUDDK_TLS_CERT_FILE"
+
+	// envTLSKeyFile specifies the name of the environment variable containing the path to the TLS key file for TCP endpoints.
+	envTLSKeyFile = "CLOUDDK_TLS_KEY_FILE"
+
+	// envWebhookURL specifies the name of the environment variable containing the URL for the webhook notifications.
+	envWebhookURL = "CLOUDDK_WEBHOOK_URL"
+
 	// envSSHPublicKey specifies the name of the environment variable containing the Base64 encoded public key for SSH connections.
 	envSSHPublicKey = "CLOUDDK_SSH_PUBLIC_KEY"
 
+	// envSSHPublicKeyFile specifies the name of the environment variable containing the path to a file containing the public key for SSH connections.
+	envSSHPublicKeyFile = "CLOUDDK_SSH_PUBLIC_KEY_FILE"
+
+	// flagAdminPort specifies the name of the command line option containing the port for the localhost admin endpoint.
+	flagAdminPort = "admin-port"
+
+	// flagAPICACertFile specifies the name of the command line option containing the path to the CA bundle for the Cloud.dk API.
+	flagAPICACertFile = "api-ca-cert-file"
+
 	// flagAPIEndpoint specifies the name of the command line option containing the Cloud.dk API endpoint.
 	flagAPIEndpoint = "api-endpoint"
 
+	// flagAPIInsecureSkipVerify specifies the name of the command line option containing the TLS verification setting for the Cloud.dk API.
+	flagAPIInsecureSkipVerify = "api-insecure-skip-verify"
+
 	// flagAPIKey specifies the name of the command line option containing the Cloud.dk API key.
 	flagAPIKey = "api-key"
 
+	// flagAPIKeyFile specifies the name of the command line option containing the path to a file containing the Cloud.dk API key.
+	flagAPIKeyFile = "api-key-file"
+
+	// flagAPIProxy specifies the name of the command line option containing the HTTPS proxy for the Cloud.dk API.
+	flagAPIProxy = "api-proxy"
+
+	// flagClusterCIDRs specifies the name of the command line option containing the comma separated cluster networks.
+	flagClusterCIDRs = "cluster-cidrs"
+
+	// flagClusterID specifies the name of the command line option containing the cluster identifier for the hostname template.
+	flagClusterID = "cluster-id"
+
 	// flagCSIEndpoint specifies the name of the command line option containing the CSI endpoint.
 	flagCSIEndpoint = "csi-endpoint"
 
+	// flagDataDevice specifies the name of the command line option containing the data disk device path.
+	flagDataDevice = "data-device"
+
+	// flagDebugLogDirectory specifies the name of the command line option containing the path to the debug log directory.
+	flagDebugLogDirectory = "debug-log-directory"
+
+	// flagDeletionGracePeriod specifies the name of the command line option containing the deletion grace period in seconds.
+	flagDeletionGracePeriod = "deletion-grace-period"
+
+	// flagDNSZone specifies the name of the command line option containing the DNS zone for the storage servers.
+	flagDNSZone = "dns-zone"
+
+	// flagDriftDetectionInterval specifies the name of the command line option containing the drift detection interval in seconds.
+	flagDriftDetectionInterval = "drift-detection-interval"
+
+	// flagDriftRepair specifies the name of the command line option containing the drift repair setting.
+	flagDriftRepair = "drift-repair"
+
+	// flagDryRun specifies the name of the command line option containing the dry-run setting.
+	flagDryRun = "dry-run"
+
+	// flagExpandRebootWindow specifies the name of the command line option containing the expansion reboot window in seconds.
+	flagExpandRebootWindow = "expand-reboot-window"
+
+	// flagIdleScaleDownDays specifies the name of the command line option containing the number of idle days before a storage server is downsized.
+	flagIdleScaleDownDays = "idle-scale-down-days"
+
+	// flagJumpHostAddress specifies the name of the command line option containing the address of the SSH jump host.
+	flagJumpHostAddress = "jump-host-address"
+
+	// flagJumpHostPrivateKey specifies the name of the command line option containing the Base64 encoded private key for the SSH jump host.
+	flagJumpHostPrivateKey = "jump-host-private-key"
+
+	// flagJumpHostUser specifies the name of the command line option containing the user for the SSH jump host.
+	flagJumpHostUser = "jump-host-user"
+
+	// flagMaxConcurrentProvisions specifies the name of the command line option containing the maximum number of concurrent provisioning operations.
+	flagMaxConcurrentProvisions = "max-concurrent-provisions"
+
+	// flagAutoHeal specifies the name of the command line option containing the automatic healing setting.
+	flagAutoHeal = "auto-heal"
+
+	// flagAuditLog specifies the name of the command line option containing the path to the audit log file.
+	flagAuditLog = "audit-log"
+
+	// flagGRPCKeepaliveInterval specifies the name of the command line option containing the gRPC keepalive interval.
+	flagGRPCKeepaliveInterval = "grpc-keepalive-interval"
+
+	// flagGRPCKeepaliveTimeout specifies the name of the command line option containing the gRPC keepalive timeout.
+	flagGRPCKeepaliveTimeout = "grpc-keepalive-timeout"
+
+	// flagGRPCMaxMessageSize specifies the name of the command line option containing the maximum gRPC message size.
+	flagGRPCMaxMessageSize = "grpc-max-message-size"
+
+	// flagGRPCReflection specifies the name of the command line option containing the gRPC reflection setting.
+	flagGRPCReflection = "grpc-reflection"
+
+	// flagGRPCTimeout specifies the name of the command line option containing the gRPC call timeout.
+	flagGRPCTimeout = "grpc-timeout"
+
+	// flagHealthProbeInterval specifies the name of the command line option containing the health probe interval.
+	flagHealthProbeInterval = "health-probe-interval"
+
+	// flagHostnameTemplate specifies the name of the command line option containing the hostname template.
+	flagHostnameTemplate = "hostname-template"
+
+	// flagKubernetesEvents specifies the name of the command line option containing the Kubernetes events setting.
+	flagKubernetesEvents = "kubernetes-events"
+
+	// flagMaintenanceWindow specifies the name of the command line option containing the maintenance window.
+	flagMaintenanceWindow = "maintenance-window"
+
+	// flagMaxStorageServers specifies the name of the command line option containing the maximum number of storage servers.
+	flagMaxStorageServers = "max-storage-servers"
+
+	// flagMaxTotalCapacity specifies the name of the command line option containing the maximum total provisioned capacity in gigabytes.
+	flagMaxTotalCapacity = "max-total-capacity"
+
+	// flagMetricsPort specifies the name of the command line option containing the port for the metrics endpoint.
+	flagMetricsPort = "metrics-port"
+
+	// flagMaxVolumesPerNode specifies the name of the command line option containing the maximum number of volumes per node.
+	flagMaxVolumesPerNode = "max-volumes-per-node"
+
+	// flagMountCheckInterval specifies the name of the command line option containing the mount check interval in seconds.
+	flagMountCheckInterval = "mount-check-interval"
+
+	// flagFreezeTimeout specifies the name of the command line option containing the freeze time window in seconds.
+	flagFreezeTimeout = "freeze-timeout"
+
+	// flagFreezeVolume specifies the name of the command line option containing the identifier of a volume to freeze.
+	flagFreezeVolume = "freeze-volume"
+
+	// flagNamespaceQuota specifies the name of the command line option containing the per namespace capacity quota in gigabytes.
+	flagNamespaceQuota = "namespace-quota"
+
+	// flagNetworkEncryption specifies the name of the command line option containing the network encryption setting.
+	flagNetworkEncryption = "network-encryption"
+
+	// flagNFSLockdPort specifies the name of the command line option containing the port for the NFS lock daemon.
+	flagNFSLockdPort = "nfs-lockd-port"
+
+	// flagNFSMountdPort specifies the name of the command line option containing the port for the NFS mount daemon.
+	flagNFSMountdPort = "nfs-mountd-port"
+
+	// flagNFSStatdPort specifies the name of the command line option containing the port for the NFS status daemon.
+	flagNFSStatdPort = "nfs-statd-port"
+
 	// flagNodeID specifies the name of the command line option containing the node identifier.
 	flagNodeID = "node-id"
 
+	// flagProfileRolloutPercentage specifies the name of the command line option containing the percentage of outdated storage servers to upgrade at a time.
+	flagProfileRolloutPercentage = "profile-rollout-percentage"
+
 	// flagServerMemory specifies the name of the command line option containing the amount of memory per storage server.
 	flagServerMemory = "server-memory"
 
+	// flagServerPackageID specifies the name of the command line option containing the exact package id for storage servers.
+	flagServerPackageID = "server-package-id"
+
 	// flagServerProcessors specifies the name of the command line option containing the number of processors per storage server.
 	flagServerProcessors = "server-processors"
 
+	// flagNodeExporter specifies the name of the command line option containing the node exporter setting.
+	flagNodeExporter = "node-exporter"
+
+	// flagOfflineBundle specifies the name of the command line option containing the path to the offline dependency bundle.
+	flagOfflineBundle = "offline-bundle"
+
+	// flagRebuildVolume specifies the name of the command line option containing the identifier of a volume to rebuild.
+	flagRebuildVolume = "rebuild-volume"
+
+	// flagUpgradeConcurrency specifies the name of the command line option containing the concurrency for the fleet upgrade.
+	flagUpgradeConcurrency = "upgrade-concurrency"
+
+	// flagUpgradeFleet specifies the name of the command line option containing the fleet upgrade setting.
+	flagUpgradeFleet = "upgrade-fleet"
+
+	// flagRotateSSHKey specifies the name of the command line option containing the Base64 encoded public key to rotate to.
+	flagRotateSSHKey = "rotate-ssh-key"
+
+	// flagSkipStaging specifies the name of the command line option containing the skip staging setting.
+	flagSkipStaging = "skip-staging"
+
+	// flagSocketGID specifies the name of the command line option containing the group id to assign to the CSI socket.
+	flagSocketGID = "socket-gid"
+
+	// flagSocketMode specifies the name of the command line option containing the octal file mode to assign to the CSI socket.
+	flagSocketMode = "socket-mode"
+
+	// flagSocketUID specifies the name of the command line option containing the user id to assign to the CSI socket.
+	flagSocketUID = "socket-uid"
+
+	// flagSSHKeyDir specifies the name of the command line option containing the path to a directory for an automatically generated SSH key pair.
+	flagSSHKeyDir = "ssh-key-dir"
+
 	// flagSSHPrivateKey specifies the name of the command line option containing the Base64 encoded private key for SSH connections.
 	flagSSHPrivateKey = "ssh-private-key"
 
+	// flagSSHPrivateKeyFile specifies the name of the command line option containing the path to a file containing the private key for SSH connections.
+	flagSSHPrivateKeyFile = "ssh-private-key-file"
+
+	// flagStrictVersionSkew specifies the name of the command line option containing the version skew policy.
+	flagStrictVersionSkew = "strict-version-skew"
+
+	// flagThawVolume specifies the name of the command line option containing the identifier of a volume to thaw.
+	flagThawVolume = "thaw-volume"
+
+	// flagTLSCACertFile specifies the name of the command line option containing the path to the certificate authority file for client authentication.
+	flagTLSCACertFile = "tls-ca-cert-file"
+
+	// flagTLSCertFile specifies the name of the command line option containing the path to the TLS certificate file for TCP endpoints.
+	flagTLSCertFile = "tls-cert-file"
+
+	// flagTLSKeyFile specifies the name of the command line option containing the path to the TLS key file for TCP endpoints.
+	flagTLSKeyFile = "tls-key-file"
+
+	// flagWebhookURL specifies the name of the command line option containing the URL for the webhook notifications.
+	flagWebhookURL = "webhook-url"
+
+	// flagVersion specifies the name of the command line option indicating that the version information must be printed.
+	flagVersion = "version"
+
 	// flagSSHPublicKey specifies the name of the command line option containing the Base64 encoded public key for SSH connections.
 	flagSSHPublicKey = "ssh-public-key"
+
+	// flagSSHPublicKeyFile specifies the name of the command line option containing the path to a file containing the public key for SSH connections.
+	flagSSHPublicKeyFile = "ssh-public-key-file"
 )
 
+// splitCommandArgs separates an optional admin subcommand from the command line flags.
+func splitCommandArgs(args []string) (command []string, flagArgs []string) {
+	command = []string{}
+	flagArgs = args
+
+	for len(flagArgs) > 0 {
+		if (flagArgs[0] == "--location" || flagArgs[0] == "--package" || flagArgs[0] == "--target") && len(flagArgs) > 1 {
+			command = append(command, flagArgs[0], flagArgs[1])
+			flagArgs = flagArgs[2:]
+
+			continue
+		}
+
+		if strings.HasPrefix(flagArgs[0], "-") && flagArgs[0] != "--orphans" {
+			break
+		}
+
+		command = append(command, flagArgs[0])
+		flagArgs = flagArgs[1:]
+	}
+
+	return command, flagArgs
+}
+
 func main() {
+	// Separate an optional admin subcommand from the command line flags.
+	command, flagArgs := splitCommandArgs(os.Args[1:])
+
 	// Parse the environment variables and command line flags.
 	var (
-		apiEndpointEnv      = os.Getenv(envAPIEndpoint)
-		apiKeyEnv           = os.Getenv(envAPIKey)
-		csiEndpointEnv      = os.Getenv(envCSIEndpointKey)
-		nodeIDEnv           = os.Getenv(envNodeID)
-		serverMemoryEnv     = os.Getenv(envServerMemory)
-		serverProcessorsEnv = os.Getenv(envServerProcessors)
-		sshPrivateKeyEnv    = os.Getenv(envSSHPrivateKey)
-		sshPublicKeyEnv     = os.Getenv(envSSHPublicKey)
+		adminPortEnv                = os.Getenv(envAdminPort)
+		apiCACertFileEnv            = os.Getenv(envAPICACertFile)
+		apiEndpointEnv              = os.Getenv(envAPIEndpoint)
+		apiInsecureSkipVerifyEnv    = os.Getenv(envAPIInsecureSkipVerify)
+		apiKeyEnv                   = os.Getenv(envAPIKey)
+		apiKeyFileEnv               = os.Getenv(envAPIKeyFile)
+		apiProxyEnv                 = os.Getenv(envAPIProxy)
+		auditLogEnv                 = os.Getenv(envAuditLog)
+		autoHealEnv                 = os.Getenv(envAutoHeal)
+		clusterCIDRsEnv             = os.Getenv(envClusterCIDRs)
+		clusterIDEnv                = os.Getenv(envClusterID)
+		csiEndpointEnv              = os.Getenv(envCSIEndpointKey)
+		dataDeviceEnv               = os.Getenv(envDataDevice)
+		debugLogDirectoryEnv        = os.Getenv(envDebugLogDirectory)
+		deletionGracePeriodEnv      = os.Getenv(envDeletionGracePeriod)
+		dnsZoneEnv                  = os.Getenv(envDNSZone)
+		driftDetectionIntervalEnv   = os.Getenv(envDriftDetectionInterval)
+		driftRepairEnv              = os.Getenv(envDriftRepair)
+		dryRunEnv                   = os.Getenv(envDryRun)
+		expandRebootWindowEnv       = os.Getenv(envExpandRebootWindow)
+		idleScaleDownDaysEnv        = os.Getenv(envIdleScaleDownDays)
+		jumpHostAddressEnv          = os.Getenv(envJumpHostAddress)
+		jumpHostPrivateKeyEnv       = os.Getenv(envJumpHostPrivateKey)
+		jumpHostUserEnv             = os.Getenv(envJumpHostUser)
+		maxConcurrentProvisionsEnv  = os.Getenv(envMaxConcurrentProvisions)
+		grpcKeepaliveIntervalEnv    = os.Getenv(envGRPCKeepaliveInterval)
+		grpcKeepaliveTimeoutEnv     = os.Getenv(envGRPCKeepaliveTimeout)
+		grpcMaxMessageSizeEnv       = os.Getenv(envGRPCMaxMessageSize)
+		grpcReflectionEnv           = os.Getenv(envGRPCReflection)
+		grpcTimeoutEnv              = os.Getenv(envGRPCTimeout)
+		healthProbeIntervalEnv      = os.Getenv(envHealthProbeInterval)
+		hostnameTemplateEnv         = os.Getenv(envHostnameTemplate)
+		kubernetesEventsEnv         = os.Getenv(envKubernetesEvents)
+		maintenanceWindowEnv        = os.Getenv(envMaintenanceWindow)
+		maxStorageServersEnv        = os.Getenv(envMaxStorageServers)
+		maxTotalCapacityEnv         = os.Getenv(envMaxTotalCapacity)
+		maxVolumesPerNodeEnv        = os.Getenv(envMaxVolumesPerNode)
+		metricsPortEnv              = os.Getenv(envMetricsPort)
+		mountCheckIntervalEnv       = os.Getenv(envMountCheckInterval)
+		namespaceQuotaEnv           = os.Getenv(envNamespaceQuota)
+		networkEncryptionEnv        = os.Getenv(envNetworkEncryption)
+		nfsLockdPortEnv             = os.Getenv(envNFSLockdPort)
+		nfsMountdPortEnv            = os.Getenv(envNFSMountdPort)
+		nfsStatdPortEnv             = os.Getenv(envNFSStatdPort)
+		nodeIDEnv                   = os.Getenv(envNodeID)
+		profileRolloutPercentageEnv = os.Getenv(envProfileRolloutPercentage)
+		serverMemoryEnv             = os.Getenv(envServerMemory)
+		serverPackageIDEnv          = os.Getenv(envServerPackageID)
+		serverProcessorsEnv         = os.Getenv(envServerProcessors)
+		nodeExporterEnv             = os.Getenv(envNodeExporter)
+		offlineBundleEnv            = os.Getenv(envOfflineBundle)
+		skipStagingEnv              = os.Getenv(envSkipStaging)
+		socketGIDEnv                = os.Getenv(envSocketGID)
+		socketModeEnv               = os.Getenv(envSocketMode)
+		socketUIDEnv                = os.Getenv(envSocketUID)
+		sshKeyDirEnv                = os.Getenv(envSSHKeyDir)
+		sshPrivateKeyEnv            = os.Getenv(envSSHPrivateKey)
+		sshPrivateKeyFileEnv        = os.Getenv(envSSHPrivateKeyFile)
+		sshPublicKeyEnv             = os.Getenv(envSSHPublicKey)
+		sshPublicKeyFileEnv         = os.Getenv(envSSHPublicKeyFile)
+		strictVersionSkewEnv        = os.Getenv(envStrictVersionSkew)
+		tlsCACertFileEnv            = os.Getenv(envTLSCACertFile)
+		tlsCertFileEnv              = os.Getenv(envTLSCertFile)
+		tlsKeyFileEnv               = os.Getenv(envTLSKeyFile)
+		webhookURLEnv               = os.Getenv(envWebhookURL)
 	)
 
 	if apiEndpointEnv == "" {
@@ -88,121 +545,930 @@ func main() {
 		csiEndpointEnv = "unix:///var/lib/kubelet/plugins/" + driver.DriverName + "/csi.sock"
 	}
 
+	deletionGracePeriod := 0
+	driftDetectionInterval := 0
+	driftRepair := false
+	dryRun := false
+	expandRebootWindow := 0
+	idleScaleDownDays := 0
+	maxConcurrentProvisions := 0
+	adminPort := 0
+	apiInsecureSkipVerify := false
+	autoHeal := false
+	grpcKeepaliveInterval := 0
+	grpcKeepaliveTimeout := 0
+	grpcMaxMessageSize := 0
+	grpcReflection := false
+	grpcTimeout := 0
+	healthProbeInterval := 0
+	kubernetesEvents := false
+	maxStorageServers := 0
+	maxTotalCapacity := 0
+	maxVolumesPerNode := 128
+	metricsPort := 0
+	mountCheckInterval := 0
+	namespaceQuota := 0
+	networkEncryption := false
+	nfsLockdPort := 2051
+	nfsMountdPort := 2052
+	nfsStatdPort := 2050
+	profileRolloutPercentage := 0
 	serverMemory := 4096
 	serverProcessors := 2
+	nodeExporter := false
+	skipStaging := false
+	socketGID := -1
+	socketMode := ""
+	socketUID := -1
+	strictVersionSkew := false
 
-	if serverMemoryEnv != "" {
-		i, err := strconv.Atoi(serverMemoryEnv)
+	if strictVersionSkewEnv != "" {
+		b, err := strconv.ParseBool(strictVersionSkewEnv)
 
 		if err != nil {
 			log.Fatalln(err)
 		}
 
-		serverMemory = i
+		strictVersionSkew = b
 	}
 
-	if serverProcessorsEnv != "" {
-		i, err := strconv.Atoi(serverProcessorsEnv)
+	if namespaceQuotaEnv != "" {
+		i, err := strconv.Atoi(namespaceQuotaEnv)
 
 		if err != nil {
 			log.Fatalln(err)
 		}
 
-		serverProcessors = i
+		namespaceQuota = i
 	}
 
-	var (
-		apiEndpointFlag      = flag.String(flagAPIEndpoint, apiEndpointEnv, "The API endpoint")
-		apiKeyFlag           = flag.String(flagAPIKey, apiKeyEnv, "The API key")
-		csiEndpointFlag      = flag.String(flagCSIEndpoint, csiEndpointEnv, "The CSI endpoint")
-		nodeIDFlag           = flag.String(flagNodeID, nodeIDEnv, "The node id")
-		serverMemoryFlag     = flag.Int(flagServerMemory, serverMemory, "The minimum amount of memory per storage server")
-		serverProcessorsFlag = flag.Int(flagServerProcessors, serverProcessors, "The minimum number of processors per storage server")
-		sshPrivateKeyFlag    = flag.String(flagSSHPrivateKey, sshPrivateKeyEnv, "The Base64 encoded private key for SSH connections")
-		sshPublicKeyFlag     = flag.String(flagSSHPublicKey, sshPublicKeyEnv, "The Base64 encoded public key for SSH connections")
-	)
+	if networkEncryptionEnv != "" {
+		b, err := strconv.ParseBool(networkEncryptionEnv)
 
-	flag.Parse()
+		if err != nil {
+			log.Fatalln(err)
+		}
 
-	// Verify that all the required properties are defined and appear to be valid.
-	if *apiEndpointFlag == "" {
-		log.Fatalln("You must specify an API endpoint (-api-endpoint or CLOUDDK_API_ENDPOINT)")
+		networkEncryption = b
 	}
 
-	_, err := url.ParseRequestURI(*apiEndpointFlag)
+	if deletionGracePeriodEnv != "" {
+		i, err := strconv.Atoi(deletionGracePeriodEnv)
 
-	if err != nil {
-		log.Fatalln(err)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		deletionGracePeriod = i
 	}
 
-	if *apiKeyFlag == "" {
-		log.Fatalln("You must specify an API key (-api-key or CLOUDDK_API_KEY)")
+	if driftDetectionIntervalEnv != "" {
+		i, err := strconv.Atoi(driftDetectionIntervalEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		driftDetectionInterval = i
 	}
 
-	if *csiEndpointFlag == "" {
-		log.Fatalln("You must specify a CSI endpoint (-csi-endpoint or CLOUDDK_CSI_ENDPOINT)")
+	if driftRepairEnv != "" {
+		b, err := strconv.ParseBool(driftRepairEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		driftRepair = b
 	}
 
-	if *nodeIDFlag == "" {
-		log.Fatalln("You must specify a node id (-node-id or CLOUDDK_NODE_ID)")
+	if dryRunEnv != "" {
+		b, err := strconv.ParseBool(dryRunEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		dryRun = b
 	}
 
-	if *serverMemoryFlag < 1 {
-		log.Fatalln("You must specify the minimum amount of memory per storage server (-server-memory or CLOUDDK_SERVER_MEMORY)")
+	if expandRebootWindowEnv != "" {
+		i, err := strconv.Atoi(expandRebootWindowEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		expandRebootWindow = i
 	}
 
-	if *serverProcessorsFlag < 1 {
-		log.Fatalln("You must specify the minimum number of processors per storage server (-server-processors or CLOUDDK_SERVER_PROCESSORS)")
+	if idleScaleDownDaysEnv != "" {
+		i, err := strconv.Atoi(idleScaleDownDaysEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		idleScaleDownDays = i
+	}
+
+	if maxConcurrentProvisionsEnv != "" {
+		i, err := strconv.Atoi(maxConcurrentProvisionsEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		maxConcurrentProvisions = i
 	}
 
-	if *sshPrivateKeyFlag == "" {
-		log.Fatalln("You must specify a private SSH key (-ssh-private-key or CLOUDDK_SSH_PRIVATE_KEY)")
+	if adminPortEnv != "" {
+		i, err := strconv.Atoi(adminPortEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		adminPort = i
 	}
 
-	if *sshPublicKeyFlag == "" {
-		log.Fatalln("You must specify a public SSH key (-ssh-public-key or CLOUDDK_SSH_PUBLIC_KEY)")
+	if apiInsecureSkipVerifyEnv != "" {
+		b, err := strconv.ParseBool(apiInsecureSkipVerifyEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		apiInsecureSkipVerify = b
 	}
 
-	// Decode the private and public SSH keys.
-	if *sshPrivateKeyFlag != "" {
-		key, err := base64.StdEncoding.DecodeString(*sshPrivateKeyFlag)
+	if autoHealEnv != "" {
+		b, err := strconv.ParseBool(autoHealEnv)
 
 		if err != nil {
 			log.Fatalln(err)
 		}
 
-		*sshPrivateKeyFlag = bytes.NewBuffer(key).String()
+		autoHeal = b
 	}
 
-	if *sshPublicKeyFlag != "" {
-		key, err := base64.StdEncoding.DecodeString(*sshPublicKeyFlag)
+	if grpcKeepaliveIntervalEnv != "" {
+		i, err := strconv.Atoi(grpcKeepaliveIntervalEnv)
 
 		if err != nil {
 			log.Fatalln(err)
 		}
 
-		*sshPublicKeyFlag = bytes.NewBuffer(key).String()
+		grpcKeepaliveInterval = i
 	}
 
-	// Initialize the driver.
-	c := driver.Configuration{
-		ClientSettings: &clouddk.ClientSettings{
-			Endpoint: *apiEndpointFlag,
-			Key:      *apiKeyFlag,
-		},
-		Endpoint:         *csiEndpointFlag,
-		NodeID:           *nodeIDFlag,
-		PrivateKey:       *sshPrivateKeyFlag,
-		PublicKey:        *sshPublicKeyFlag,
-		ServerMemory:     *serverMemoryFlag,
-		ServerProcessors: *serverProcessorsFlag,
+	if grpcKeepaliveTimeoutEnv != "" {
+		i, err := strconv.Atoi(grpcKeepaliveTimeoutEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		grpcKeepaliveTimeout = i
 	}
 
-	drv, err := driver.NewDriver(&c)
+	if grpcMaxMessageSizeEnv != "" {
+		i, err := strconv.Atoi(grpcMaxMessageSizeEnv)
 
-	if err != nil {
-		log.Fatalln(err)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		grpcMaxMessageSize = i
 	}
 
-	drv.Run()
+	if grpcReflectionEnv != "" {
+		b, err := strconv.ParseBool(grpcReflectionEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		grpcReflection = b
+	}
+
+	if grpcTimeoutEnv != "" {
+		i, err := strconv.Atoi(grpcTimeoutEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		grpcTimeout = i
+	}
+
+	if healthProbeIntervalEnv != "" {
+		i, err := strconv.Atoi(healthProbeIntervalEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		healthProbeInterval = i
+	}
+
+	if kubernetesEventsEnv != "" {
+		b, err := strconv.ParseBool(kubernetesEventsEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		kubernetesEvents = b
+	}
+
+	if maxStorageServersEnv != "" {
+		i, err := strconv.Atoi(maxStorageServersEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		maxStorageServers = i
+	}
+
+	if maxTotalCapacityEnv != "" {
+		i, err := strconv.Atoi(maxTotalCapacityEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		maxTotalCapacity = i
+	}
+
+	if maxVolumesPerNodeEnv != "" {
+		i, err := strconv.Atoi(maxVolumesPerNodeEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		maxVolumesPerNode = i
+	}
+
+	if metricsPortEnv != "" {
+		i, err := strconv.Atoi(metricsPortEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		metricsPort = i
+	}
+
+	if mountCheckIntervalEnv != "" {
+		i, err := strconv.Atoi(mountCheckIntervalEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		mountCheckInterval = i
+	}
+
+	if nfsLockdPortEnv != "" {
+		i, err := strconv.Atoi(nfsLockdPortEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		nfsLockdPort = i
+	}
+
+	if nfsMountdPortEnv != "" {
+		i, err := strconv.Atoi(nfsMountdPortEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		nfsMountdPort = i
+	}
+
+	if nfsStatdPortEnv != "" {
+		i, err := strconv.Atoi(nfsStatdPortEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		nfsStatdPort = i
+	}
+
+	if profileRolloutPercentageEnv != "" {
+		i, err := strconv.Atoi(profileRolloutPercentageEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		profileRolloutPercentage = i
+	}
+
+	if serverMemoryEnv != "" {
+		i, err := strconv.Atoi(serverMemoryEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		serverMemory = i
+	}
+
+	if serverProcessorsEnv != "" {
+		i, err := strconv.Atoi(serverProcessorsEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		serverProcessors = i
+	}
+
+	if nodeExporterEnv != "" {
+		b, err := strconv.ParseBool(nodeExporterEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		nodeExporter = b
+	}
+
+	if skipStagingEnv != "" {
+		b, err := strconv.ParseBool(skipStagingEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		skipStaging = b
+	}
+
+	if socketGIDEnv != "" {
+		i, err := strconv.Atoi(socketGIDEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		socketGID = i
+	}
+
+	if socketModeEnv != "" {
+		socketMode = socketModeEnv
+	}
+
+	if socketUIDEnv != "" {
+		i, err := strconv.Atoi(socketUIDEnv)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		socketUID = i
+	}
+
+	var (
+		adminPortFlag                = flag.Int(flagAdminPort, adminPort, "The port for the localhost admin endpoint (0 disables the endpoint)")
+		apiCACertFileFlag            = flag.String(flagAPICACertFile, apiCACertFileEnv, "The path to a custom CA bundle for the API traffic")
+		apiEndpointFlag              = flag.String(flagAPIEndpoint, apiEndpointEnv, "The API endpoint")
+		apiInsecureSkipVerifyFlag    = flag.Bool(flagAPIInsecureSkipVerify, apiInsecureSkipVerify, "Whether to skip the TLS certificate verification for the API traffic")
+		apiKeyFlag                   = flag.String(flagAPIKey, apiKeyEnv, "The API key")
+		apiKeyFileFlag               = flag.String(flagAPIKeyFile, apiKeyFileEnv, "The path to a file containing the API key")
+		apiProxyFlag                 = flag.String(flagAPIProxy, apiProxyEnv, "The URL of an HTTPS proxy for the API traffic")
+		clusterCIDRsFlag             = flag.String(flagClusterCIDRs, clusterCIDRsEnv, "The comma separated cluster networks to authorize instead of individual nodes")
+		clusterIDFlag                = flag.String(flagClusterID, clusterIDEnv, "The cluster identifier substituted into the hostname template")
+		csiEndpointFlag              = flag.String(flagCSIEndpoint, csiEndpointEnv, "The CSI endpoint")
+		dataDeviceFlag               = flag.String(flagDataDevice, dataDeviceEnv, "The device path for the data disk on the storage servers (empty detects the device)")
+		debugLogDirectoryFlag        = flag.String(flagDebugLogDirectory, debugLogDirectoryEnv, "The path to a directory in which to store the full logs for failed provisioning operations (empty disables the logs)")
+		deletionGracePeriodFlag      = flag.Int(flagDeletionGracePeriod, deletionGracePeriod, "The grace period in seconds before a deleted volume is removed (0 deletes volumes immediately)")
+		dnsZoneFlag                  = flag.String(flagDNSZone, dnsZoneEnv, "The DNS zone in which the storage servers are registered (empty mounts by address)")
+		driftDetectionIntervalFlag   = flag.Int(flagDriftDetectionInterval, driftDetectionInterval, "The interval in seconds between export drift checks (0 disables drift detection)")
+		driftRepairFlag              = flag.Bool(flagDriftRepair, driftRepair, "Whether to automatically repair detected export drift")
+		dryRunFlag                   = flag.Bool(flagDryRun, dryRun, "Whether to log provisioning operations instead of performing them")
+		expandRebootWindowFlag       = flag.Int(flagExpandRebootWindow, expandRebootWindow, "The acceptable reboot window in seconds when right-sizing a storage server during expansion (0 disables package upgrades)")
+		idleScaleDownDaysFlag        = flag.Int(flagIdleScaleDownDays, idleScaleDownDays, "The number of days without published nodes before a storage server is downsized (0 disables scale-down)")
+		jumpHostAddressFlag          = flag.String(flagJumpHostAddress, jumpHostAddressEnv, "The address of an SSH jump host for the connections to the storage servers")
+		jumpHostPrivateKeyFlag       = flag.String(flagJumpHostPrivateKey, jumpHostPrivateKeyEnv, "The Base64 encoded private key for the SSH jump host")
+		jumpHostUserFlag             = flag.String(flagJumpHostUser, jumpHostUserEnv, "The user for the SSH jump host")
+		freezeTimeoutFlag            = flag.Int(flagFreezeTimeout, 60, "The freeze time window in seconds")
+		freezeVolumeFlag             = flag.String(flagFreezeVolume, "", "The id of a volume to freeze for backup purposes")
+		maxConcurrentProvisionsFlag  = flag.Int(flagMaxConcurrentProvisions, maxConcurrentProvisions, "The maximum number of concurrent provisioning operations (0 disables the limit)")
+		auditLogFlag                 = flag.String(flagAuditLog, auditLogEnv, "The path to the audit log file ('-' selects the standard output stream)")
+		autoHealFlag                 = flag.Bool(flagAutoHeal, autoHeal, "Whether to rebuild storage servers automatically after repeated failed health probes")
+		grpcKeepaliveIntervalFlag    = flag.Int(flagGRPCKeepaliveInterval, grpcKeepaliveInterval, "The interval in seconds between server keepalive pings on idle connections (0 uses the default)")
+		grpcKeepaliveTimeoutFlag     = flag.Int(flagGRPCKeepaliveTimeout, grpcKeepaliveTimeout, "The number of seconds to wait for a keepalive acknowledgement (0 uses the default)")
+		grpcMaxMessageSizeFlag       = flag.Int(flagGRPCMaxMessageSize, grpcMaxMessageSize, "The maximum size in bytes of gRPC messages (0 uses the default)")
+		grpcReflectionFlag           = flag.Bool(flagGRPCReflection, grpcReflection, "Whether to enable gRPC server reflection for tools like grpcurl")
+		grpcTimeoutFlag              = flag.Int(flagGRPCTimeout, grpcTimeout, "The timeout in seconds for individual gRPC calls (0 disables the timeout)")
+		healthProbeIntervalFlag      = flag.Int(flagHealthProbeInterval, healthProbeInterval, "The interval in seconds between health probes of the storage servers (0 disables the probes)")
+		hostnameTemplateFlag         = flag.String(flagHostnameTemplate, hostnameTemplateEnv, "The template for the volume part of the storage server hostnames (supports the {{CLUSTER_ID}}, {{PVC_NAME}}, {{PVC_NAMESPACE}} and {{VOLUME_NAME}} variables)")
+		kubernetesEventsFlag         = flag.Bool(flagKubernetesEvents, kubernetesEvents, "Whether to post Kubernetes events for provisioning milestones and failures")
+		maintenanceWindowFlag        = flag.String(flagMaintenanceWindow, maintenanceWindowEnv, "The daily UTC time window ('HH:MM-HH:MM') for applying security updates (empty disables patching)")
+		maxStorageServersFlag        = flag.Int(flagMaxStorageServers, maxStorageServers, "The maximum number of storage servers to provision (0 disables the cap)")
+		maxTotalCapacityFlag         = flag.Int(flagMaxTotalCapacity, maxTotalCapacity, "The maximum total provisioned capacity in gigabytes (0 disables the cap)")
+		maxVolumesPerNodeFlag        = flag.Int(flagMaxVolumesPerNode, maxVolumesPerNode, "The maximum number of volumes to place on a single node")
+		metricsPortFlag              = flag.Int(flagMetricsPort, metricsPort, "The port for the metrics endpoint (0 disables the endpoint)")
+		mountCheckIntervalFlag       = flag.Int(flagMountCheckInterval, mountCheckInterval, "The interval in seconds between checks for stale mounts (0 disables the checks)")
+		namespaceQuotaFlag           = flag.Int(flagNamespaceQuota, namespaceQuota, "The maximum provisioned capacity in gigabytes per namespace (0 disables the quota)")
+		networkEncryptionFlag        = flag.Bool(flagNetworkEncryption, networkEncryption, "Whether to encrypt the NFS traffic with WireGuard")
+		nfsLockdPortFlag             = flag.Int(flagNFSLockdPort, nfsLockdPort, "The port for the NFS lock daemon on the storage servers")
+		nfsMountdPortFlag            = flag.Int(flagNFSMountdPort, nfsMountdPort, "The port for the NFS mount daemon on the storage servers")
+		nfsStatdPortFlag             = flag.Int(flagNFSStatdPort, nfsStatdPort, "The port for the NFS status daemon on the storage servers")
+		nodeIDFlag                   = flag.String(flagNodeID, nodeIDEnv, "The node id")
+		profileRolloutPercentageFlag = flag.Int(flagProfileRolloutPercentage, profileRolloutPercentage, "The percentage of outdated storage servers to upgrade at a time")
+		serverMemoryFlag             = flag.Int(flagServerMemory, serverMemory, "The minimum amount of memory per storage server")
+		serverPackageIDFlag          = flag.String(flagServerPackageID, serverPackageIDEnv, "The exact package id for storage servers (bypasses the memory and processor requirements)")
+		serverProcessorsFlag         = flag.Int(flagServerProcessors, serverProcessors, "The minimum number of processors per storage server")
+		nodeExporterFlag             = flag.Bool(flagNodeExporter, nodeExporter, "Whether to install the node exporter on the storage servers")
+		offlineBundleFlag            = flag.String(flagOfflineBundle, offlineBundleEnv, "The path to a dependency bundle which is uploaded and installed instead of using apt-get")
+		rebuildVolumeFlag            = flag.String(flagRebuildVolume, "", "The id of a volume whose storage server should be rebuilt from its data disk")
+		rotateSSHKeyFlag             = flag.String(flagRotateSSHKey, "", "The Base64 encoded public key to install on all storage servers in place of the current keys")
+		upgradeConcurrencyFlag       = flag.Int(flagUpgradeConcurrency, 1, "The number of storage servers to upgrade concurrently during a fleet upgrade")
+		upgradeFleetFlag             = flag.Bool(flagUpgradeFleet, false, "Roll the current bootstrap configuration out to all storage servers and exit")
+		skipStagingFlag              = flag.Bool(flagSkipStaging, skipStaging, "Whether to drop the staging capability and mount volumes directly at the target path")
+		socketGIDFlag                = flag.Int(flagSocketGID, socketGID, "The group id to assign to the CSI socket (-1 leaves the group unchanged)")
+		socketModeFlag               = flag.String(flagSocketMode, socketMode, "The octal file mode to assign to the CSI socket (an empty string leaves the mode unchanged)")
+		socketUIDFlag                = flag.Int(flagSocketUID, socketUID, "The user id to assign to the CSI socket (-1 leaves the owner unchanged)")
+		sshKeyDirFlag                = flag.String(flagSSHKeyDir, sshKeyDirEnv, "The path to a directory in which to persist an automatically generated SSH key pair")
+		sshPrivateKeyFlag            = flag.String(flagSSHPrivateKey, sshPrivateKeyEnv, "The Base64 encoded private key for SSH connections")
+		sshPrivateKeyFileFlag        = flag.String(flagSSHPrivateKeyFile, sshPrivateKeyFileEnv, "The path to a file containing the private key for SSH connections")
+		sshPublicKeyFlag             = flag.String(flagSSHPublicKey, sshPublicKeyEnv, "The Base64 encoded public key for SSH connections")
+		sshPublicKeyFileFlag         = flag.String(flagSSHPublicKeyFile, sshPublicKeyFileEnv, "The path to a file containing the public key for SSH connections")
+		strictVersionSkewFlag        = flag.Bool(flagStrictVersionSkew, strictVersionSkew, "Whether to refuse staging volumes published by an incompatible controller version")
+		thawVolumeFlag               = flag.String(flagThawVolume, "", "The id of a volume to thaw before the freeze time window has passed")
+		tlsCACertFileFlag            = flag.String(flagTLSCACertFile, tlsCACertFileEnv, "The path to the certificate authority file for client certificate authentication")
+		tlsCertFileFlag              = flag.String(flagTLSCertFile, tlsCertFileEnv, "The path to the TLS certificate file for TCP endpoints")
+		tlsKeyFileFlag               = flag.String(flagTLSKeyFile, tlsKeyFileEnv, "The path to the TLS key file for TCP endpoints")
+		versionFlag                  = flag.Bool(flagVersion, false, "Print the version information and exit")
+		webhookURLFlag               = flag.String(flagWebhookURL, webhookURLEnv, "The URL for the Slack compatible webhook notifications about lifecycle events")
+	)
+
+	flag.CommandLine.Parse(flagArgs)
+
+	// Print the version information instead of starting the driver, if requested.
+	if *versionFlag {
+		fmt.Printf("%s %s\n", driver.DriverName, driver.DriverVersion)
+		fmt.Printf("Build commit: %s\n", driver.BuildCommit)
+		fmt.Printf("Build date: %s\n", driver.BuildDate)
+		fmt.Printf("Go version: %s\n", runtime.Version())
+
+		return
+	}
+
+	// Verify that all the required properties are defined and appear to be valid.
+	if *apiEndpointFlag == "" {
+		log.Fatalln("You must specify an API endpoint (-api-endpoint or CLOUDDK_API_ENDPOINT)")
+	}
+
+	_, err := url.ParseRequestURI(*apiEndpointFlag)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *apiKeyFlag == "" {
+		log.Fatalln("You must specify an API key (-api-key or CLOUDDK_API_KEY)")
+	}
+
+	if *csiEndpointFlag == "" {
+		log.Fatalln("You must specify a CSI endpoint (-csi-endpoint or CLOUDDK_CSI_ENDPOINT)")
+	}
+
+	if *nodeIDFlag == "" {
+		log.Fatalln("You must specify a node id (-node-id or CLOUDDK_NODE_ID)")
+	}
+
+	clusterCIDRs := []string{}
+
+	if *clusterCIDRsFlag != "" {
+		for _, cidr := range strings.Split(*clusterCIDRsFlag, ",") {
+			cidr = strings.TrimSpace(cidr)
+			_, _, err := net.ParseCIDR(cidr)
+
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			clusterCIDRs = append(clusterCIDRs, cidr)
+		}
+	}
+
+	if *deletionGracePeriodFlag < 0 {
+		log.Fatalln("The deletion grace period must be at least 0 (-deletion-grace-period or CLOUDDK_DELETION_GRACE_PERIOD)")
+	}
+
+	if *maxConcurrentProvisionsFlag < 0 {
+		log.Fatalln("The maximum number of concurrent provisioning operations must be at least 0 (-max-concurrent-provisions or CLOUDDK_MAX_CONCURRENT_PROVISIONS)")
+	}
+
+	if *expandRebootWindowFlag < 0 {
+		log.Fatalln("The expansion reboot window must be at least 0 (-expand-reboot-window or CLOUDDK_EXPAND_REBOOT_WINDOW)")
+	}
+
+	if *hostnameTemplateFlag != "" && !strings.Contains(*hostnameTemplateFlag, "{{VOLUME_NAME}}") {
+		log.Fatalln("The hostname template must contain the {{VOLUME_NAME}} variable so that every volume renders a unique hostname (-hostname-template or CLOUDDK_HOSTNAME_TEMPLATE)")
+	}
+
+	if *idleScaleDownDaysFlag < 0 {
+		log.Fatalln("The number of idle days must be at least 0 (-idle-scale-down-days or CLOUDDK_IDLE_SCALE_DOWN_DAYS)")
+	}
+
+	if *mountCheckIntervalFlag < 0 {
+		log.Fatalln("The mount check interval must be at least 0 (-mount-check-interval or CLOUDDK_MOUNT_CHECK_INTERVAL)")
+	}
+
+	if *grpcKeepaliveIntervalFlag < 0 || *grpcKeepaliveTimeoutFlag < 0 || *grpcMaxMessageSizeFlag < 0 || *grpcTimeoutFlag < 0 {
+		log.Fatalln("The gRPC server options must be at least 0")
+	}
+
+	if *healthProbeIntervalFlag < 0 {
+		log.Fatalln("The health probe interval must be at least 0 (-health-probe-interval or CLOUDDK_HEALTH_PROBE_INTERVAL)")
+	}
+
+	if *maxStorageServersFlag < 0 {
+		log.Fatalln("The maximum number of storage servers must be at least 0 (-max-storage-servers or CLOUDDK_MAX_STORAGE_SERVERS)")
+	}
+
+	if *maxTotalCapacityFlag < 0 {
+		log.Fatalln("The maximum total provisioned capacity must be at least 0 (-max-total-capacity or CLOUDDK_MAX_TOTAL_CAPACITY)")
+	}
+
+	if *maxVolumesPerNodeFlag < 1 {
+		log.Fatalln("The maximum number of volumes per node must be at least 1 (-max-volumes-per-node or CLOUDDK_MAX_VOLUMES_PER_NODE)")
+	}
+
+	if *namespaceQuotaFlag < 0 {
+		log.Fatalln("The namespace quota must be at least 0 (-namespace-quota or CLOUDDK_NAMESPACE_QUOTA)")
+	}
+
+	if *adminPortFlag < 0 || *adminPortFlag > 65535 {
+		log.Fatalln("The admin port must be between 0 and 65535 (-admin-port or CLOUDDK_ADMIN_PORT)")
+	}
+
+	if *metricsPortFlag < 0 || *metricsPortFlag > 65535 {
+		log.Fatalln("The metrics port must be between 0 and 65535 (-metrics-port or CLOUDDK_METRICS_PORT)")
+	}
+
+	if *nfsLockdPortFlag < 1 || *nfsLockdPortFlag > 65535 ||
+		*nfsMountdPortFlag < 1 || *nfsMountdPortFlag > 65535 ||
+		*nfsStatdPortFlag < 1 || *nfsStatdPortFlag > 65535 {
+		log.Fatalln("The NFS port assignments must be between 1 and 65535")
+	}
+
+	if *profileRolloutPercentageFlag < 0 || *profileRolloutPercentageFlag > 100 {
+		log.Fatalln("The profile rollout percentage must be between 0 and 100 (-profile-rollout-percentage or CLOUDDK_PROFILE_ROLLOUT_PERCENTAGE)")
+	}
+
+	if *serverMemoryFlag < 1 {
+		log.Fatalln("You must specify the minimum amount of memory per storage server (-server-memory or CLOUDDK_SERVER_MEMORY)")
+	}
+
+	if *serverProcessorsFlag < 1 {
+		log.Fatalln("You must specify the minimum number of processors per storage server (-server-processors or CLOUDDK_SERVER_PROCESSORS)")
+	}
+
+	if *sshPrivateKeyFlag == "" && *sshPrivateKeyFileFlag == "" && *sshKeyDirFlag == "" {
+		log.Fatalln("You must specify a private SSH key, a key file or a key directory (-ssh-private-key or CLOUDDK_SSH_PRIVATE_KEY)")
+	}
+
+	if *sshPublicKeyFlag == "" && *sshPublicKeyFileFlag == "" && *sshKeyDirFlag == "" {
+		log.Fatalln("You must specify a public SSH key, a key file or a key directory (-ssh-public-key or CLOUDDK_SSH_PUBLIC_KEY)")
+	}
+
+	socketFileMode := os.FileMode(0)
+
+	if *socketModeFlag != "" {
+		mode, err := strconv.ParseUint(*socketModeFlag, 8, 32)
+
+		if err != nil {
+			log.Fatalln("The socket mode must be a valid octal file mode (-socket-mode or CLOUDDK_SOCKET_MODE)")
+		}
+
+		socketFileMode = os.FileMode(mode)
+	}
+
+	if (*tlsCertFileFlag == "") != (*tlsKeyFileFlag == "") {
+		log.Fatalln("You must specify both a TLS certificate file and a TLS key file (-tls-cert-file and -tls-key-file)")
+	}
+
+	if *tlsCACertFileFlag != "" && *tlsCertFileFlag == "" {
+		log.Fatalln("You must specify a TLS certificate file in order to enable client certificate authentication (-tls-cert-file or CLOUDDK_TLS_CERT_FILE)")
+	}
+
+	// Decode the private and public SSH keys.
+	if *sshPrivateKeyFlag != "" {
+		key, err := base64.StdEncoding.DecodeString(*sshPrivateKeyFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		*sshPrivateKeyFlag = bytes.NewBuffer(key).String()
+	}
+
+	if *sshPublicKeyFlag != "" {
+		key, err := base64.StdEncoding.DecodeString(*sshPublicKeyFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		*sshPublicKeyFlag = bytes.NewBuffer(key).String()
+	}
+
+	if *jumpHostPrivateKeyFlag != "" {
+		key, err := base64.StdEncoding.DecodeString(*jumpHostPrivateKeyFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		*jumpHostPrivateKeyFlag = bytes.NewBuffer(key).String()
+	}
+
+	// Read the key material from the mounted files, if any. The file based options take precedence
+	// over the Base64 encoded variants as they do not leak into the pod specification.
+	if *apiKeyFileFlag != "" {
+		contents, err := ioutil.ReadFile(*apiKeyFileFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		*apiKeyFlag = strings.TrimSpace(bytes.NewBuffer(contents).String())
+	}
+
+	if *sshPrivateKeyFileFlag != "" {
+		contents, err := ioutil.ReadFile(*sshPrivateKeyFileFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		_, err = ssh.ParsePrivateKey(contents)
+
+		if err != nil {
+			log.Fatalln("The SSH private key file must contain a valid private key (-ssh-private-key-file or CLOUDDK_SSH_PRIVATE_KEY_FILE)")
+		}
+
+		*sshPrivateKeyFlag = bytes.NewBuffer(contents).String()
+	}
+
+	if *sshPublicKeyFileFlag != "" {
+		contents, err := ioutil.ReadFile(*sshPublicKeyFileFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		_, _, _, _, err = ssh.ParseAuthorizedKey(contents)
+
+		if err != nil {
+			log.Fatalln("The SSH public key file must contain a valid public key (-ssh-public-key-file or CLOUDDK_SSH_PUBLIC_KEY_FILE)")
+		}
+
+		*sshPublicKeyFlag = bytes.NewBuffer(contents).String()
+	}
+
+	// Generate and persist an SSH key pair when none is provided.
+	if *sshPrivateKeyFlag == "" && *sshKeyDirFlag != "" {
+		privateKey, publicKey, err := driver.LoadOrGenerateSSHKeyPair(*sshKeyDirFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		*sshPrivateKeyFlag = privateKey
+		*sshPublicKeyFlag = publicKey
+	}
+
+	// Initialize the driver.
+	c := driver.Configuration{
+		AdminPort:             *adminPortFlag,
+		APICACertFile:         *apiCACertFileFlag,
+		APIInsecureSkipVerify: *apiInsecureSkipVerifyFlag,
+		APIProxyURL:           *apiProxyFlag,
+		AuditLogPath:          *auditLogFlag,
+		AutoHeal:              *autoHealFlag,
+		ClientSettings: &clouddk.ClientSettings{
+			Endpoint: *apiEndpointFlag,
+			Key:      *apiKeyFlag,
+		},
+		ClusterCIDRs:             clusterCIDRs,
+		ClusterID:                *clusterIDFlag,
+		DataDevice:               *dataDeviceFlag,
+		DebugLogDirectory:        *debugLogDirectoryFlag,
+		DeletionGracePeriod:      *deletionGracePeriodFlag,
+		DNSZone:                  *dnsZoneFlag,
+		DriftDetectionInterval:   *driftDetectionIntervalFlag,
+		DriftRepair:              *driftRepairFlag,
+		DryRun:                   *dryRunFlag,
+		ExpandRebootWindow:       *expandRebootWindowFlag,
+		GRPCKeepaliveInterval:    *grpcKeepaliveIntervalFlag,
+		GRPCKeepaliveTimeout:     *grpcKeepaliveTimeoutFlag,
+		GRPCMaxMessageSize:       *grpcMaxMessageSizeFlag,
+		GRPCReflection:           *grpcReflectionFlag,
+		GRPCTimeout:              *grpcTimeoutFlag,
+		HealthProbeInterval:      *healthProbeIntervalFlag,
+		HostnameTemplate:         *hostnameTemplateFlag,
+		IdleScaleDownDays:        *idleScaleDownDaysFlag,
+		JumpHostAddress:          *jumpHostAddressFlag,
+		JumpHostPrivateKey:       *jumpHostPrivateKeyFlag,
+		JumpHostUser:             *jumpHostUserFlag,
+		Endpoint:                 *csiEndpointFlag,
+		MaxConcurrentProvisions:  *maxConcurrentProvisionsFlag,
+		KubernetesEvents:         *kubernetesEventsFlag,
+		MaintenanceWindow:        *maintenanceWindowFlag,
+		MaxStorageServers:        *maxStorageServersFlag,
+		MaxTotalCapacity:         *maxTotalCapacityFlag,
+		MaxVolumesPerNode:        *maxVolumesPerNodeFlag,
+		MetricsPort:              *metricsPortFlag,
+		MountCheckInterval:       *mountCheckIntervalFlag,
+		NamespaceQuota:           *namespaceQuotaFlag,
+		NetworkEncryption:        *networkEncryptionFlag,
+		NFSLockdPort:             *nfsLockdPortFlag,
+		NFSMountdPort:            *nfsMountdPortFlag,
+		NFSStatdPort:             *nfsStatdPortFlag,
+		NodeExporter:             *nodeExporterFlag,
+		NodeID:                   *nodeIDFlag,
+		OfflineBundlePath:        *offlineBundleFlag,
+		PrivateKey:               *sshPrivateKeyFlag,
+		ProfileRolloutPercentage: *profileRolloutPercentageFlag,
+		PublicKey:                *sshPublicKeyFlag,
+		ServerMemory:             *serverMemoryFlag,
+		ServerPackageID:          *serverPackageIDFlag,
+		ServerProcessors:         *serverProcessorsFlag,
+		SkipStaging:              *skipStagingFlag,
+		SocketGID:                *socketGIDFlag,
+		SocketMode:               socketFileMode,
+		SocketUID:                *socketUIDFlag,
+		StrictVersionSkew:        *strictVersionSkewFlag,
+		TLSCACertFile:            *tlsCACertFileFlag,
+		TLSCertFile:              *tlsCertFileFlag,
+		TLSKeyFile:               *tlsKeyFileFlag,
+		WebhookURL:               *webhookURLFlag,
+	}
+
+	drv, err := driver.NewDriver(&c)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Verify the configuration before performing any operations.
+	err = drv.Validate()
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Run an admin subcommand instead of starting the driver, if requested.
+	if len(command) > 0 {
+		runCommand(drv, command)
+
+		return
+	}
+
+	// Freeze or thaw a volume instead of starting the driver, if requested.
+	if *freezeVolumeFlag != "" {
+		err = drv.FreezeVolume(*freezeVolumeFlag, *freezeTimeoutFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	if *thawVolumeFlag != "" {
+		err = drv.ThawVolume(*thawVolumeFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	// Rebuild the storage server backing a volume instead of starting the driver, if requested.
+	if *rebuildVolumeFlag != "" {
+		err = drv.RebuildVolume(*rebuildVolumeFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	// Rotate the SSH key on the storage servers instead of starting the driver, if requested.
+	if *rotateSSHKeyFlag != "" {
+		err = drv.RotateSSHKey(*rotateSSHKeyFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	// Roll the current bootstrap configuration out to the fleet instead of starting the driver, if requested.
+	if *upgradeFleetFlag {
+		err = drv.UpgradeFleet(*upgradeConcurrencyFlag)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	drv.Run()
+}
+
+// runCommand dispatches an admin subcommand to the driver.
+func runCommand(drv *driver.Driver, command []string) {
+	const usage = "Usage: clouddk-csi-driver backup|restore <volume-id> --target <path>|doctor|volumes list|inspect <id>|cleanup --orphans|migrate <id> --package <package-id> [--location <location>]"
+
+	if command[0] == "doctor" {
+		err := drv.RunDoctor()
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	if command[0] == "backup" || command[0] == "restore" {
+		if len(command) != 4 || command[2] != "--target" {
+			log.Fatalln(usage)
+		}
+
+		var err error
+
+		if command[0] == "backup" {
+			err = drv.BackupVolume(command[1], command[3])
+		} else {
+			err = drv.RestoreVolume(command[1], command[3])
+		}
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	if command[0] != "volumes" || len(command) < 2 {
+		log.Fatalln(usage)
+	}
+
+	var err error
+
+	switch command[1] {
+	case "list":
+		err = drv.ListVolumeServers()
+	case "inspect":
+		if len(command) < 3 {
+			log.Fatalln(usage)
+		}
+
+		err = drv.InspectVolume(command[2])
+	case "cleanup":
+		if len(command) < 3 || command[2] != "--orphans" {
+			log.Fatalln(usage)
+		}
+
+		err = drv.CleanupOrphanVolumes()
+	case "migrate":
+		if len(command) < 5 || command[3] != "--package" {
+			log.Fatalln(usage)
+		}
+
+		location := ""
+
+		if len(command) == 7 && command[5] == "--location" {
+			location = command[6]
+		} else if len(command) != 5 {
+			log.Fatalln(usage)
+		}
+
+		err = drv.MigrateVolume(command[2], command[4], location)
+	default:
+		log.Fatalln(usage)
+	}
+
+	if err != nil {
+		log.Fatalln(err)
+	}
 }
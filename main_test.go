@@ -0,0 +1,58 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSplitCommandArgs verifies that admin subcommands and their options are separated from the
+// command line flags.
+func TestSplitCommandArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		command  []string
+		flagArgs []string
+	}{
+		{
+			args:     []string{"-api-key", "secret"},
+			command:  []string{},
+			flagArgs: []string{"-api-key", "secret"},
+		},
+		{
+			args:     []string{"doctor"},
+			command:  []string{"doctor"},
+			flagArgs: []string{},
+		},
+		{
+			args:     []string{"backup", "ns-1234", "--target", "/tmp/backup.tar.gz", "-api-key", "secret"},
+			command:  []string{"backup", "ns-1234", "--target", "/tmp/backup.tar.gz"},
+			flagArgs: []string{"-api-key", "secret"},
+		},
+		{
+			args:     []string{"volumes", "cleanup", "--orphans"},
+			command:  []string{"volumes", "cleanup", "--orphans"},
+			flagArgs: []string{},
+		},
+		{
+			args:     []string{"volumes", "migrate", "ns-1234", "--package", "the-package-id", "--location", "dk2", "-api-key", "secret"},
+			command:  []string{"volumes", "migrate", "ns-1234", "--package", "the-package-id", "--location", "dk2"},
+			flagArgs: []string{"-api-key", "secret"},
+		},
+	}
+
+	for _, test := range tests {
+		command, flagArgs := splitCommandArgs(test.args)
+
+		if strings.Join(command, " ") != strings.Join(test.command, " ") {
+			t.Errorf("Unexpected command %v for arguments %v (expected %v)", command, test.args, test.command)
+		}
+
+		if strings.Join(flagArgs, " ") != strings.Join(test.flagArgs, " ") {
+			t.Errorf("Unexpected flags %v for arguments %v (expected %v)", flagArgs, test.args, test.flagArgs)
+		}
+	}
+}